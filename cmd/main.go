@@ -1,30 +1,72 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"strconv"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/signaller-matrix/signaller/internal"
 	"github.com/signaller-matrix/signaller/internal/backends/memory"
+	"github.com/signaller-matrix/signaller/internal/config"
 )
 
-var (
-	server            *internal.Server
-	defaultPortNumber = 8008
-)
+func main() {
+	configPath := flag.String("config", "", "path to YAML config file, default config is used if empty")
+	flag.Parse()
 
-func init() {
-	var err error
-	server, err = internal.NewServer(defaultPortNumber)
+	cfg, err := loadConfig(*configPath)
 	if err != nil {
-		panic(err)
+		log.Fatalln(err)
 	}
-	server.Address = "localhost"
-	server.Backend = memory.NewBackend(server.Address)
-	server.Backend.Register("andrew", "1", "")
-}
 
-func main() {
-	log.Println("Server started on port " + strconv.Itoa(defaultPortNumber))
+	server, err := internal.NewServer(cfg, memory.NewBackend(cfg.ServerName))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *configPath != "" {
+		go reloadOnSIGHUP(server, *configPath)
+	}
+
+	for _, listener := range cfg.Listeners {
+		log.Printf("Server %s started on %s:%d", cfg.ServerName, listener.Address, listener.Port)
+	}
 	log.Println(server.Run())
 }
+
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		return config.Default(), nil
+	}
+
+	return config.Load(path)
+}
+
+// reloadOnSIGHUP applies changes of config file each time SIGHUP is received
+func reloadOnSIGHUP(server *internal.Server, path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		next, err := config.Load(path)
+		if err != nil {
+			log.Printf("Config is not reloaded: %v", err)
+			continue
+		}
+
+		cfg, ignored := config.Reload(server.Config(), next)
+		if err := server.SetConfig(cfg); err != nil {
+			log.Printf("Config is not reloaded: %v", err)
+			continue
+		}
+
+		if len(ignored) > 0 {
+			log.Printf("Config is reloaded, changes of %s require restart", strings.Join(ignored, ", "))
+		} else {
+			log.Println("Config is reloaded")
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/admin"
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
+)
+
+// adminUser returns user of request if the user is server administrator. Otherwise error response is sent.
+func adminUser(w http.ResponseWriter, r *http.Request) User {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return nil
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return nil
+	}
+
+	if !user.IsAdmin() {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "you are not a server administrator")
+		return nil
+	}
+
+	return user
+}
+
+// sharedSecretRegisterHandler registers users with requests signed by shared secret from config.
+// It works regardless of registration policy, so it can be used by provisioning scripts.
+func sharedSecretRegisterHandler(w http.ResponseWriter, r *http.Request) {
+	secret := currServer.Config().Registration.SharedSecret
+	if secret == "" {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "shared secret registration is disabled")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sendJsonResponse(w, http.StatusOK, admin.NonceResponse{Nonce: currServer.registerNonces.new()})
+	case http.MethodPost:
+		var request admin.RegisterRequest
+		if err := getRequest(r, &request); err != nil {
+			errorResponse(w, models.M_NOT_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if !currServer.registerNonces.take(request.Nonce) {
+			errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "unknown or expired nonce")
+			return
+		}
+
+		if !validSharedSecretMAC(secret, request) {
+			errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "wrong mac")
+			return
+		}
+
+		user, apiErr := currServer.Backend.CreateUser(request.Username, request.Password)
+		if apiErr != nil {
+			errorResponse(w, apiErr, http.StatusBadRequest, "")
+			return
+		}
+
+		user.SetAdmin(request.Admin)
+
+		if request.DeviceID == "" {
+			request.DeviceID = RandomString(deviceIDSize)
+		}
+
+		_, token, apiErr := currServer.Backend.Login(request.Username, request.Password, request.DeviceID)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		response := admin.RegisterResponse{
+			UserID:      user.ID(),
+			AccessToken: token,
+			HomeServer:  currServer.Address,
+			DeviceID:    request.DeviceID}
+
+		sendJsonResponse(w, http.StatusOK, response)
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// registrationTokensHandler lists and creates registration tokens
+func registrationTokensHandler(w http.ResponseWriter, r *http.Request) {
+	if adminUser(w, r) == nil {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		response := admin.RegistrationTokensResponse{
+			RegistrationTokens: currServer.Backend.RegistrationTokens()}
+
+		sendJsonResponse(w, http.StatusOK, response)
+	case http.MethodPost:
+		var request admin.NewRegistrationTokenRequest
+		if err := getRequest(r, &request); err != nil {
+			errorResponse(w, models.M_NOT_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		token, apiErr := currServer.Backend.CreateRegistrationToken(request)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, token)
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// registrationTokenHandler returns, updates and deletes registration token
+func registrationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if adminUser(w, r) == nil {
+		return
+	}
+
+	tokenValue := mux.Vars(r)["token"]
+
+	token, apiErr := currServer.Backend.RegistrationToken(tokenValue)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sendJsonResponse(w, http.StatusOK, token)
+	case http.MethodPut:
+		var request admin.UpdateRegistrationTokenRequest
+		if err := getRequest(r, &request); err != nil {
+			errorResponse(w, models.M_NOT_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// absent fields are not changed, null removes limit
+		if request.UsesAllowed != nil {
+			token.UsesAllowed = nil
+			if err := json.Unmarshal(request.UsesAllowed, &token.UsesAllowed); err != nil {
+				errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "uses_allowed must be integer or null")
+				return
+			}
+		}
+
+		if request.ExpiryTime != nil {
+			token.ExpiryTime = nil
+			if err := json.Unmarshal(request.ExpiryTime, &token.ExpiryTime); err != nil {
+				errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "expiry_time must be integer or null")
+				return
+			}
+		}
+
+		token, apiErr = currServer.Backend.UpdateRegistrationToken(tokenValue, token.UsesAllowed, token.ExpiryTime)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, token)
+	case http.MethodDelete:
+		if apiErr := currServer.Backend.DeleteRegistrationToken(tokenValue); apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// adminThreePIDsHandler associates third party identifier with user, so user can log in with it
+func adminThreePIDsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	if adminUser(w, r) == nil {
+		return
+	}
+
+	localpart, serverName, err := SplitUserID(mux.Vars(r)["userId"])
+	if err != nil {
+		errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := currServer.Backend.GetUserByName(localpart)
+	if serverName != currServer.Address || user == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "user not found")
+		return
+	}
+
+	var request admin.AddThreePIDRequest
+	if err := getRequest(r, &request); err != nil {
+		errorResponse(w, models.M_NOT_JSON, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if apiErr := user.AddThreePID(request.Medium, request.Address); apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, threepids.Response{ThreePIDs: user.ThreePIDs()})
+}
@@ -1,26 +1,65 @@
 package internal
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/admin"
+	"github.com/signaller-matrix/signaller/internal/models/capabilities"
 	"github.com/signaller-matrix/signaller/internal/models/common"
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
 	"github.com/signaller-matrix/signaller/internal/models/devices"
+	"github.com/signaller-matrix/signaller/internal/models/eventcontext"
 	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+	"github.com/signaller-matrix/signaller/internal/models/members"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/notifications"
+	"github.com/signaller-matrix/signaller/internal/models/peek"
+	"github.com/signaller-matrix/signaller/internal/models/publicrooms"
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+	"github.com/signaller-matrix/signaller/internal/models/roominitialsync"
+	"github.com/signaller-matrix/signaller/internal/models/search"
 	"github.com/signaller-matrix/signaller/internal/models/sync"
+	"github.com/signaller-matrix/signaller/internal/models/tags"
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
 )
 
 type Backend interface {
 	Register(username, password, device string) (user User, token string, err models.ApiError)
+	CreateUser(username, password string) (User, models.ApiError)
+	RegisterGuest(device string) (user User, token string, err models.ApiError)
 	Login(username, password, device string) (user User, token string, err models.ApiError)
 	GetUserByToken(token string) (user User)
+	GetUserByThreePID(medium, address string) User
+	GetUserBySSOSubject(issuer, subject string) User
+	CreateSSOUser(username, issuer, subject string) (User, models.ApiError)
+	CreateLoginToken(user User, lifetime time.Duration) string
+	LoginByToken(token, device string) (user User, accessToken string, err models.ApiError)
 	GetUserByName(userName string) User
 	GetRoomByID(id string) Room
-	PublicRooms(filter string) []Room
-	ValidateUsernameFunc() func(string) error
+	PublicRooms(request publicrooms.Request) (*publicrooms.Response, models.ApiError)
+	ValidateUsernameFunc() func(string) models.ApiError
+	SetValidateUsernameFunc(func(string) models.ApiError)
 	GetEventByID(id string) events.Event
 	PutEvent(events.Event) error
 	GetRoomByAlias(string) Room
 	GetEventsSince(user User, sinceToken string, limit int) []events.Event
+	PeekMessages(room Room, from, to string, dir messages.Direction, limit int, eventFilter *filter.RoomEventFilter) (*messages.Response, models.ApiError)
+	PeekRoomInitialSync(room Room) (*roominitialsync.Response, models.ApiError)
+	PeekEvents(room Room, from string, timeout time.Duration) (*peek.Response, models.ApiError)
+	RoomVersions() capabilities.RoomVersionsCapability
+	SetAccessTokenLifetime(lifetime time.Duration)
+	RegistrationTokens() []admin.RegistrationToken
+	RegistrationToken(token string) (admin.RegistrationToken, models.ApiError)
+	CreateRegistrationToken(request admin.NewRegistrationTokenRequest) (admin.RegistrationToken, models.ApiError)
+	UpdateRegistrationToken(token string, usesAllowed *int, expiryTime *int64) (admin.RegistrationToken, models.ApiError)
+	DeleteRegistrationToken(token string) models.ApiError
+	IsRegistrationTokenValid(token string) bool
+	ReserveRegistrationToken(token string) models.ApiError
+	ReleaseRegistrationToken(token string, completed bool)
 }
 
 type Room interface {
@@ -42,6 +81,14 @@ type User interface {
 	Name() string
 	ID() string
 	Password() string
+	IsGuest() bool
+	IsAdmin() bool
+	SetAdmin(admin bool)
+	ThreePIDs() []threepids.ThirdPartyIdentifier
+	AddThreePID(medium, address string) models.ApiError
+	IsNewDevice(device string) bool
+	SetDeviceDisplayName(device, displayName string)
+	UpgradeGuest(password string) models.ApiError
 	CreateRoom(request createroom.Request) (Room, models.ApiError)
 	LeaveRoom(room Room) models.ApiError
 	SetTopic(room Room, topic string) models.ApiError
@@ -58,5 +105,33 @@ type User interface {
 	GetFilterByID(filterID string) *common.Filter
 	AddRoomAlias(Room, string) models.ApiError
 	DeleteRoomAlias(string) models.ApiError
+	RoomAliases(Room) ([]string, models.ApiError)
+	CanonicalAlias(Room) (*events.CanonicalAliasContent, models.ApiError)
+	SetCanonicalAlias(Room, events.CanonicalAliasContent) (eventID string, err models.ApiError)
 	Sync(token string, request sync.SyncRequest) (response *sync.SyncReply, err models.ApiError)
+	PushRules() pushrules.Ruleset
+	AddPushRule(kind pushrules.Kind, rule pushrules.PushRule, before, after string) models.ApiError
+	DeletePushRule(kind pushrules.Kind, ruleID string) models.ApiError
+	SetPushRuleEnabled(kind pushrules.Kind, ruleID string, enabled bool) models.ApiError
+	SetPushRuleActions(kind pushrules.Kind, ruleID string, actions []pushrules.Action) models.ApiError
+	Pushers() []pushers.Pusher
+	SetPusher(request pushers.SetRequest) models.ApiError
+	Notifications(from string, limit int, only string) (*notifications.Response, models.ApiError)
+	SetReadReceipt(room Room, eventID string) models.ApiError
+	Members(room Room, at string, membership, notMembership events.Membership) ([]events.StateEvent, models.ApiError)
+	JoinedMembers(room Room) (map[string]members.RoomMember, models.ApiError)
+	GetEvent(room Room, eventID string) (*events.RoomEvent, models.ApiError)
+	Messages(room Room, from, to string, dir messages.Direction, limit int, eventFilter *filter.RoomEventFilter) (*messages.Response, models.ApiError)
+	EventContext(room Room, eventID string, limit int, eventFilter *filter.RoomEventFilter) (*eventcontext.Response, models.ApiError)
+	Search(criteria search.RoomEventsCriteria, nextBatch string) (*search.RoomEventsResults, models.ApiError)
+	RoomInitialSync(room Room) (*roominitialsync.Response, models.ApiError)
+	PeekEvents(room Room, from string, timeout time.Duration) (*peek.Response, models.ApiError)
+	SetAccountData(dataType string, content json.RawMessage) models.ApiError
+	AccountData(dataType string) (json.RawMessage, models.ApiError)
+	SetRoomAccountData(room Room, dataType string, content json.RawMessage) models.ApiError
+	RoomAccountData(room Room, dataType string) (json.RawMessage, models.ApiError)
+	Tags(room Room) (map[string]tags.Tag, models.ApiError)
+	SetTag(room Room, name string, tag tags.Tag) models.ApiError
+	DeleteTag(room Room, name string) models.ApiError
+	UpgradeRoom(room Room, version string) (Room, models.ApiError)
 }
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// accountData is private data of user of some type
+type accountData struct {
+	content  json.RawMessage
+	position int64 // stream position of last change
+}
+
+// SetAccountData sets global account data of specified type
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-user-userid-account-data-type
+func (user *User) SetAccountData(dataType string, content json.RawMessage) models.ApiError {
+	if events.EventType(dataType) == events.IgnoredUserList {
+		return user.setIgnoredUsers(content)
+	}
+
+	return user.updateAccountData("", dataType, func(json.RawMessage, int64) (json.RawMessage, models.ApiError) {
+		return content, nil
+	})
+}
+
+// AccountData returns global account data of specified type
+func (user *User) AccountData(dataType string) (json.RawMessage, models.ApiError) {
+	return user.getAccountData("", dataType)
+}
+
+// SetRoomAccountData sets account data of specified type for room
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-user-userid-rooms-roomid-account-data-type
+func (user *User) SetRoomAccountData(room internal.Room, dataType string, content json.RawMessage) models.ApiError {
+	return user.updateAccountData(room.ID(), dataType, func(json.RawMessage, int64) (json.RawMessage, models.ApiError) {
+		return content, nil
+	})
+}
+
+// RoomAccountData returns account data of specified type for room
+func (user *User) RoomAccountData(room internal.Room, dataType string) (json.RawMessage, models.ApiError) {
+	return user.getAccountData(room.ID(), dataType)
+}
+
+func (user *User) getAccountData(roomID, dataType string) (json.RawMessage, models.ApiError) {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	data, exists := user.accountData[roomID][dataType]
+	if !exists {
+		return nil, models.NewError(models.M_NOT_FOUND, "account data not found")
+	}
+
+	return data.content, nil
+}
+
+// updateAccountData replaces account data of room with result of update func, which gets current content (nil if there is no data)
+// and stream position of change. Change is placed to stream, so it will be delivered to clients with next sync.
+func (user *User) updateAccountData(roomID, dataType string, update func(content json.RawMessage, position int64) (json.RawMessage, models.ApiError)) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	// Position is taken under user mutex, so clients woken up by it wait until change is written
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	position := user.backend.nextStreamPosition()
+
+	content, err := update(user.accountData[roomID][dataType].content, position)
+	if err != nil {
+		return err
+	}
+
+	if user.accountData[roomID] == nil {
+		user.accountData[roomID] = make(map[string]accountData)
+	}
+	user.accountData[roomID][dataType] = accountData{content: content, position: position}
+
+	return nil
+}
+
+// accountDataBetween returns account data of room which was changed after since position up to until position (inclusive)
+func (user *User) accountDataBetween(roomID string, since, until int64, match func(events.EventType) bool) []events.Event {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	result := []events.Event{}
+	for dataType, data := range user.accountData[roomID] {
+		if data.position <= since || data.position > until || !match(events.EventType(dataType)) {
+			continue
+		}
+
+		result = append(result, &events.AccountDataEvent{ContentData: data.content, EType: events.EventType(dataType)})
+	}
+
+	return result
+}
+
+// nextStreamPosition reserves position in stream for change which isn't stored as event.
+// It may be called with locked user mutex, so backend mutex must not be held while user mutex is being locked.
+func (backend *Backend) nextStreamPosition() int64 {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	backend.streamPosition++
+	backend.notifyWaiters()
+
+	return backend.streamPosition
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+)
+
+func TestAccountData(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	_, err = user.AccountData("org.example.settings")
+	assert.Error(t, err)
+
+	assert.NoError(t, user.SetAccountData("org.example.settings", json.RawMessage(`{"theme":"dark"}`)))
+	content, err := user.AccountData("org.example.settings")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"theme":"dark"}`, string(content))
+
+	// Room account data is separated from global one
+	_, err = user.RoomAccountData(room, "org.example.settings")
+	assert.Error(t, err)
+
+	assert.NoError(t, user.SetRoomAccountData(room, "org.example.settings", json.RawMessage(`{"theme":"light"}`)))
+	content, err = user.RoomAccountData(room, "org.example.settings")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"theme":"light"}`, string(content))
+
+	guest, _, err := backend.RegisterGuest("")
+	assert.NoError(t, err)
+	assert.Error(t, guest.SetAccountData("org.example.settings", json.RawMessage(`{}`)))
+}
+
+func TestSyncAccountData(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, token, err := backend.Register("user1", "", "device1")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user.SetAccountData("org.example.settings", json.RawMessage(`{"theme":"dark"}`)))
+	assert.NoError(t, user.SetAccountData("org.example.other", json.RawMessage(`{}`)))
+	assert.NoError(t, user.SetRoomAccountData(room, "org.example.settings", json.RawMessage(`{"theme":"light"}`)))
+
+	response, err := user.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, response.AccountData.Events, 2)
+	assert.Len(t, response.Rooms.Join[room.ID()].AccountData.Events, 1)
+
+	// Only changes are sent in incremental sync
+	assert.NoError(t, user.SetAccountData("org.example.settings", json.RawMessage(`{"theme":"light"}`)))
+
+	response, err = user.Sync(token, mSync.SyncRequest{Since: response.NextBatch})
+	assert.NoError(t, err)
+	assert.Len(t, response.AccountData.Events, 1)
+	assert.Equal(t, events.EventType("org.example.settings"), response.AccountData.Events[0].Type())
+	assert.JSONEq(t, `{"theme":"light"}`, string(response.AccountData.Events[0].Content()))
+	assert.Empty(t, response.Rooms.Join[room.ID()].AccountData.Events)
+
+	// Account data can be filtered by type
+	response, err = user.Sync(token, mSync.SyncRequest{Filter: `{"account_data":{"types":["org.example.other"]}}`})
+	assert.NoError(t, err)
+	assert.Len(t, response.AccountData.Events, 1)
+	assert.Equal(t, events.EventType("org.example.other"), response.AccountData.Events[0].Type())
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// AddRoomAlias maps alias of this server to room
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-directory-room-roomalias
+func (user *User) AddRoomAlias(room internal.Room, alias string) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	localpart, err := user.backend.localAlias(alias)
+	if err != nil {
+		return err
+	}
+
+	user.backend.mutex.Lock()
+	defer user.backend.mutex.Unlock()
+
+	if room.Creator().ID() != user.ID() {
+		return models.NewError(models.M_FORBIDDEN, "only room creator can add room alias") // TODO: make room admins can use this method
+	}
+
+	if _, exists := user.backend.roomAliases[localpart]; exists {
+		return models.NewError(models.M_UNKNOWN, fmt.Sprintf("room alias %s already exists", alias))
+	}
+
+	user.backend.addAlias(localpart, room)
+
+	return nil
+}
+
+// DeleteRoomAlias removes alias mapping. Room creator and users who can change canonical alias of room are allowed to do it.
+// Removed alias is dropped from canonical alias of room too.
+// https://matrix.org/docs/spec/client_server/latest#delete-matrix-client-r0-directory-room-roomalias
+func (user *User) DeleteRoomAlias(alias string) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	localpart, err := user.backend.localAlias(alias)
+	if err != nil {
+		return err
+	}
+
+	room, ok := user.backend.GetRoomByAlias(localpart).(*Room)
+	if !ok {
+		return models.NewError(models.M_NOT_FOUND, "room alias not found")
+	}
+
+	powerLevels := room.powerLevels()
+	if room.Creator().ID() != user.ID() && powerLevels.UserLevel(user.ID()) < powerLevels.EventLevel(events.CanonicalAlias, true) {
+		return models.NewError(models.M_FORBIDDEN, "not enough power level to delete room alias")
+	}
+
+	user.backend.mutex.Lock()
+	if user.backend.roomAliases[localpart] != internal.Room(room) {
+		user.backend.mutex.Unlock()
+		return models.NewError(models.M_NOT_FOUND, "room alias not found")
+	}
+	user.backend.removeAlias(localpart)
+	user.backend.mutex.Unlock()
+
+	stateEvent, exists := user.backend.currentStateEvent(room.ID(), events.CanonicalAlias, "")
+	if !exists {
+		return nil
+	}
+
+	var content events.CanonicalAliasContent
+	if json.Unmarshal(stateEvent.Content, &content) != nil {
+		return nil
+	}
+
+	fullAlias := internal.GetCanonicalAlias(user.backend.hostname, localpart)
+
+	changed := false
+	if content.Alias == fullAlias {
+		content.Alias = ""
+		changed = true
+	}
+	for i, altAlias := range content.AltAliases {
+		if altAlias == fullAlias {
+			content.AltAliases = append(content.AltAliases[:i], content.AltAliases[i+1:]...)
+			changed = true
+			break
+		}
+	}
+
+	if changed {
+		newContent, _ := json.Marshal(content)
+		user.backend.PutEvent(newStateEvent(room, user, events.CanonicalAlias, "", newContent))
+		room.setAliasName(content.Alias)
+	}
+
+	return nil
+}
+
+// RoomAliases returns local aliases of room
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-aliases
+func (user *User) RoomAliases(room internal.Room) ([]string, models.ApiError) {
+	if !user.backend.newVisibilityChecker(room.ID(), user.ID()).canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view aliases of room")
+	}
+
+	aliases := room.Aliases()
+	if aliases == nil {
+		aliases = []string{}
+	}
+
+	return aliases, nil
+}
+
+// CanonicalAlias returns current canonical alias state of room
+// https://matrix.org/docs/spec/client_server/latest#m-room-canonical-alias
+func (user *User) CanonicalAlias(room internal.Room) (*events.CanonicalAliasContent, models.ApiError) {
+	if !user.backend.newVisibilityChecker(room.ID(), user.ID()).canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view state of room")
+	}
+
+	stateEvent, exists := user.backend.currentStateEvent(room.ID(), events.CanonicalAlias, "")
+	if !exists {
+		return nil, models.NewError(models.M_NOT_FOUND, "room has no canonical alias")
+	}
+
+	var content events.CanonicalAliasContent
+	if err := json.Unmarshal(stateEvent.Content, &content); err != nil {
+		return nil, models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
+	return &content, nil
+}
+
+// SetCanonicalAlias sends canonical alias state event. Aliases of this server must point to room.
+// https://matrix.org/docs/spec/client_server/latest#m-room-canonical-alias
+func (user *User) SetCanonicalAlias(room internal.Room, content events.CanonicalAliasContent) (string, models.ApiError) {
+	if err := user.guestAccessForbidden(); err != nil {
+		return "", err
+	}
+
+	memRoom := room.(*Room)
+
+	if !memRoom.isJoined(user.ID()) {
+		return "", models.NewError(models.M_FORBIDDEN, "you are not a member of room")
+	}
+
+	powerLevels := memRoom.powerLevels()
+	if powerLevels.UserLevel(user.ID()) < powerLevels.EventLevel(events.CanonicalAlias, true) {
+		return "", models.NewError(models.M_FORBIDDEN, "not enough power level to change canonical alias")
+	}
+
+	err := user.backend.validateCanonicalAlias(content, func(localpart string) bool {
+		aliasRoom := user.backend.GetRoomByAlias(localpart)
+		return aliasRoom != nil && aliasRoom.ID() == room.ID()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	newContent, _ := json.Marshal(content)
+	stateEvent := newStateEvent(room, user, events.CanonicalAlias, "", newContent)
+	user.backend.PutEvent(stateEvent)
+
+	memRoom.setAliasName(content.Alias)
+
+	return stateEvent.EventID, nil
+}
+
+// validateCanonicalAlias checks grammar of canonical alias content. Aliases of this server are checked by isRoomAlias function
+// which gets localpart of alias. Aliases of other servers can't be checked without federation.
+func (backend *Backend) validateCanonicalAlias(content events.CanonicalAliasContent, isRoomAlias func(localpart string) bool) models.ApiError {
+	aliases := content.AltAliases
+	if content.Alias != "" {
+		aliases = append([]string{content.Alias}, aliases...)
+	}
+
+	for _, alias := range aliases {
+		localpart, serverName, err := internal.SplitAlias(alias)
+		if err != nil {
+			return models.NewError(models.M_INVALID_PARAM, err.Error())
+		}
+
+		if serverName == backend.hostname && !isRoomAlias(localpart) {
+			return models.NewError(models.M_BAD_ALIAS, fmt.Sprintf("room alias %s doesn't point to room", alias))
+		}
+	}
+
+	return nil
+}
+
+// localAlias checks that alias is valid alias of this server and returns its localpart
+func (backend *Backend) localAlias(alias string) (string, models.ApiError) {
+	localpart, serverName, err := internal.SplitAlias(alias)
+	if err != nil {
+		return "", models.NewError(models.M_INVALID_PARAM, err.Error())
+	}
+
+	if serverName != backend.hostname {
+		return "", models.NewError(models.M_INVALID_PARAM, "room alias must belong to server "+backend.hostname)
+	}
+
+	return localpart, nil
+}
+
+// addAlias maps alias localpart to room. Backend mutex must be held.
+func (backend *Backend) addAlias(localpart string, room internal.Room) {
+	backend.roomAliases[localpart] = room
+	backend.aliasesByRoom[room.ID()] = append(backend.aliasesByRoom[room.ID()], localpart)
+	backend.directoryVersion++
+}
+
+// removeAlias removes alias mapping. Backend mutex must be held.
+func (backend *Backend) removeAlias(localpart string) {
+	room, exists := backend.roomAliases[localpart]
+	if !exists {
+		return
+	}
+
+	delete(backend.roomAliases, localpart)
+	backend.directoryVersion++
+
+	aliases := backend.aliasesByRoom[room.ID()]
+	for i, alias := range aliases {
+		if alias == localpart {
+			backend.aliasesByRoom[room.ID()] = append(aliases[:i:i], aliases[i+1:]...)
+			break
+		}
+	}
+}
+
+// setAliasName updates alias name of room from canonical alias. Alias name is empty if canonical alias isn't local.
+func (room *Room) setAliasName(canonicalAlias string) {
+	localpart, serverName, err := internal.SplitAlias(canonicalAlias)
+	if err != nil || serverName != room.server.hostname {
+		localpart = ""
+	}
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+
+	room.aliasName = localpart
+}
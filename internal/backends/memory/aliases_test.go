@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+func TestRoomAliases(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{RoomAliasName: "room1", Name: "room1"})
+	assert.NoError(t, err)
+
+	for _, alias := range []string{"#alias1:localhost", "#alias2:localhost"} {
+		assert.NoError(t, user1.AddRoomAlias(room, alias))
+	}
+
+	// Aliases are listed in order of creation with alias requested on room creation first
+	expectedAliases := []string{"#room1:localhost", "#alias1:localhost", "#alias2:localhost"}
+	assert.Equal(t, expectedAliases, room.Aliases())
+
+	aliases, err := user1.RoomAliases(room)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedAliases, aliases)
+
+	assert.Error(t, user1.AddRoomAlias(room, "#alias1:localhost"))
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), user1.AddRoomAlias(room, "alias3").Code())
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), user1.AddRoomAlias(room, "#alias3:example.com").Code())
+
+	_, err = user1.CreateRoom(createroom.Request{RoomAliasName: "bad alias"})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	// Users who can't see room can't list its aliases
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+	_, err = user2.RoomAliases(room)
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+}
+
+func TestCreateRoomWithAliasConcurrently(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	const count = 10
+	var (
+		wg      sync.WaitGroup
+		created = make(chan string, count)
+		errs    = make(chan models.ApiError, count)
+	)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			room, err := user1.CreateRoom(createroom.Request{RoomAliasName: "room1"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			created <- room.ID()
+		}()
+	}
+	wg.Wait()
+	close(created)
+	close(errs)
+
+	// Only one room takes alias, others fail before any event is stored
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, <-created, backend.GetRoomByAlias("room1").ID())
+	}
+	for err := range errs {
+		assert.Equal(t, models.M_ROOM_IN_USE.Code(), err.Code())
+	}
+
+	backend.mutex.RLock()
+	assert.Len(t, backend.rooms, 1)
+	assert.Len(t, backend.roomStates, 1)
+	backend.mutex.RUnlock()
+}
+
+func TestDeleteRoomAlias(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{RoomAliasName: "room1", Invite: []string{user2.ID()}, Preset: createroom.TrustedPrivateChat})
+	assert.NoError(t, err)
+	assert.NoError(t, user1.AddRoomAlias(room, "#alias1:localhost"))
+	assert.NoError(t, user2.JoinRoom(room))
+
+	user3, _, err := backend.Register("user3", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.M_FORBIDDEN.Code(), user3.DeleteRoomAlias("#alias1:localhost").Code())
+
+	// Invitee of trusted private chat has enough power level to delete aliases
+	assert.NoError(t, user2.DeleteRoomAlias("#alias1:localhost"))
+	assert.Nil(t, backend.GetRoomByAlias("#alias1:localhost"))
+	assert.Equal(t, models.M_NOT_FOUND.Code(), user1.DeleteRoomAlias("#alias1:localhost").Code())
+
+	// Canonical alias is removed together with alias
+	assert.NoError(t, user1.DeleteRoomAlias("#room1:localhost"))
+	assert.Empty(t, room.Aliases())
+	assert.Empty(t, room.AliasName())
+	content, err := user1.CanonicalAlias(room)
+	assert.NoError(t, err)
+	assert.Empty(t, content.Alias)
+
+	// Alias can be reused after deletion
+	_, err = user1.CreateRoom(createroom.Request{RoomAliasName: "room1"})
+	assert.NoError(t, err)
+}
+
+func TestSetCanonicalAlias(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+	assert.NoError(t, user1.AddRoomAlias(room, "#alias1:localhost"))
+	assert.NoError(t, user1.AddRoomAlias(room, "#alias2:localhost"))
+	assert.NoError(t, user2.JoinRoom(room))
+
+	anotherRoom, err := user1.CreateRoom(createroom.Request{RoomAliasName: "another"})
+	assert.NoError(t, err)
+	assert.NotNil(t, anotherRoom)
+
+	tests := []struct {
+		content events.CanonicalAliasContent
+		err     models.ApiError
+	}{
+		{events.CanonicalAliasContent{Alias: "#alias1:localhost", AltAliases: []string{"#alias2:localhost", "#remote:example.com"}}, nil},
+		{events.CanonicalAliasContent{AltAliases: []string{"#alias2:localhost"}}, nil},
+		{events.CanonicalAliasContent{Alias: "#unknown:localhost"}, models.M_BAD_ALIAS},
+		{events.CanonicalAliasContent{AltAliases: []string{"#another:localhost"}}, models.M_BAD_ALIAS},
+		{events.CanonicalAliasContent{Alias: "alias1"}, models.M_INVALID_PARAM}}
+
+	for _, test := range tests {
+		_, err := user1.SetCanonicalAlias(room, test.content)
+		if test.err == nil {
+			assert.NoError(t, err)
+			content, err := user1.CanonicalAlias(room)
+			assert.NoError(t, err)
+			assert.Equal(t, test.content, *content)
+			continue
+		}
+
+		assert.Equal(t, test.err.Code(), err.Code())
+	}
+
+	_, err = user1.SetCanonicalAlias(room, events.CanonicalAliasContent{Alias: "#alias1:localhost"})
+	assert.NoError(t, err)
+	assert.Equal(t, "alias1", room.AliasName())
+
+	// Regular member can't change canonical alias
+	_, err = user2.SetCanonicalAlias(room, events.CanonicalAliasContent{Alias: "#alias2:localhost"})
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+}
@@ -3,32 +3,53 @@ package memory
 import (
 	"encoding/json"
 	"fmt"
-	"regexp"
-	"sort"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/signaller-matrix/signaller/internal"
 	"github.com/signaller-matrix/signaller/internal/models"
-	"github.com/signaller-matrix/signaller/internal/models/common"
-	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/admin"
 	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+	"github.com/signaller-matrix/signaller/internal/pushgateway"
 	"github.com/tidwall/buntdb"
 )
 
 type Backend struct {
 	data                 map[string]internal.User
 	rooms                map[string]internal.Room
+	roomStates           map[string]*roomStateIndex // room ID -> state of room
+	userRooms            map[string]map[string]bool // user ID -> IDs of rooms which user has been invited to, joined or left
 	events               *buntdb.DB
-	roomAliases          map[string]internal.Room
+	roomAliases          map[string]internal.Room // localpart of alias -> room
+	aliasesByRoom        map[string][]string      // room ID -> localparts of room aliases in order of creation
 	hostname             string
-	validateUsernameFunc func(string) error // TODO: create ability to redefine validation func
+	validateUsernameFunc func(string) models.ApiError
+	pushWorker           *pushgateway.Worker
+	streamPosition       int64
+	searchIndex          *searchIndex
+	newEvents            chan struct{} // closed when new event is stored
+	directory            directoryIndex
+	directoryVersion     int64         // incremented when public room directory is changed
+	tokenLifetime        time.Duration // lifetime of new access tokens, zero for tokens which never expire
+	registrationTokens   map[string]*admin.RegistrationToken
+	loginTokens          map[string]loginToken
+	threePIDs            map[string]string // medium and address of third party identifier -> username
+	ssoSubjects          map[string]string // issuer and subject of SSO user -> username
+	accessTokens         map[string]*User  // access token -> user, so user is found without scan of all users
 	mutex                sync.RWMutex
 }
 
 type Token struct {
-	Device string
+	Device      string
+	DisplayName string    // display name of device
+	Expires     time.Time // zero if token never expires
+}
+
+// expired reports whether token can't be used anymore
+func (token Token) expired() bool {
+	return !token.Expires.IsZero() && time.Now().After(token.Expires)
 }
 
 func NewBackend(hostname string) *Backend {
@@ -36,46 +57,83 @@ func NewBackend(hostname string) *Backend {
 	if err != nil {
 		panic(err)
 	}
-	eventDB.CreateIndex("origin_server_ts", "*", buntdb.IndexJSON("origin_server_ts"))
-	eventDB.CreateIndex("room_id", "*", buntdb.IndexJSON("room_id"))
-	return &Backend{
+	eventDB.CreateIndex("position", "*", buntdb.IndexJSON("position"))
+	eventDB.CreateIndex("room_position", "*", buntdb.IndexJSONCaseSensitive("room_id"), buntdb.IndexJSON("position"))
+	backend := &Backend{
 		hostname:             hostname,
-		validateUsernameFunc: defaultValidationUsernameFunc,
+		validateUsernameFunc: internal.DefaultUsernamePolicy(hostname).Validate,
 		rooms:                make(map[string]internal.Room),
+		roomStates:           make(map[string]*roomStateIndex),
+		userRooms:            make(map[string]map[string]bool),
 		roomAliases:          make(map[string]internal.Room),
+		aliasesByRoom:        make(map[string][]string),
+		registrationTokens:   make(map[string]*admin.RegistrationToken),
+		loginTokens:          make(map[string]loginToken),
+		threePIDs:            make(map[string]string),
+		ssoSubjects:          make(map[string]string),
+		accessTokens:         make(map[string]*User),
 		events:               eventDB,
+		searchIndex:          newSearchIndex(),
+		newEvents:            make(chan struct{}),
 		data:                 make(map[string]internal.User)}
+
+	backend.pushWorker = pushgateway.NewWorker(backend.disablePusher)
+	backend.pushWorker.Start()
+
+	return backend
+}
+
+// Close stops delivery of push notifications and releases storage of events, backend can't be used after it
+func (backend *Backend) Close() error {
+	backend.pushWorker.Stop()
+
+	return backend.events.Close()
 }
 
 func (backend *Backend) Register(username, password, device string) (user internal.User, token string, err models.ApiError) {
+	if _, err := backend.CreateUser(username, password); err != nil {
+		return nil, "", err
+	}
+
+	return backend.Login(username, password, device)
+}
+
+// CreateUser registers user without logging in. Username is normalized and validated with username validation func.
+func (backend *Backend) CreateUser(username, password string) (internal.User, models.ApiError) {
+	username = internal.NormalizeUsername(username)
+
 	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	user, err := backend.createUser(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
 
+// createUser registers user with normalized username, backend must be locked
+func (backend *Backend) createUser(username, password string) (*User, models.ApiError) {
 	if backend.validateUsernameFunc != nil {
-		err := backend.validateUsernameFunc(username)
-		if err != nil {
-			return nil, "", models.NewError(models.M_INVALID_USERNAME, err.Error())
+		if err := backend.validateUsernameFunc(username); err != nil {
+			return nil, err
 		}
 	}
 
 	if _, ok := backend.data[username]; ok {
-		backend.mutex.Unlock()
-		return nil, "", models.NewError(models.M_USER_IN_USE, "trying to register a user ID which has been taken")
+		return nil, models.NewError(models.M_USER_IN_USE, "trying to register a user ID which has been taken")
 	}
 
-	user = &User{
-		name:     username,
-		password: password,
-		Tokens:   make(map[string]Token),
-		backend:  backend,
-		filters:  make(map[string]common.Filter)}
-
+	user := newUser(backend, username, password)
 	backend.data[username] = user
 
-	backend.mutex.Unlock()
-	return backend.Login(username, password, device)
+	return user, nil
 }
 
 func (backend *Backend) Login(username, password, device string) (user internal.User, token string, err models.ApiError) {
+	username = internal.NormalizeUsername(username)
+
 	backend.mutex.Lock()
 	defer backend.mutex.Unlock()
 
@@ -84,13 +142,15 @@ func (backend *Backend) Login(username, password, device string) (user internal.
 		return nil, "", models.NewError(models.M_FORBIDDEN, "wrong username")
 	}
 
+	if user.(*User).IsGuest() {
+		return nil, "", models.NewError(models.M_FORBIDDEN, "guest users can't log in")
+	}
+
 	if user.Password() != password {
 		return nil, "", models.NewError(models.M_FORBIDDEN, "wrong password")
 	}
 
-	token = internal.RandomString(defaultTokenSize)
-
-	backend.data[username].(*User).Tokens[token] = Token{Device: device}
+	token = backend.issueToken(user.(*User), device)
 
 	return user, token, nil
 }
@@ -99,15 +159,47 @@ func (backend *Backend) GetUserByToken(token string) internal.User {
 	backend.mutex.RLock()
 	defer backend.mutex.RUnlock()
 
-	for _, user := range backend.data {
-		for userToken := range user.(*User).Tokens {
-			if userToken == token {
-				return user
-			}
+	user, exists := backend.accessTokens[token]
+	if !exists || user.Tokens[token].expired() {
+		return nil
+	}
+
+	return user
+}
+
+// SetAccessTokenLifetime sets lifetime of access tokens which will be issued. Already issued tokens are not affected.
+func (backend *Backend) SetAccessTokenLifetime(lifetime time.Duration) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	backend.tokenLifetime = lifetime
+}
+
+// newToken returns token info for device of user with expiration time according to token lifetime.
+// Display name of known device is kept. Backend mutex must be held.
+func (backend *Backend) newToken(user *User, device string) Token {
+	token := Token{Device: device}
+	if backend.tokenLifetime > 0 {
+		token.Expires = time.Now().Add(backend.tokenLifetime)
+	}
+
+	for _, userToken := range user.Tokens {
+		if userToken.Device == device {
+			token.DisplayName = userToken.DisplayName
+			break
 		}
 	}
 
-	return nil
+	return token
+}
+
+// issueToken returns new access token for device of user and adds it to index of tokens. Backend mutex must be held.
+func (backend *Backend) issueToken(user *User, device string) string {
+	token := internal.RandomString(defaultTokenSize)
+	user.Tokens[token] = backend.newToken(user, device)
+	backend.accessTokens[token] = user
+
+	return token
 }
 
 func (backend *Backend) GetRoomByID(id string) internal.Room {
@@ -134,26 +226,6 @@ func (backend *Backend) GetUserByName(userName string) internal.User {
 	return nil
 }
 
-func (backend *Backend) PublicRooms(filter string) []internal.Room {
-	backend.mutex.RLock()
-	defer backend.mutex.RUnlock()
-
-	var rooms []internal.Room
-
-	for _, room := range backend.rooms {
-		if room.State() == createroom.PublicChat &&
-			(strings.Contains(room.Name(), filter) ||
-				strings.Contains(room.Topic(), filter) ||
-				strings.Contains(room.AliasName(), filter)) {
-			rooms = append(rooms, room)
-		}
-	}
-
-	sort.Sort(BySize(rooms))
-
-	return rooms
-}
-
 func (backend *Backend) GetRoomByAlias(alias string) internal.Room {
 	backend.mutex.RLock()
 	defer backend.mutex.RUnlock()
@@ -167,97 +239,250 @@ func (backend *Backend) GetRoomByAlias(alias string) internal.Room {
 	return nil
 }
 
-func (backend *Backend) ValidateUsernameFunc() func(string) error {
+func (backend *Backend) ValidateUsernameFunc() func(string) models.ApiError {
 	backend.mutex.RLock()
 	defer backend.mutex.RUnlock()
 
 	return backend.validateUsernameFunc
 }
 
-func defaultValidationUsernameFunc(userName string) error {
-	const re = `^\w{5,}$`
-
-	if !regexp.MustCompile(re).MatchString(userName) {
-		return fmt.Errorf("username does not match %s", re)
-	}
+// SetValidateUsernameFunc replaces func which validates normalized usernames of new users, nil disables validation
+func (backend *Backend) SetValidateUsernameFunc(validateUsernameFunc func(string) models.ApiError) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
 
-	return nil
+	backend.validateUsernameFunc = validateUsernameFunc
 }
 
 func (backend *Backend) GetEventByID(id string) events.Event {
-	backend.mutex.RLock()
-	defer backend.mutex.RUnlock()
-
-	var event events.Event
+	stored, exists := backend.storedEvent(id)
+	if !exists {
+		return nil
+	}
 
-	backend.events.View(func(tx *buntdb.Tx) error {
-		val, err := tx.Get(id, true)
-		if err == nil {
-			json.Unmarshal([]byte(val), event)
-		}
+	event, err := stored.decode()
+	if err != nil {
 		return nil
-	})
+	}
+
 	return event
 }
 
 func (backend *Backend) PutEvent(event events.Event) error {
-	backend.mutex.Lock()
-	defer backend.mutex.Unlock()
+	// events are looked up by IDs, so events without IDs can't be stored
+	if event.ID() == "" {
+		return fmt.Errorf("event of type %s has no ID", event.Type())
+	}
 
-	marshalledEvent, err := json.Marshal(event)
+	stored, err := newStoredEvent(event)
 	if err != nil {
 		return err
 	}
 
-	err = backend.events.Update(func(tx *buntdb.Tx) error {
-		_, _, err := tx.Set(event.ID(), string(marshalledEvent), nil)
-		return err
-	})
+	backend.mutex.Lock()
+	backend.streamPosition++
+	stored.Position = backend.streamPosition
+
+	marshalledStoredEvent, err := json.Marshal(stored)
+	if err == nil {
+		err = backend.events.Update(func(tx *buntdb.Tx) error {
+			_, _, err := tx.Set(event.ID(), string(marshalledStoredEvent), nil)
+			return err
+		})
+	}
+	if err == nil {
+		backend.indexState(event, stored.Position)
+		backend.notifyWaiters()
+	}
+	backend.mutex.Unlock()
 
 	if err != nil {
 		return err
 	}
 
+	if roomEvent, ok := event.(*events.RoomEvent); ok {
+		backend.searchIndex.add(roomEvent, stored.Position)
+		backend.evaluatePushRules(roomEvent)
+		backend.updateDirectoryListing(roomEvent)
+		backend.invalidateDirectory(roomEvent)
+	}
+
 	return nil
 }
 
 func (backend *Backend) GetEventsSince(user internal.User, sinceToken string, limit int) []events.Event {
-	if sinceToken != "" {
-		var sinceEvent events.Event
-		var eventSlice []events.Event
-		err := backend.events.View(func(tx *buntdb.Tx) error {
-			// handler error
-			val, err := tx.Get(sinceToken, true)
-			if err == nil {
-				json.Unmarshal([]byte(val), sinceEvent)
-			}
-			sinceRoomEvent := sinceEvent.(*events.RoomEvent)
-			tx.AscendRange("origin_server_ts", `{"origin_server_ts": `+string(sinceRoomEvent.OriginServerTs)+`}`, `{"origin_server_ts": `+string(time.Now().Unix())+`}`, func(key, value string) bool {
-				var unmarshalledEvent events.Event
-				json.Unmarshal([]byte(value), unmarshalledEvent)
-				eventSlice = append(eventSlice, unmarshalledEvent)
+	if sinceToken == "" {
+		return nil
+	}
+
+	since, exists := backend.storedEvent(sinceToken)
+	if !exists {
+		return nil
+	}
+
+	var eventSlice []events.Event
+	backend.events.View(func(tx *buntdb.Tx) error {
+		return tx.AscendGreaterOrEqual("position", `{"position":`+strconv.FormatInt(since.Position+1, 10)+`}`, func(key, value string) bool {
+			var stored storedEvent
+			if json.Unmarshal([]byte(value), &stored) != nil {
 				return true
-			})
-			return err
+			}
+
+			if roomEvent, err := stored.roomEvent(); err == nil {
+				eventSlice = append(eventSlice, roomEvent)
+			}
+
+			return limit <= 0 || len(eventSlice) < limit
 		})
+	})
+
+	var returnEvents []events.Event
+	for _, event := range eventSlice {
+		if isEventRelatedToUser(event, user) {
+			returnEvents = append(returnEvents, event)
+		}
+	}
 
+	return returnEvents
+}
+
+const (
+	eventKindRoom        = "room"         // events.RoomEvent without state key
+	eventKindState       = "state"        // events.RoomEvent with state key
+	eventKindAccountData = "account_data" // events.AccountDataEvent
+)
+
+// storedEvent is event with its kind and position in stream, as it is kept in events database
+type storedEvent struct {
+	Kind     string          `json:"kind"`
+	Position int64           `json:"position"`
+	RoomID   string          `json:"room_id,omitempty"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// newStoredEvent returns stored event of event without position
+func newStoredEvent(event events.Event) (storedEvent, error) {
+	kind, err := eventKind(event)
+	if err != nil {
+		return storedEvent{}, err
+	}
+
+	marshalledEvent, err := json.Marshal(event)
+	if err != nil {
+		return storedEvent{}, err
+	}
+
+	stored := storedEvent{Kind: kind, Event: marshalledEvent}
+	if roomEvent, ok := event.(*events.RoomEvent); ok {
+		stored.RoomID = roomEvent.RoomID
+	}
+
+	return stored, nil
+}
+
+// eventKind returns kind of event which is used to decode stored event to the same type
+func eventKind(event events.Event) (string, error) {
+	switch e := event.(type) {
+	case *events.RoomEvent:
+		if e.IsState() {
+			return eventKindState, nil
+		}
+		return eventKindRoom, nil
+	case *events.AccountDataEvent:
+		return eventKindAccountData, nil
+	}
+
+	return "", fmt.Errorf("unsupported event type %T", event)
+}
+
+// decode decodes stored event to type of event which was stored
+func (stored *storedEvent) decode() (events.Event, error) {
+	switch stored.Kind {
+	case eventKindRoom, eventKindState:
+		return stored.roomEvent()
+	case eventKindAccountData:
+		accountDataEvent := new(events.AccountDataEvent)
+		err := json.Unmarshal(stored.Event, accountDataEvent)
+
+		return accountDataEvent, err
+	}
+
+	return nil, fmt.Errorf("unknown event kind %q", stored.Kind)
+}
+
+// roomEvent decodes stored event of room or state kind
+func (stored *storedEvent) roomEvent() (*events.RoomEvent, error) {
+	if stored.Kind != eventKindRoom && stored.Kind != eventKindState {
+		return nil, fmt.Errorf("event of kind %q is not room event", stored.Kind)
+	}
+
+	roomEvent := new(events.RoomEvent)
+	err := json.Unmarshal(stored.Event, roomEvent)
+
+	return roomEvent, err
+}
+
+// storedEvent returns event with its position in stream
+func (backend *Backend) storedEvent(eventID string) (stored storedEvent, exists bool) {
+	backend.events.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(eventID)
 		if err != nil {
-			panic(err)
+			return err
+		}
+
+		exists = json.Unmarshal([]byte(value), &stored) == nil
+		return nil
+	})
+
+	return stored, exists
+}
+
+// roomEvents returns up to limit events of room in stream order starting from position (exclusive).
+// If backwards is true events are returned in reverse order. Events which don't match filter
+// or can't be seen according to visibility checker (including events of ignored users) are skipped.
+func (backend *Backend) roomEvents(roomID string, from int64, limit int, backwards bool, eventFilter *filter.RoomEventFilter, checker *visibilityChecker) []storedEvent {
+	var result []storedEvent
+
+	pivot := `{"room_id":` + strconv.Quote(roomID) + `,"position":` + strconv.FormatInt(from, 10) + `}`
+
+	iterator := func(key, value string) bool {
+		var stored storedEvent
+		if json.Unmarshal([]byte(value), &stored) != nil {
+			return true
+		}
+
+		if stored.RoomID != roomID {
+			return false
+		}
+
+		if stored.Position == from {
+			return true
 		}
 
-		var returnEvents []events.Event
-		if eventSlice != nil {
-			for _, event := range eventSlice {
-				if isEventRelatedToUser(event, user) {
-					returnEvents = append(returnEvents, event)
-				}
+		if checker != nil && !checker.canSee(stored.Position) {
+			return true
+		}
+
+		if eventFilter != nil || checker.ignoresSomebody() {
+			event, err := stored.roomEvent()
+			if err != nil || (eventFilter != nil && !eventFilter.Match(event)) || checker.isIgnored(event) {
+				return true
 			}
 		}
 
-		return returnEvents
+		result = append(result, stored)
+
+		return limit <= 0 || len(result) < limit
 	}
 
-	return nil
+	backend.events.View(func(tx *buntdb.Tx) error {
+		if backwards {
+			return tx.DescendLessOrEqual("room_position", pivot, iterator)
+		}
+		return tx.AscendGreaterOrEqual("room_position", pivot, iterator)
+	})
+
+	return result
 }
 
 func isEventRelatedToUser(event events.Event, user internal.User) bool {
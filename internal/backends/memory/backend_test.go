@@ -1,15 +1,31 @@
 package memory
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/signaller-matrix/signaller/internal/models"
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/publicrooms"
 )
 
-func TestRegisterUser(t *testing.T) {
+// newTestBackend returns backend which is closed when test finishes
+func newTestBackend(t *testing.T) *Backend {
 	backend := NewBackend("localhost")
+	t.Cleanup(func() {
+		backend.Close()
+	})
+
+	return backend
+}
+
+func TestRegisterUser(t *testing.T) {
+	backend := newTestBackend(t)
 
 	var (
 		username = "username1"
@@ -25,7 +41,7 @@ func TestRegisterUser(t *testing.T) {
 }
 
 func TestRegisterUserWithAlreadyTakenName(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "username1"
@@ -39,7 +55,7 @@ func TestRegisterUserWithAlreadyTakenName(t *testing.T) {
 }
 
 func TestLogin(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "username1"
@@ -55,7 +71,7 @@ func TestLogin(t *testing.T) {
 }
 
 func TestLoginWithWrongCredentials(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "username1"
@@ -73,7 +89,7 @@ func TestLoginWithWrongCredentials(t *testing.T) {
 }
 
 func TestLogout(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "username1"
@@ -90,10 +106,45 @@ func TestLogout(t *testing.T) {
 	user.Logout(token)
 
 	assert.Nil(t, backend.GetUserByToken(token))
+
+	_, token1, err := backend.Login(userName, password, "")
+	assert.NoError(t, err)
+	_, token2, err := backend.Login(userName, password, "")
+	assert.NoError(t, err)
+	assert.Equal(t, user, backend.GetUserByToken(token1))
+
+	user.LogoutAll()
+
+	assert.Nil(t, backend.GetUserByToken(token1))
+	assert.Nil(t, backend.GetUserByToken(token2))
+}
+
+func TestAccessTokenLifetime(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, token, err := backend.Register("username1", "password1", "")
+	assert.NoError(t, err)
+
+	backend.SetAccessTokenLifetime(time.Hour)
+	_, expiringToken, err := backend.Login("username1", "password1", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, user, backend.GetUserByToken(token))
+	assert.Equal(t, user, backend.GetUserByToken(expiringToken))
+
+	// Token lifetime doesn't affect already issued tokens
+	backend.mutex.Lock()
+	tokenInfo := user.(*User).Tokens[expiringToken]
+	tokenInfo.Expires = time.Now().Add(-time.Second)
+	user.(*User).Tokens[expiringToken] = tokenInfo
+	backend.mutex.Unlock()
+
+	assert.Equal(t, user, backend.GetUserByToken(token))
+	assert.Nil(t, backend.GetUserByToken(expiringToken))
 }
 
 func TestGetRoomByID(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, token, err := backend.Register("username", "", "")
 	assert.NoError(t, err)
@@ -115,7 +166,7 @@ func TestGetRoomByID(t *testing.T) {
 }
 
 func TestGetUserByName(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "username"
@@ -138,7 +189,7 @@ func TestGetUserByName(t *testing.T) {
 }
 
 func TestPublicRooms(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	username1, _, err := backend.Register("username1", "", "")
 	assert.NoError(t, err)
@@ -148,7 +199,7 @@ func TestPublicRooms(t *testing.T) {
 	request := createroom.Request{
 		RoomAliasName: "room1",
 		Name:          "room1",
-		Preset:        createroom.PublicChat}
+		Visibility:    createroom.VisibilityTypePublic}
 
 	room1, err := username1.CreateRoom(request)
 	assert.NoError(t, err)
@@ -158,7 +209,7 @@ func TestPublicRooms(t *testing.T) {
 	request = createroom.Request{
 		RoomAliasName: "room2",
 		Name:          "room2",
-		Preset:        createroom.PublicChat}
+		Visibility:    createroom.VisibilityTypePublic}
 
 	room2, err := username1.CreateRoom(request)
 	assert.NoError(t, err)
@@ -172,14 +223,15 @@ func TestPublicRooms(t *testing.T) {
 	err = user2.JoinRoom(room2)
 	assert.NoError(t, err)
 
-	rooms := backend.PublicRooms("")
-	assert.Len(t, rooms, 2)
-	assert.Equal(t, rooms[0], room2)
-	assert.Equal(t, rooms[1], room1)
+	response, err := backend.PublicRooms(publicrooms.Request{})
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 2)
+	assert.Equal(t, room2.ID(), response.Chunk[0].RoomID)
+	assert.Equal(t, room1.ID(), response.Chunk[1].RoomID)
 }
 
 func TestNewUserNameValidate(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var shortName = "u1"
 
@@ -188,3 +240,119 @@ func TestNewUserNameValidate(t *testing.T) {
 	assert.Nil(t, user)
 	assert.Empty(t, token)
 }
+
+func TestUsernameNormalization(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("UserName1", "password1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "@username1:localhost", user.ID())
+
+	_, _, err = backend.Register("username1", "password1", "")
+	assert.Equal(t, models.M_USER_IN_USE.Code(), err.Code())
+
+	loggedIn, _, err := backend.Login("USERNAME1", "password1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, user, loggedIn)
+}
+
+func TestSetValidateUsernameFunc(t *testing.T) {
+	backend := newTestBackend(t)
+
+	backend.SetValidateUsernameFunc(func(username string) models.ApiError {
+		if username == "reserved" {
+			return models.NewError(models.M_EXCLUSIVE, "")
+		}
+		return nil
+	})
+
+	_, _, err := backend.Register("reserved", "", "")
+	assert.Equal(t, models.M_EXCLUSIVE.Code(), err.Code())
+
+	_, _, err = backend.Register("u1", "", "")
+	assert.NoError(t, err)
+
+	backend.SetValidateUsernameFunc(nil)
+	_, _, err = backend.Register("reserved", "", "")
+	assert.NoError(t, err)
+}
+
+func TestGetEventByID(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user.SetTopic(room, "topic"))
+	assert.NoError(t, user.SendMessage(room, "message"))
+
+	response, err := user.Messages(room, "", "", messages.DirectionBackward, 2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 2)
+
+	// Room event
+	event := backend.GetEventByID(response.Chunk[0].EventID)
+	assert.IsType(t, &events.RoomEvent{}, event)
+	assert.Equal(t, events.Message, event.Type())
+	assert.False(t, event.(*events.RoomEvent).IsState())
+
+	// State event
+	event = backend.GetEventByID(response.Chunk[1].EventID)
+	assert.Equal(t, events.Topic, event.Type())
+	assert.True(t, event.(*events.RoomEvent).IsState())
+	assert.Equal(t, "", *event.(*events.RoomEvent).StateKey)
+	assert.Equal(t, room.ID(), event.(*events.RoomEvent).RoomID)
+
+	assert.Nil(t, backend.GetEventByID("unknown"))
+}
+
+func TestStoredEventRoundTrip(t *testing.T) {
+	stateKey := ""
+	tests := []struct {
+		kind  string
+		event events.Event
+	}{
+		{eventKindRoom, &events.RoomEvent{
+			ContentData: json.RawMessage(`{"body":"message","msgtype":"m.text"}`),
+			EType:       events.Message,
+			EventID:     "event1",
+			Sender:      "@user1:localhost",
+			RoomID:      "!room1:localhost"}},
+		{eventKindState, &events.RoomEvent{
+			ContentData: json.RawMessage(`{"topic":"topic"}`),
+			EType:       events.Topic,
+			EventID:     "event2",
+			Sender:      "@user1:localhost",
+			RoomID:      "!room1:localhost",
+			StateKey:    &stateKey}},
+		{eventKindAccountData, &events.AccountDataEvent{
+			ContentData: json.RawMessage(`{"ignored_users":{}}`),
+			EType:       events.IgnoredUserList}}}
+
+	for _, test := range tests {
+		stored, err := newStoredEvent(test.event)
+		if !assert.NoError(t, err, test.kind) {
+			continue
+		}
+		assert.Equal(t, test.kind, stored.Kind)
+
+		marshalled, err := json.Marshal(stored)
+		assert.NoError(t, err)
+
+		var unmarshalled storedEvent
+		assert.NoError(t, json.Unmarshal(marshalled, &unmarshalled))
+
+		decoded, err := unmarshalled.decode()
+		assert.NoError(t, err, test.kind)
+		assert.Equal(t, test.event, decoded, test.kind)
+	}
+
+	_, err := (&storedEvent{Kind: "unknown"}).decode()
+	assert.Error(t, err)
+
+	// events without IDs can't be looked up, so they aren't stored
+	backend := newTestBackend(t)
+	assert.Error(t, backend.PutEvent(tests[2].event))
+}
@@ -4,4 +4,5 @@ const (
 	groupIDSize      = 16
 	eventIDSize      = 16
 	defaultTokenSize = 16
+	guestNameSize    = 8
 )
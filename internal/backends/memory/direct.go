@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// addDirectRoom marks room as direct chat with users in m.direct account data
+// https://matrix.org/docs/spec/client_server/latest#direct-messaging
+func (user *User) addDirectRoom(roomID string, userIDs []string) models.ApiError {
+	return user.updateAccountData("", string(events.Direct), func(content json.RawMessage, position int64) (json.RawMessage, models.ApiError) {
+		direct := make(events.DirectContent)
+		if content != nil && json.Unmarshal(content, &direct) != nil {
+			direct = make(events.DirectContent) // broken content set by client is replaced
+		}
+
+		for _, userID := range userIDs {
+			if !inStringSlice(direct[userID], roomID) {
+				direct[userID] = append(direct[userID], roomID)
+			}
+		}
+
+		newContent, err := json.Marshal(direct)
+		if err != nil {
+			return nil, models.NewError(models.M_UNKNOWN, err.Error())
+		}
+
+		return newContent, nil
+	})
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+func TestCreateDirectRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Invite: []string{user2.ID()}, IsDirect: true})
+	assert.NoError(t, err)
+	assert.Equal(t, createroom.TrustedPrivateChat, room.State())
+	assert.Len(t, room.(*Room).invites, 1)
+
+	// Invite member event is marked as direct
+	inviteEvent, exists := backend.currentStateEvent(room.ID(), events.Member, user2.ID())
+	assert.True(t, exists)
+	var content events.EventContent
+	assert.NoError(t, json.Unmarshal(inviteEvent.Content, &content))
+	assert.Equal(t, events.MembershipInvite, content.Membership)
+	assert.True(t, content.IsDirect)
+
+	// Invitee has the same power level as creator
+	assert.Equal(t, 100, room.(*Room).powerLevel(user1.ID()))
+	assert.Equal(t, 100, room.(*Room).powerLevel(user2.ID()))
+
+	direct, err := user1.AccountData(string(events.Direct))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"@user2:localhost":["`+room.ID()+`"]}`, string(direct))
+
+	// Next direct room is added to existing list
+	anotherRoom, err := user1.CreateRoom(createroom.Request{Invite: []string{user2.ID()}, IsDirect: true})
+	assert.NoError(t, err)
+	direct, err = user1.AccountData(string(events.Direct))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"@user2:localhost":["`+room.ID()+`","`+anotherRoom.ID()+`"]}`, string(direct))
+
+	// Unknown invitee
+	_, err = user1.CreateRoom(createroom.Request{Invite: []string{"@unknown:localhost"}, IsDirect: true})
+	assert.Error(t, err)
+}
+
+func TestCreateRoomWithInvites(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Preset: createroom.PrivateChat, Invite: []string{user2.ID()}})
+	assert.NoError(t, err)
+	assert.Len(t, room.(*Room).invites, 1)
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.Equal(t, 0, room.(*Room).powerLevel(user2.ID()))
+
+	_, err = user1.AccountData(string(events.Direct))
+	assert.Error(t, err)
+}
+
+func TestCreateDirectRoomNotification(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Invite: []string{user2.ID()}, IsDirect: true})
+	assert.NoError(t, err)
+
+	// Invite sent with creation of room reaches invitee
+	response, err := user2.Notifications("", 0, "")
+	assert.NoError(t, err)
+	if assert.Len(t, response.Notifications, 1) {
+		assert.Equal(t, room.ID(), response.Notifications[0].RoomID)
+		assert.Equal(t, events.Member, response.Notifications[0].Event.EType)
+		assert.Equal(t, user2.ID(), *response.Notifications[0].Event.StateKey)
+	}
+}
@@ -0,0 +1,310 @@
+package memory
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/publicrooms"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+)
+
+// directoryIndex is cached list of rooms of public room directory sorted by directory order
+type directoryIndex struct {
+	built   bool
+	version int64 // directory version at which index was built
+	entries []publicrooms.PublicRoomsChunk
+
+	mutex sync.Mutex
+}
+
+// directoryToken is pagination token of public room directory. Forward token points to first room of next batch,
+// backward token points to first room of current batch.
+type directoryToken struct {
+	Forward          bool   `json:"f"`
+	NumJoinedMembers int    `json:"n"`
+	RoomID           string `json:"r"`
+}
+
+// PublicRooms returns batch of public room directory of this server
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-publicrooms
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-publicrooms
+func (backend *Backend) PublicRooms(request publicrooms.Request) (*publicrooms.Response, models.ApiError) {
+	if request.Server != "" && request.Server != backend.hostname {
+		return nil, models.NewError(models.M_INVALID_PARAM, "public rooms of other servers are not available")
+	}
+
+	if request.IncludeAllNetworks && request.ThirdPartyInstanceID != "" {
+		return nil, models.NewError(models.M_INVALID_PARAM, "third_party_instance_id can't be used with include_all_networks")
+	}
+
+	// Server has no third party networks, so all networks are the same as the server's own one
+	if request.ThirdPartyInstanceID != "" {
+		return &publicrooms.Response{Chunk: []publicrooms.PublicRoomsChunk{}}, nil
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = publicrooms.DefaultLimit
+	}
+
+	var entries []publicrooms.PublicRoomsChunk
+	for _, entry := range backend.directoryEntries() {
+		if matchDirectoryEntry(entry, request.Filter.GenericSearchTerm) {
+			entries = append(entries, entry)
+		}
+	}
+
+	start, end := 0, limit
+	if request.Since != "" {
+		token, err := decodeDirectoryToken(request.Since)
+		if err != nil {
+			return nil, err
+		}
+
+		pivot := sort.Search(len(entries), func(i int) bool {
+			return !directoryLess(entries[i].NumJoinedMembers, entries[i].RoomID, token.NumJoinedMembers, token.RoomID)
+		})
+
+		if token.Forward {
+			start, end = pivot, pivot+limit
+		} else {
+			start, end = pivot-limit, pivot
+		}
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	response := &publicrooms.Response{
+		Chunk:                  append([]publicrooms.PublicRoomsChunk{}, entries[start:end]...),
+		TotalRoomCountEstimate: len(entries)}
+
+	if end < len(entries) {
+		response.NextBatch = encodeDirectoryToken(directoryToken{
+			Forward:          true,
+			NumJoinedMembers: entries[end].NumJoinedMembers,
+			RoomID:           entries[end].RoomID})
+	}
+
+	if start > 0 {
+		response.PrevBatch = encodeDirectoryToken(directoryToken{
+			Forward:          false,
+			NumJoinedMembers: entries[start].NumJoinedMembers,
+			RoomID:           entries[start].RoomID})
+	}
+
+	return response, nil
+}
+
+// directoryEntries returns sorted public room directory. Directory is rebuilt when directory version is changed.
+func (backend *Backend) directoryEntries() []publicrooms.PublicRoomsChunk {
+	backend.directory.mutex.Lock()
+	defer backend.directory.mutex.Unlock()
+
+	backend.mutex.RLock()
+	version := backend.directoryVersion
+	var rooms []internal.Room
+	for _, room := range backend.rooms {
+		rooms = append(rooms, room)
+	}
+	backend.mutex.RUnlock()
+
+	if backend.directory.built && backend.directory.version == version {
+		return backend.directory.entries
+	}
+
+	var entries []publicrooms.PublicRoomsChunk
+	for _, room := range rooms {
+		if room.Visibility() == createroom.VisibilityTypePublic {
+			entries = append(entries, backend.directoryEntry(room))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return directoryLess(entries[i].NumJoinedMembers, entries[i].RoomID, entries[j].NumJoinedMembers, entries[j].RoomID)
+	})
+
+	backend.directory.built = true
+	backend.directory.version = version
+	backend.directory.entries = entries
+
+	return entries
+}
+
+// SetRoomVisibility publishes room in public room directory or removes it from there. Users who can change
+// canonical alias of room are allowed to do it. Only joinable rooms which aren't replaced can be published.
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-directory-list-room-roomid
+func (user *User) SetRoomVisibility(room internal.Room, visibilityType createroom.VisibilityType) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	if visibilityType != createroom.VisibilityTypePublic && visibilityType != createroom.VisibilityTypePrivate {
+		return models.NewError(models.M_INVALID_PARAM, "unknown visibility "+string(visibilityType))
+	}
+
+	memRoom := room.(*Room)
+
+	powerLevels := memRoom.powerLevels()
+	if powerLevels.UserLevel(user.ID()) < powerLevels.EventLevel(events.CanonicalAlias, true) {
+		return models.NewError(models.M_FORBIDDEN, "not enough power level to change room visibility")
+	}
+
+	if visibilityType == createroom.VisibilityTypePublic && !user.backend.isListable(room.ID()) {
+		return models.NewError(models.M_BAD_STATE, "only joinable rooms can be published")
+	}
+
+	memRoom.setVisibility(visibilityType)
+
+	return nil
+}
+
+// isListable checks that room can be published in public room directory: anyone can join room and room isn't replaced
+func (backend *Backend) isListable(roomID string) bool {
+	if _, replaced := backend.currentStateEvent(roomID, events.Tombstone, ""); replaced {
+		return false
+	}
+
+	stateEvent, exists := backend.currentStateEvent(roomID, events.JoinRules, "")
+	if !exists {
+		return false
+	}
+
+	var content struct {
+		JoinRule rooms.JoinRule `json:"join_rule"`
+	}
+
+	return json.Unmarshal(stateEvent.Content, &content) == nil && content.JoinRule == rooms.Public
+}
+
+// updateDirectoryListing delists room when join rules or tombstone event makes it unlistable
+func (backend *Backend) updateDirectoryListing(event *events.RoomEvent) {
+	if !event.IsState() || event.EType != events.JoinRules && event.EType != events.Tombstone {
+		return
+	}
+
+	if room, ok := backend.GetRoomByID(event.RoomID).(*Room); ok {
+		backend.delistUnlistable(room)
+	}
+}
+
+// directoryEventTypes are types of state events which change entries of public room directory
+var directoryEventTypes = map[events.EventType]bool{
+	events.Name:              true,
+	events.Topic:             true,
+	events.Avatar:            true,
+	events.CanonicalAlias:    true,
+	events.JoinRules:         true,
+	events.HistoryVisibility: true,
+	events.GuestAccess:       true,
+	events.Member:            true}
+
+// invalidateDirectory increments directory version when state event changes entry of published room
+func (backend *Backend) invalidateDirectory(event *events.RoomEvent) {
+	if !event.IsState() || !directoryEventTypes[event.EType] {
+		return
+	}
+
+	room := backend.GetRoomByID(event.RoomID)
+	if room == nil || room.Visibility() != createroom.VisibilityTypePublic {
+		return
+	}
+
+	backend.mutex.Lock()
+	backend.directoryVersion++
+	backend.mutex.Unlock()
+}
+
+// delistUnlistable removes published room from public room directory if room can't be listed
+func (backend *Backend) delistUnlistable(room *Room) {
+	if room.Visibility() == createroom.VisibilityTypePublic && !backend.isListable(room.ID()) {
+		room.setVisibility(createroom.VisibilityTypePrivate)
+	}
+}
+
+// setVisibility changes visibility of room in public room directory
+func (room *Room) setVisibility(visibilityType createroom.VisibilityType) {
+	room.mutex.Lock()
+	room.visibility = visibilityType
+	room.mutex.Unlock()
+
+	room.server.mutex.Lock()
+	room.server.directoryVersion++
+	room.server.mutex.Unlock()
+}
+
+// directoryEntry returns public room directory entry of room
+func (backend *Backend) directoryEntry(room internal.Room) publicrooms.PublicRoomsChunk {
+	entry := publicrooms.PublicRoomsChunk{
+		Aliases:          room.Aliases(),
+		Name:             room.Name(),
+		NumJoinedMembers: len(room.Users()),
+		RoomID:           room.ID(),
+		Topic:            room.Topic(),
+		WorldReadable:    room.WorldReadable(),
+		GuestCanJoin:     room.GuestCanJoin(),
+		AvatarURL:        room.AvatarURL()}
+
+	if stateEvent, exists := backend.currentStateEvent(room.ID(), events.CanonicalAlias, ""); exists {
+		var content events.CanonicalAliasContent
+		if json.Unmarshal(stateEvent.Content, &content) == nil {
+			entry.CanonicalAlias = content.Alias
+		}
+	}
+
+	return entry
+}
+
+// directoryLess defines order of public room directory: rooms with more members go first, ties are ordered by room ID
+func directoryLess(members1 int, roomID1 string, members2 int, roomID2 string) bool {
+	if members1 != members2 {
+		return members1 > members2
+	}
+
+	return roomID1 < roomID2
+}
+
+// matchDirectoryEntry checks case-insensitively that name, topic or aliases of room contain search term
+func matchDirectoryEntry(entry publicrooms.PublicRoomsChunk, searchTerm string) bool {
+	if searchTerm == "" {
+		return true
+	}
+
+	searchTerm = strings.ToLower(searchTerm)
+
+	fields := append([]string{entry.Name, entry.Topic, entry.CanonicalAlias}, entry.Aliases...)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), searchTerm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeDirectoryToken(token directoryToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeDirectoryToken(since string) (directoryToken, models.ApiError) {
+	var token directoryToken
+
+	data, err := base64.RawURLEncoding.DecodeString(since)
+	if err != nil || json.Unmarshal(data, &token) != nil || token.RoomID == "" {
+		return token, models.NewError(models.M_INVALID_PARAM, "invalid pagination token")
+	}
+
+	return token, nil
+}
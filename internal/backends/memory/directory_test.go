@@ -0,0 +1,242 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/publicrooms"
+)
+
+func TestPublicRoomsPagination(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	var roomIDs []string
+	for i := 0; i < 5; i++ {
+		room, err := user.CreateRoom(createroom.Request{Name: fmt.Sprintf("room%d", i), Visibility: createroom.VisibilityTypePublic})
+		assert.NoError(t, err)
+		roomIDs = append(roomIDs, room.ID())
+	}
+
+	chunkIDs := func(response *publicrooms.Response) []string {
+		var result []string
+		for _, chunk := range response.Chunk {
+			result = append(result, chunk.RoomID)
+		}
+		return result
+	}
+
+	// All rooms have the same number of members, so they are ordered by room ID
+	all, err := backend.PublicRooms(publicrooms.Request{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, roomIDs, chunkIDs(all))
+	assert.Equal(t, 5, all.TotalRoomCountEstimate)
+	assert.Empty(t, all.NextBatch)
+	assert.Empty(t, all.PrevBatch)
+
+	first, err := backend.PublicRooms(publicrooms.Request{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, chunkIDs(all)[:2], chunkIDs(first))
+	assert.Empty(t, first.PrevBatch)
+	assert.NotEmpty(t, first.NextBatch)
+
+	second, err := backend.PublicRooms(publicrooms.Request{Limit: 2, Since: first.NextBatch})
+	assert.NoError(t, err)
+	assert.Equal(t, chunkIDs(all)[2:4], chunkIDs(second))
+	assert.NotEmpty(t, second.PrevBatch)
+
+	last, err := backend.PublicRooms(publicrooms.Request{Limit: 2, Since: second.NextBatch})
+	assert.NoError(t, err)
+	assert.Equal(t, chunkIDs(all)[4:], chunkIDs(last))
+	assert.Empty(t, last.NextBatch)
+
+	// Paginating backwards returns the same batches
+	previous, err := backend.PublicRooms(publicrooms.Request{Limit: 2, Since: last.PrevBatch})
+	assert.NoError(t, err)
+	assert.Equal(t, chunkIDs(second), chunkIDs(previous))
+
+	previous, err = backend.PublicRooms(publicrooms.Request{Limit: 2, Since: previous.PrevBatch})
+	assert.NoError(t, err)
+	assert.Equal(t, chunkIDs(first), chunkIDs(previous))
+	assert.Empty(t, previous.PrevBatch)
+
+	// Token stays valid when rooms are added
+	_, err = user.CreateRoom(createroom.Request{Name: "another", Visibility: createroom.VisibilityTypePublic})
+	assert.NoError(t, err)
+	next, err := backend.PublicRooms(publicrooms.Request{Since: second.NextBatch})
+	assert.NoError(t, err)
+	assert.Contains(t, chunkIDs(next), chunkIDs(all)[4])
+	for _, roomID := range chunkIDs(all)[:4] {
+		assert.NotContains(t, chunkIDs(next), roomID)
+	}
+
+	_, err = backend.PublicRooms(publicrooms.Request{Since: "invalid"})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+}
+
+func TestPublicRoomsFilter(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room1, err := user.CreateRoom(createroom.Request{Name: "Go Programming", Visibility: createroom.VisibilityTypePublic})
+	assert.NoError(t, err)
+
+	room2, err := user.CreateRoom(createroom.Request{Topic: "About gophers", RoomAliasName: "animals", Visibility: createroom.VisibilityTypePublic})
+	assert.NoError(t, err)
+
+	_, err = user.CreateRoom(createroom.Request{Name: "private go", Preset: createroom.PrivateChat})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		searchTerm string
+		expected   []string
+	}{
+		{"", []string{room1.ID(), room2.ID()}},
+		{"GO", []string{room1.ID(), room2.ID()}},
+		{"programming", []string{room1.ID()}},
+		{"#animals:localhost", []string{room2.ID()}},
+		{"unknown", nil}}
+
+	for _, test := range tests {
+		response, err := backend.PublicRooms(publicrooms.Request{Filter: publicrooms.Filter{GenericSearchTerm: test.searchTerm}})
+		assert.NoError(t, err)
+
+		var roomIDs []string
+		for _, chunk := range response.Chunk {
+			roomIDs = append(roomIDs, chunk.RoomID)
+		}
+		assert.ElementsMatch(t, test.expected, roomIDs, test.searchTerm)
+	}
+
+	response, err := backend.PublicRooms(publicrooms.Request{Filter: publicrooms.Filter{GenericSearchTerm: "animals"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "#animals:localhost", response.Chunk[0].CanonicalAlias)
+	assert.Equal(t, []string{"#animals:localhost"}, response.Chunk[0].Aliases)
+}
+
+func TestPublicRoomsNetworks(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	_, err = user.CreateRoom(createroom.Request{Visibility: createroom.VisibilityTypePublic})
+	assert.NoError(t, err)
+
+	response, err := backend.PublicRooms(publicrooms.Request{Server: "localhost", IncludeAllNetworks: true})
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 1)
+
+	// Server has no third party networks
+	response, err = backend.PublicRooms(publicrooms.Request{ThirdPartyInstanceID: "irc"})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Chunk)
+
+	_, err = backend.PublicRooms(publicrooms.Request{ThirdPartyInstanceID: "irc", IncludeAllNetworks: true})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	_, err = backend.PublicRooms(publicrooms.Request{Server: "example.com"})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+}
+
+func TestPublishRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	publicRoomIDs := func() []string {
+		response, err := backend.PublicRooms(publicrooms.Request{})
+		assert.NoError(t, err)
+
+		var result []string
+		for _, chunk := range response.Chunk {
+			result = append(result, chunk.RoomID)
+		}
+		return result
+	}
+
+	// Public preset doesn't publish room
+	room, err := user1.CreateRoom(createroom.Request{Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+	assert.Empty(t, publicRoomIDs())
+
+	// Publishing requires power level
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.Equal(t, models.M_FORBIDDEN.Code(), user2.SetRoomVisibility(room, createroom.VisibilityTypePublic).Code())
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), user1.SetRoomVisibility(room, "unknown").Code())
+
+	assert.NoError(t, user1.SetRoomVisibility(room, createroom.VisibilityTypePublic))
+	assert.Equal(t, []string{room.ID()}, publicRoomIDs())
+
+	assert.NoError(t, user1.SetRoomVisibility(room, createroom.VisibilityTypePrivate))
+	assert.Empty(t, publicRoomIDs())
+
+	// Rooms which can't be joined by anyone can't be published
+	privateRoom, err := user1.CreateRoom(createroom.Request{Preset: createroom.PrivateChat, Visibility: createroom.VisibilityTypePublic})
+	assert.NoError(t, err)
+	assert.Equal(t, createroom.VisibilityTypePrivate, privateRoom.Visibility())
+	assert.Equal(t, models.M_BAD_STATE.Code(), user1.SetRoomVisibility(privateRoom, createroom.VisibilityTypePublic).Code())
+
+	// Room is delisted when it becomes invite only
+	assert.NoError(t, user1.SetRoomVisibility(room, createroom.VisibilityTypePublic))
+	backend.PutEvent(newStateEvent(room, user1, events.JoinRules, "", json.RawMessage(`{"join_rule":"invite"}`)))
+	assert.Equal(t, createroom.VisibilityTypePrivate, room.Visibility())
+	assert.Empty(t, publicRoomIDs())
+
+	// ...and when it is replaced, while replacement room is published
+	backend.PutEvent(newStateEvent(room, user1, events.JoinRules, "", json.RawMessage(`{"join_rule":"public"}`)))
+	assert.NoError(t, user1.SetRoomVisibility(room, createroom.VisibilityTypePublic))
+	newRoom, err := user1.UpgradeRoom(room, defaultRoomVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{newRoom.ID()}, publicRoomIDs())
+}
+
+func TestPublicRoomsInvalidation(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1", Visibility: createroom.VisibilityTypePublic})
+	assert.NoError(t, err)
+
+	response, err := backend.PublicRooms(publicrooms.Request{})
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 1)
+
+	backend.mutex.RLock()
+	version := backend.directoryVersion
+	backend.mutex.RUnlock()
+
+	// Messages don't change directory
+	assert.NoError(t, user.SendMessage(room, "message"))
+	backend.mutex.RLock()
+	assert.Equal(t, version, backend.directoryVersion)
+	backend.mutex.RUnlock()
+
+	assert.NoError(t, user.SetTopic(room, "new topic"))
+	response, err = backend.PublicRooms(publicrooms.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, "new topic", response.Chunk[0].Topic)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+	response, err = backend.PublicRooms(publicrooms.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Chunk[0].NumJoinedMembers)
+}
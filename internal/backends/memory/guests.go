@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+)
+
+// RegisterGuest registers guest account with generated user ID and logs in to it
+// https://matrix.org/docs/spec/client_server/latest#guest-access
+func (backend *Backend) RegisterGuest(device string) (user internal.User, token string, err models.ApiError) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	username := internal.RandomString(guestNameSize)
+	for _, exists := backend.data[username]; exists; _, exists = backend.data[username] {
+		username = internal.RandomString(guestNameSize)
+	}
+
+	guest := newUser(backend, username, "")
+	guest.guest = true
+
+	token = backend.issueToken(guest, device)
+
+	backend.data[username] = guest
+
+	return guest, token, nil
+}
+
+func (user *User) IsGuest() bool {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	return user.guest
+}
+
+// UpgradeGuest turns guest account to full account with password. User ID of account isn't changed.
+func (user *User) UpgradeGuest(password string) models.ApiError {
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	if !user.guest {
+		return models.NewError(models.M_BAD_STATE, "user is not a guest")
+	}
+
+	user.guest = false
+	user.password = password
+
+	return nil
+}
+
+// guestAccessForbidden returns error for actions which guests aren't allowed to do
+func (user *User) guestAccessForbidden() models.ApiError {
+	if user.IsGuest() {
+		return models.NewError(models.M_GUEST_ACCESS_FORBIDDEN, "guest users are not allowed to do this")
+	}
+
+	return nil
+}
+
+// guestAccess returns current guest access of room
+func (backend *Backend) guestAccess(roomID string) rooms.GuestAccess {
+	stateEvent, exists := backend.currentStateEvent(roomID, events.GuestAccess, "")
+	if !exists {
+		return rooms.GuestAccessForbidden
+	}
+
+	var content struct {
+		GuestAccess rooms.GuestAccess `json:"guest_access"`
+	}
+	if json.Unmarshal(stateEvent.Content, &content) != nil {
+		return rooms.GuestAccessForbidden
+	}
+
+	return content.GuestAccess
+}
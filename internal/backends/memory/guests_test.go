@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+)
+
+func TestRegisterGuest(t *testing.T) {
+	backend := newTestBackend(t)
+
+	guest, token, err := backend.RegisterGuest("device1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.True(t, guest.IsGuest())
+	assert.Equal(t, guest, backend.GetUserByToken(token))
+
+	// Guest can't log in without access token
+	_, _, err = backend.Login(guest.Name(), "", "device2")
+	assert.Error(t, err)
+
+	_, err = guest.CreateRoom(createroom.Request{Name: "room1"})
+	assert.Equal(t, models.M_GUEST_ACCESS_FORBIDDEN.Code(), err.Code())
+}
+
+func TestGuestJoinRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	guest, _, err := backend.RegisterGuest("")
+	assert.NoError(t, err)
+
+	privateRoom, err := user.CreateRoom(createroom.Request{Name: "room1", Preset: createroom.PrivateChat})
+	assert.NoError(t, err)
+	assert.True(t, privateRoom.GuestCanJoin())
+
+	publicRoom, err := user.CreateRoom(createroom.Request{Name: "room2", Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+	assert.False(t, publicRoom.GuestCanJoin())
+
+	assert.NoError(t, guest.JoinRoom(privateRoom))
+
+	err = guest.JoinRoom(publicRoom)
+	assert.Equal(t, models.M_GUEST_ACCESS_FORBIDDEN.Code(), err.Code())
+
+	// Guest can't invite other users
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+	err = guest.Invite(privateRoom, user2)
+	assert.Equal(t, models.M_GUEST_ACCESS_FORBIDDEN.Code(), err.Code())
+}
+
+func TestGuestReadWorldReadableRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	guest, _, err := backend.RegisterGuest("")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1", Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+
+	_, err = guest.Messages(room, "", "", messages.DirectionBackward, 10, nil)
+	assert.Error(t, err)
+
+	setHistoryVisibility(t, backend, room, user, rooms.HistoryVisibilityWorldReadable)
+	assert.NoError(t, user.SendMessage(room, "hello"))
+
+	response, err := guest.Messages(room, "", "", messages.DirectionBackward, 10, nil)
+	assert.NoError(t, err)
+	assert.Len(t, messageBodies(response.Chunk), 1)
+}
+
+func TestUpgradeGuest(t *testing.T) {
+	backend := newTestBackend(t)
+
+	guest, _, err := backend.RegisterGuest("")
+	assert.NoError(t, err)
+	guestID := guest.ID()
+
+	assert.NoError(t, guest.UpgradeGuest("password"))
+	assert.False(t, guest.IsGuest())
+
+	user, _, err := backend.Login(guest.Name(), "password", "")
+	assert.NoError(t, err)
+	assert.Equal(t, guestID, user.ID())
+
+	_, err = user.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	// Full account can't be upgraded again
+	assert.Error(t, user.UpgradeGuest("password"))
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// setIgnoredUsers replaces list of ignored users. Users whose ignore status has changed are remembered,
+// so rooms with them will be synced again from scratch.
+// https://matrix.org/docs/spec/client_server/latest#ignoring-users
+func (user *User) setIgnoredUsers(content json.RawMessage) models.ApiError {
+	var newList events.IgnoredUserListContent
+	if err := json.Unmarshal(content, &newList); err != nil || newList.IgnoredUsers == nil {
+		return models.NewError(models.M_BAD_JSON, "ignored_users must be object")
+	}
+
+	if _, ignoresSelf := newList.IgnoredUsers[user.ID()]; ignoresSelf {
+		return models.NewError(models.M_INVALID_PARAM, "user can't ignore itself")
+	}
+
+	return user.updateAccountData("", string(events.IgnoredUserList), func(oldContent json.RawMessage, position int64) (json.RawMessage, models.ApiError) {
+		oldList := decodeIgnoredUsers(oldContent)
+
+		for userID := range newList.IgnoredUsers {
+			if !oldList[userID] {
+				user.ignoreChanges = append(user.ignoreChanges, stateChange{position, userID})
+			}
+		}
+		for userID := range oldList {
+			if _, exists := newList.IgnoredUsers[userID]; !exists {
+				user.ignoreChanges = append(user.ignoreChanges, stateChange{position, userID})
+			}
+		}
+
+		return content, nil
+	})
+}
+
+// ignoredUsers returns set of users ignored by user
+func (user *User) ignoredUsers() map[string]bool {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	return decodeIgnoredUsers(user.accountData[""][string(events.IgnoredUserList)].content)
+}
+
+// ignoreChangesBetween returns users which have been ignored or unignored after since position up to until position (inclusive)
+func (user *User) ignoreChangesBetween(since, until int64) map[string]bool {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	changed := make(map[string]bool)
+	for _, change := range user.ignoreChanges {
+		if change.position > since && change.position <= until {
+			changed[change.value] = true
+		}
+	}
+
+	return changed
+}
+
+func decodeIgnoredUsers(content json.RawMessage) map[string]bool {
+	ignored := make(map[string]bool)
+
+	var list events.IgnoredUserListContent
+	if content == nil || json.Unmarshal(content, &list) != nil {
+		return ignored
+	}
+
+	for userID := range list.IgnoredUsers {
+		ignored[userID] = true
+	}
+
+	return ignored
+}
+
+// userByID returns user of this server by user ID
+func (backend *Backend) userByID(userID string) *User {
+	if !strings.HasPrefix(userID, "@") || !strings.HasSuffix(userID, ":"+backend.hostname) {
+		return nil
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(userID, "@"), ":"+backend.hostname)
+
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	if user, exists := backend.data[name]; exists {
+		return user.(*User)
+	}
+
+	return nil
+}
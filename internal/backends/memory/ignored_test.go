@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+)
+
+func ignoreUsers(t *testing.T, user *User, userIDs ...string) {
+	list := events.IgnoredUserListContent{IgnoredUsers: make(map[string]struct{})}
+	for _, userID := range userIDs {
+		list.IgnoredUsers[userID] = struct{}{}
+	}
+
+	content, err := json.Marshal(list)
+	assert.NoError(t, err)
+	assert.NoError(t, user.SetAccountData(string(events.IgnoredUserList), content))
+}
+
+func TestIgnoredUsers(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+
+	assert.Error(t, user1.SetAccountData(string(events.IgnoredUserList), json.RawMessage(`{"ignored_users":[]}`)))
+	assert.Error(t, user1.SetAccountData(string(events.IgnoredUserList), json.RawMessage(`{"ignored_users":{"@user1:localhost":{}}}`)))
+
+	ignoreUsers(t, user1.(*User), user2.ID())
+
+	assert.NoError(t, user2.SendMessage(room, "hello user1"))
+	assert.NoError(t, user1.SendMessage(room, "hello user2"))
+
+	response, err := user1.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+	assert.NoError(t, err)
+	bodies := messageBodies(response.Chunk)
+	assert.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], "hello user2")
+
+	// State events of ignored users are still visible
+	joined := false
+	for _, event := range response.Chunk {
+		if event.EType == events.Member && event.Sender == user2.ID() {
+			joined = true
+		}
+	}
+	assert.True(t, joined)
+
+	// Ignored user's messages aren't notified
+	notifications, err := user1.Notifications("", 0, "")
+	assert.NoError(t, err)
+	assert.Empty(t, notifications.Notifications)
+
+	// Ignore list is applied to ignorer only
+	response, err = user2.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+	assert.NoError(t, err)
+	assert.Len(t, messageBodies(response.Chunk), 2)
+
+	_, err = user1.GetEvent(room, response.Chunk[1].EventID)
+	assert.Error(t, err)
+}
+
+func TestSyncIgnoredUsers(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, token, err := backend.Register("user1", "", "device1")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room1, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room1))
+
+	room2, err := user2.CreateRoom(createroom.Request{Name: "room2"})
+	assert.NoError(t, err)
+
+	ignoreUsers(t, user1.(*User), user2.ID())
+
+	assert.NoError(t, user2.SendMessage(room1, "hello user1"))
+	assert.NoError(t, user2.Invite(room2, user1))
+
+	response, err := user1.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, messageBodies(response.Rooms.Join[room1.ID()].Timeline.Events))
+	assert.NotContains(t, response.Rooms.Invite, room2.ID())
+
+	assert.NoError(t, user1.SendMessage(room1, "hello"))
+
+	response, err = user1.Sync(token, mSync.SyncRequest{Since: response.NextBatch})
+	assert.NoError(t, err)
+	assert.Len(t, response.Rooms.Join[room1.ID()].Timeline.Events, 1)
+
+	// Room is synced from scratch after user is unignored, so hidden messages appear
+	ignoreUsers(t, user1.(*User))
+
+	response, err = user1.Sync(token, mSync.SyncRequest{Since: response.NextBatch})
+	assert.NoError(t, err)
+	joinedRoom := response.Rooms.Join[room1.ID()]
+	assert.Len(t, messageBodies(joinedRoom.Timeline.Events), 2)
+	assert.Equal(t, events.Create, joinedRoom.Timeline.Events[0].EType)
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+// loginToken is short-lived single-use token for m.login.token login
+type loginToken struct {
+	username string
+	expires  time.Time
+}
+
+// CreateLoginToken returns token which can be exchanged for access token of user once during lifetime
+func (backend *Backend) CreateLoginToken(user internal.User, lifetime time.Duration) string {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	now := time.Now()
+	for token, info := range backend.loginTokens {
+		if now.After(info.expires) {
+			delete(backend.loginTokens, token)
+		}
+	}
+
+	token := internal.RandomString(defaultTokenSize)
+	backend.loginTokens[token] = loginToken{
+		username: user.Name(),
+		expires:  now.Add(lifetime)}
+
+	return token
+}
+
+// LoginByToken logs in user of login token, token can't be used again
+func (backend *Backend) LoginByToken(token, device string) (user internal.User, accessToken string, err models.ApiError) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	info, exists := backend.loginTokens[token]
+	delete(backend.loginTokens, token)
+
+	if !exists || time.Now().After(info.expires) {
+		return nil, "", models.NewError(models.M_FORBIDDEN, "invalid login token")
+	}
+
+	user, exists = backend.data[info.username]
+	if !exists {
+		return nil, "", models.NewError(models.M_FORBIDDEN, "invalid login token")
+	}
+
+	accessToken = backend.issueToken(user.(*User), device)
+
+	return user, accessToken, nil
+}
+
+// IsNewDevice reports whether device has only one access token, so it has been created by last login
+func (user *User) IsNewDevice(device string) bool {
+	user.backend.mutex.RLock()
+	defer user.backend.mutex.RUnlock()
+
+	count := 0
+	for _, token := range user.Tokens {
+		if token.Device == device {
+			count++
+		}
+	}
+
+	return count == 1
+}
+
+// SetDeviceDisplayName sets display name of device
+func (user *User) SetDeviceDisplayName(device, displayName string) {
+	user.backend.mutex.Lock()
+	defer user.backend.mutex.Unlock()
+
+	for accessToken, token := range user.Tokens {
+		if token.Device == device {
+			token.DisplayName = displayName
+			user.Tokens[accessToken] = token
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+func TestLoginByToken(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("username1", "password1", "dev1")
+	assert.NoError(t, err)
+
+	loginToken := backend.CreateLoginToken(user, time.Minute)
+	assert.NotEmpty(t, loginToken)
+
+	loggedIn, accessToken, err := backend.LoginByToken(loginToken, "dev2")
+	assert.NoError(t, err)
+	assert.Equal(t, user, loggedIn)
+	assert.Equal(t, user, backend.GetUserByToken(accessToken))
+
+	// Token is single-use
+	_, _, err = backend.LoginByToken(loginToken, "dev3")
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+
+	// Token expires
+	expiredToken := backend.CreateLoginToken(user, -time.Second)
+	_, _, err = backend.LoginByToken(expiredToken, "dev3")
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+
+	_, _, err = backend.LoginByToken("unknown", "dev3")
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+}
+
+func TestDeviceDisplayName(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("username1", "password1", "dev1")
+	assert.NoError(t, err)
+	assert.True(t, user.IsNewDevice("dev1"))
+
+	user.SetDeviceDisplayName("dev1", "Phone")
+
+	_, _, err = backend.Login("username1", "password1", "dev1")
+	assert.NoError(t, err)
+	assert.False(t, user.IsNewDevice("dev1"))
+
+	_, _, err = backend.Login("username1", "password1", "dev2")
+	assert.NoError(t, err)
+	assert.True(t, user.IsNewDevice("dev2"))
+
+	names := make(map[string]string)
+	for _, device := range user.Devices() {
+		names[device.DeviceID] = device.DisplayName
+	}
+	assert.Equal(t, map[string]string{"dev1": "Phone", "dev2": ""}, names)
+}
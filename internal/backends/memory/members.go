@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/members"
+)
+
+// newMemberEvent returns m.room.member event which changes membership of target user
+func newMemberEvent(room internal.Room, sender, target internal.User, membership events.Membership) *events.RoomEvent {
+	content, _ := json.Marshal(events.EventContent{Membership: membership}) // TODO: add display name and avatar when profiles will be implemented
+
+	return &events.RoomEvent{
+		ContentData:    content,
+		EType:          events.Member,
+		EventID:        internal.RandomString(eventIDSize),
+		Sender:         sender.ID(),
+		OriginServerTs: time.Now().Unix(),
+		RoomID:         room.ID(),
+		StateKey:       stateKey(target.ID())}
+}
+
+// newStateEvent returns state event of room sent by sender
+func newStateEvent(room internal.Room, sender internal.User, eventType events.EventType, key string, content json.RawMessage) *events.RoomEvent {
+	return &events.RoomEvent{
+		ContentData:    content,
+		EType:          eventType,
+		EventID:        internal.RandomString(eventIDSize),
+		Sender:         sender.ID(),
+		OriginServerTs: time.Now().Unix(),
+		RoomID:         room.ID(),
+		StateKey:       stateKey(key)}
+}
+
+// memberEvents returns member events of room state at specified stream position
+func (backend *Backend) memberEvents(roomID string, position int64) []events.StateEvent {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	index := backend.roomStates[roomID]
+	if index == nil {
+		return nil
+	}
+
+	return index.stateAt(position, events.Member)
+}
+
+func memberContent(stateEvent events.StateEvent) events.EventContent {
+	var content events.EventContent
+	json.Unmarshal(stateEvent.Content, &content)
+
+	return content
+}
+
+// visibleStatePosition returns latest position up to specified one at which user can see state of room.
+// User which isn't joined to room can see only state at the moment of leaving unless room is world readable.
+func (backend *Backend) visibleStatePosition(room internal.Room, userID string, position int64) int64 {
+	backend.mutex.RLock()
+	currentPosition := backend.streamPosition
+	backend.mutex.RUnlock()
+
+	memberEvent, memberPosition, exists := backend.userMembership(room.ID(), userID, currentPosition)
+	if !exists || memberPosition >= position || memberContent(memberEvent).Membership == events.MembershipJoin || room.WorldReadable() {
+		return position
+	}
+
+	return memberPosition
+}
+
+// Members returns member events of room. If at is not empty, members at this point of room history are returned.
+func (user *User) Members(room internal.Room, at string, membership, notMembership events.Membership) ([]events.StateEvent, models.ApiError) {
+	if !user.backend.newVisibilityChecker(room.ID(), user.ID()).canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view members of room")
+	}
+
+	var position int64
+	if at != "" {
+		var ok bool
+		if position, ok = user.backend.streamToken(at); !ok {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong at token")
+		}
+	} else {
+		user.backend.mutex.RLock()
+		position = user.backend.streamPosition
+		user.backend.mutex.RUnlock()
+	}
+	position = user.backend.visibleStatePosition(room, user.ID(), position)
+
+	result := []events.StateEvent{}
+	for _, stateEvent := range user.backend.memberEvents(room.ID(), position) {
+		content := memberContent(stateEvent)
+
+		if (membership != "" && content.Membership != membership) ||
+			(notMembership != "" && content.Membership == notMembership) {
+			continue
+		}
+
+		result = append(result, stateEvent)
+	}
+
+	return result, nil
+}
+
+// JoinedMembers returns profiles of users joined to room
+func (user *User) JoinedMembers(room internal.Room) (map[string]members.RoomMember, models.ApiError) {
+	if !user.backend.newVisibilityChecker(room.ID(), user.ID()).canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view members of room")
+	}
+
+	user.backend.mutex.RLock()
+	position := user.backend.streamPosition
+	user.backend.mutex.RUnlock()
+	position = user.backend.visibleStatePosition(room, user.ID(), position)
+
+	result := make(map[string]members.RoomMember)
+	for _, stateEvent := range user.backend.memberEvents(room.ID(), position) {
+		content := memberContent(stateEvent)
+		if content.Membership != events.MembershipJoin {
+			continue
+		}
+
+		result[stateEvent.StateKey] = members.RoomMember{
+			DisplayName: content.DisplayName,
+			AvatarURL:   content.AvatarURL}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+)
+
+func TestMembers(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	user3, _, err := backend.Register("user3", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.NoError(t, user1.Invite(room, user3))
+
+	memberEvents, err := user1.Members(room, "", "", "")
+	assert.NoError(t, err)
+	assert.Len(t, memberEvents, 3)
+	for _, memberEvent := range memberEvents {
+		assert.Equal(t, string(events.Member), memberEvent.Type)
+	}
+
+	memberEvents, err = user1.Members(room, "", events.MembershipInvite, "")
+	assert.NoError(t, err)
+	assert.Len(t, memberEvents, 1)
+	assert.Equal(t, user3.ID(), memberEvents[0].StateKey)
+	assert.Equal(t, user1.ID(), memberEvents[0].Sender)
+
+	memberEvents, err = user1.Members(room, "", "", events.MembershipInvite)
+	assert.NoError(t, err)
+	assert.Len(t, memberEvents, 2)
+
+	// Remember point of history before user2 leaves
+	response, err := user1.Messages(room, "", "", messages.DirectionBackward, 1, nil)
+	assert.NoError(t, err)
+	at := response.End
+
+	assert.NoError(t, user2.LeaveRoom(room))
+
+	memberEvents, err = user1.Members(room, "", events.MembershipLeave, "")
+	assert.NoError(t, err)
+	assert.Len(t, memberEvents, 1)
+	assert.Equal(t, user2.ID(), memberEvents[0].StateKey)
+
+	memberEvents, err = user1.Members(room, at, events.MembershipJoin, "")
+	assert.NoError(t, err)
+	assert.Len(t, memberEvents, 2)
+
+	joined, err := user1.JoinedMembers(room)
+	assert.NoError(t, err)
+	assert.Len(t, joined, 1)
+	assert.Contains(t, joined, user1.ID())
+
+	// Never was member
+	user4, _, err := backend.Register("user4", "", "")
+	assert.NoError(t, err)
+
+	_, err = user4.Members(room, "", "", "")
+	assert.Error(t, err)
+
+	_, err = user4.JoinedMembers(room)
+	assert.Error(t, err)
+
+	// User which left room sees members at the moment of leaving
+	user5, _, err := backend.Register("user5", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, user5.JoinRoom(room))
+
+	memberEvents, err = user2.Members(room, "", events.MembershipJoin, "")
+	assert.NoError(t, err)
+	assert.Len(t, memberEvents, 1)
+	assert.Equal(t, user1.ID(), memberEvents[0].StateKey)
+
+	joined, err = user2.JoinedMembers(room)
+	assert.NoError(t, err)
+	assert.Len(t, joined, 1)
+	assert.NotContains(t, joined, user5.ID())
+}
@@ -0,0 +1,212 @@
+package memory
+
+import (
+	"strconv"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/eventcontext"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+)
+
+// streamToken converts pagination token to stream position. Sync batch tokens which are event IDs are supported too.
+func (backend *Backend) streamToken(token string) (int64, bool) {
+	if position, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return position, position >= 0
+	}
+
+	stored, exists := backend.storedEvent(token)
+	return stored.Position, exists
+}
+
+func (user *User) Messages(room internal.Room, from, to string, dir messages.Direction, limit int, eventFilter *filter.RoomEventFilter) (*messages.Response, models.ApiError) {
+	return user.backend.messages(room, user.ID(), from, to, dir, limit, eventFilter)
+}
+
+// messages returns events of room which user can see. Empty user ID is used for unauthenticated users.
+func (backend *Backend) messages(room internal.Room, userID, from, to string, dir messages.Direction, limit int, eventFilter *filter.RoomEventFilter) (*messages.Response, models.ApiError) {
+	checker := backend.newVisibilityChecker(room.ID(), userID)
+	if !checker.canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view events of room")
+	}
+
+	if dir != messages.DirectionBackward && dir != messages.DirectionForward {
+		return nil, models.NewError(models.M_INVALID_PARAM, "wrong dir: "+string(dir))
+	}
+
+	if limit <= 0 {
+		limit = messages.DefaultLimit
+	}
+
+	var fromPosition int64
+	if from != "" {
+		var ok bool
+		if fromPosition, ok = backend.streamToken(from); !ok {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong from token")
+		}
+	} else if dir == messages.DirectionBackward {
+		backend.mutex.RLock()
+		fromPosition = backend.streamPosition + 1
+		backend.mutex.RUnlock()
+	}
+
+	var toPosition int64 = -1
+	if to != "" {
+		var ok bool
+		if toPosition, ok = backend.streamToken(to); !ok {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong to token")
+		}
+	}
+
+	backwards := dir == messages.DirectionBackward
+
+	response := &messages.Response{
+		Start: strconv.FormatInt(fromPosition, 10),
+		End:   strconv.FormatInt(fromPosition, 10),
+		Chunk: []events.RoomEvent{}}
+
+	for _, stored := range backend.roomEvents(room.ID(), fromPosition, limit, backwards, eventFilter, checker) {
+		if toPosition >= 0 && ((backwards && stored.Position <= toPosition) || (!backwards && stored.Position >= toPosition)) {
+			break
+		}
+
+		event, err := stored.roomEvent()
+		if err != nil {
+			continue
+		}
+
+		response.Chunk = append(response.Chunk, *event)
+		response.End = strconv.FormatInt(stored.Position, 10)
+	}
+
+	return response, nil
+}
+
+func (user *User) EventContext(room internal.Room, eventID string, limit int, eventFilter *filter.RoomEventFilter) (*eventcontext.Response, models.ApiError) {
+	backend := user.backend
+
+	checker := backend.newVisibilityChecker(room.ID(), user.ID())
+	if !checker.canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view events of room")
+	}
+
+	stored, exists := backend.storedEvent(eventID)
+	if !exists || stored.RoomID != room.ID() || !checker.canSee(stored.Position) {
+		return nil, models.NewError(models.M_NOT_FOUND, "event not found")
+	}
+
+	event, err := stored.roomEvent()
+	if err != nil {
+		return nil, models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
+	if checker.isIgnored(event) {
+		return nil, models.NewError(models.M_NOT_FOUND, "event not found")
+	}
+
+	// Context is divided between events before and after requested event
+	beforeLimit := limit / 2
+	afterLimit := limit - beforeLimit
+
+	position := strconv.FormatInt(stored.Position, 10)
+
+	response := &eventcontext.Response{
+		Start:        position,
+		End:          position,
+		Event:        *event,
+		EventsBefore: []events.RoomEvent{},
+		EventsAfter:  []events.RoomEvent{}}
+
+	if beforeLimit > 0 {
+		for _, before := range backend.roomEvents(room.ID(), stored.Position, beforeLimit, true, eventFilter, checker) {
+			if beforeEvent, err := before.roomEvent(); err == nil {
+				response.EventsBefore = append(response.EventsBefore, *beforeEvent)
+				response.Start = strconv.FormatInt(before.Position, 10)
+			}
+		}
+	}
+
+	lastPosition := stored.Position
+	if afterLimit > 0 {
+		for _, after := range backend.roomEvents(room.ID(), stored.Position, afterLimit, false, eventFilter, checker) {
+			if afterEvent, err := after.roomEvent(); err == nil {
+				response.EventsAfter = append(response.EventsAfter, *afterEvent)
+				response.End = strconv.FormatInt(after.Position, 10)
+				lastPosition = after.Position
+			}
+		}
+	}
+
+	response.State = backend.roomState(room.ID(), lastPosition)
+
+	return response, nil
+}
+
+// roomState returns state of room at specified stream position
+func (backend *Backend) roomState(roomID string, position int64) []events.StateEvent {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	index := backend.roomStates[roomID]
+	if index == nil {
+		return []events.StateEvent{}
+	}
+
+	return index.stateAt(position, "")
+}
+
+// currentStateEvent returns current state event of room with specified type and state key
+func (backend *Backend) currentStateEvent(roomID string, eventType events.EventType, key string) (events.StateEvent, bool) {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	index := backend.roomStates[roomID]
+	if index == nil {
+		return events.StateEvent{}, false
+	}
+
+	stateEvent, exists := index.current[stateID{eventType: eventType, key: key}]
+
+	return stateEvent, exists
+}
+
+// roomStateBetween returns state of room which was changed after from position up to position (inclusive)
+func (backend *Backend) roomStateBetween(roomID string, from, position int64) []events.StateEvent {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	index := backend.roomStates[roomID]
+	if index == nil {
+		return []events.StateEvent{}
+	}
+
+	return index.stateBetween(from, position)
+}
+
+// GetEvent returns event of room if user can see it
+func (user *User) GetEvent(room internal.Room, eventID string) (*events.RoomEvent, models.ApiError) {
+	checker := user.backend.newVisibilityChecker(room.ID(), user.ID())
+
+	stored, exists := user.backend.storedEvent(eventID)
+	if !exists || stored.RoomID != room.ID() || !checker.canSee(stored.Position) {
+		return nil, models.NewError(models.M_NOT_FOUND, "event not found")
+	}
+
+	event, err := stored.roomEvent()
+	if err != nil {
+		return nil, models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
+	if checker.isIgnored(event) {
+		return nil, models.NewError(models.M_NOT_FOUND, "event not found")
+	}
+
+	return event, nil
+}
+
+// stateKey returns pointer to state key for state events
+func stateKey(key string) *string {
+	return &key
+}
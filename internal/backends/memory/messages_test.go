@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+)
+
+func TestMessages(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "message 1"))
+	assert.NoError(t, user1.SendMessage(room, "message 2"))
+	assert.NoError(t, user1.SendMessage(room, "message 3"))
+
+	messageFilter := &filter.RoomEventFilter{Types: []string{string(events.Message)}}
+
+	// Backward pagination from the end of room
+	response, err := user1.Messages(room, "", "", messages.DirectionBackward, 2, messageFilter)
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 2)
+	assert.Contains(t, string(response.Chunk[0].ContentData), "message 3")
+	assert.Contains(t, string(response.Chunk[1].ContentData), "message 2")
+
+	response, err = user1.Messages(room, response.End, "", messages.DirectionBackward, 2, messageFilter)
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 1)
+	assert.Contains(t, string(response.Chunk[0].ContentData), "message 1")
+
+	// Forward pagination back to the end
+	response, err = user1.Messages(room, response.End, "", messages.DirectionForward, 0, messageFilter)
+	assert.NoError(t, err)
+	assert.Len(t, response.Chunk, 2)
+	assert.Contains(t, string(response.Chunk[0].ContentData), "message 2")
+
+	_, err = user1.Messages(room, "", "", "x", 0, nil)
+	assert.Error(t, err)
+
+	// Not member can't read events
+	_, err = user2.Messages(room, "", "", messages.DirectionBackward, 0, nil)
+	assert.Error(t, err)
+}
+
+func TestEventContext(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1", Topic: "topic"})
+	assert.NoError(t, err)
+
+	otherRoom, err := user1.CreateRoom(createroom.Request{Name: "room2"})
+	assert.NoError(t, err)
+
+	for _, text := range []string{"message 1", "message 2", "message 3", "message 4"} {
+		assert.NoError(t, user1.SendMessage(room, text))
+	}
+
+	response, err := user1.Messages(room, "", "", messages.DirectionBackward, 2, nil)
+	assert.NoError(t, err)
+	eventID := response.Chunk[1].EventID // message 3
+
+	messageFilter := &filter.RoomEventFilter{Types: []string{string(events.Message)}}
+
+	context, err := user1.EventContext(room, eventID, 4, messageFilter)
+	assert.NoError(t, err)
+	assert.Equal(t, eventID, context.Event.EventID)
+	assert.Len(t, context.EventsBefore, 2)
+	assert.Contains(t, string(context.EventsBefore[0].ContentData), "message 2")
+	assert.Contains(t, string(context.EventsBefore[1].ContentData), "message 1")
+	assert.Len(t, context.EventsAfter, 1)
+	assert.Contains(t, string(context.EventsAfter[0].ContentData), "message 4")
+	assert.NotEqual(t, context.Start, context.End)
+
+	var stateTypes []string
+	for _, stateEvent := range context.State {
+		stateTypes = append(stateTypes, stateEvent.Type)
+	}
+	assert.Contains(t, stateTypes, string(events.Name))
+	assert.Contains(t, stateTypes, string(events.Topic))
+
+	// Start token continues backward pagination
+	response, err = user1.Messages(room, context.Start, "", messages.DirectionBackward, 0, messageFilter)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Chunk)
+
+	// Event of other room
+	_, err = user1.EventContext(otherRoom, eventID, 4, nil)
+	assert.Error(t, err)
+
+	_, err = user2.EventContext(room, eventID, 4, nil)
+	assert.Error(t, err)
+}
+
+func TestGetEvent(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	otherRoom, err := user1.CreateRoom(createroom.Request{Name: "room2"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "message"))
+
+	response, err := user1.Messages(room, "", "", messages.DirectionBackward, 1, nil)
+	assert.NoError(t, err)
+	eventID := response.Chunk[0].EventID
+
+	event, err := user1.GetEvent(room, eventID)
+	assert.NoError(t, err)
+	assert.Equal(t, eventID, event.EventID)
+
+	// Event of other room
+	_, err = user1.GetEvent(otherRoom, eventID)
+	assert.Equal(t, models.M_NOT_FOUND.Code(), err.Code())
+
+	// Not member
+	_, err = user2.GetEvent(room, eventID)
+	assert.Equal(t, models.M_NOT_FOUND.Code(), err.Code())
+}
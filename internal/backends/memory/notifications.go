@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/notifications"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+)
+
+const (
+	maxNotificationsCount     = 1000 // count of notifications stored for each user
+	defaultNotificationsLimit = 50
+)
+
+type notification struct {
+	notifications.Notification
+	position int64 // position of notified event in stream, used as pagination token
+}
+
+// addNotification adds notification to history of user. Must be called with locked user mutex.
+func (user *User) addNotification(event *events.RoomEvent, position int64, actions []pushrules.Action) {
+	user.notifications = append(user.notifications, notification{
+		Notification: notifications.Notification{
+			Actions: actions,
+			Event:   *event,
+			RoomID:  event.RoomID,
+			TS:      time.Now().UnixNano() / int64(time.Millisecond)},
+		position: position})
+
+	if len(user.notifications) > maxNotificationsCount {
+		user.notifications = user.notifications[len(user.notifications)-maxNotificationsCount:]
+	}
+}
+
+func (user *User) Notifications(from string, limit int, only string) (*notifications.Response, models.ApiError) {
+	if err := user.guestAccessForbidden(); err != nil {
+		return nil, err
+	}
+
+	var fromPosition int64
+	if from != "" {
+		var err error
+		fromPosition, err = strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong from token")
+		}
+	}
+
+	if limit <= 0 {
+		limit = defaultNotificationsLimit
+	}
+
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	response := &notifications.Response{Notifications: []notifications.Notification{}}
+	var lastPosition int64
+
+	// newest notifications go first
+	for i := len(user.notifications) - 1; i >= 0; i-- {
+		n := user.notifications[i]
+
+		if fromPosition > 0 && n.position >= fromPosition {
+			continue
+		}
+
+		if only == notifications.OnlyHighlight && !isHighlight(n.Actions) {
+			continue
+		}
+
+		if len(response.Notifications) == limit {
+			response.NextToken = strconv.FormatInt(lastPosition, 10)
+			break
+		}
+
+		response.Notifications = append(response.Notifications, n.Notification)
+		lastPosition = n.position
+	}
+
+	return response, nil
+}
+
+// SetReadReceipt marks all notifications in room up to specified event as read
+func (user *User) SetReadReceipt(room internal.Room, eventID string) models.ApiError {
+	if !isRoomMember(room, user) {
+		return models.NewError(models.M_FORBIDDEN, "you are not a member of room")
+	}
+
+	entry, exists := user.backend.storedEvent(eventID)
+	if !exists || entry.RoomID != room.ID() {
+		return models.NewError(models.M_NOT_FOUND, "event not found")
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	if entry.Position <= user.readMarkers[room.ID()] {
+		return nil
+	}
+	user.readMarkers[room.ID()] = entry.Position
+
+	var counts = user.unreadNotifications[room.ID()]
+	counts.NotificationCount = 0
+	counts.HighlightCount = 0
+
+	for i := range user.notifications {
+		n := &user.notifications[i]
+		if n.RoomID != room.ID() {
+			continue
+		}
+
+		if n.position <= entry.Position {
+			n.Read = true
+		}
+
+		if !n.Read {
+			counts.NotificationCount++
+			if isHighlight(n.Actions) {
+				counts.HighlightCount++
+			}
+		}
+	}
+
+	user.unreadNotifications[room.ID()] = counts
+
+	return nil
+}
+
+func isHighlight(actions []pushrules.Action) bool {
+	for _, action := range actions {
+		if action.Type == pushrules.ActionSetTweak && action.Tweak == pushrules.TweakHighlight {
+			highlight, ok := action.Value.(bool)
+			return !ok || highlight
+		}
+	}
+
+	return false
+}
+
+func isRoomMember(room internal.Room, user internal.User) bool {
+	for _, member := range room.Users() {
+		if member.ID() == user.ID() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/peek"
+	"github.com/signaller-matrix/signaller/internal/models/roominitialsync"
+)
+
+// PeekMessages returns events of room for unauthenticated user. Only world readable rooms can be viewed.
+// https://matrix.org/docs/spec/client_server/latest#room-previews
+func (backend *Backend) PeekMessages(room internal.Room, from, to string, dir messages.Direction, limit int, eventFilter *filter.RoomEventFilter) (*messages.Response, models.ApiError) {
+	return backend.messages(room, "", from, to, dir, limit, eventFilter)
+}
+
+func (user *User) RoomInitialSync(room internal.Room) (*roominitialsync.Response, models.ApiError) {
+	return user.backend.roomInitialSync(room, user.ID())
+}
+
+// PeekRoomInitialSync returns snapshot of room for unauthenticated user
+func (backend *Backend) PeekRoomInitialSync(room internal.Room) (*roominitialsync.Response, models.ApiError) {
+	return backend.roomInitialSync(room, "")
+}
+
+// roomInitialSync returns state and recent messages of room which user can see. Empty user ID is used for unauthenticated users.
+func (backend *Backend) roomInitialSync(room internal.Room, userID string) (*roominitialsync.Response, models.ApiError) {
+	backend.mutex.RLock()
+	statePosition := backend.streamPosition
+	backend.mutex.RUnlock()
+
+	recent, err := backend.messages(room, userID, "", "", messages.DirectionBackward, roominitialsync.DefaultLimit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &roominitialsync.Response{
+		RoomID:      room.ID(),
+		Messages:    roominitialsync.PaginationChunk{Start: recent.End, End: recent.Start, Chunk: []events.RoomEvent{}},
+		Visibility:  room.Visibility(),
+		AccountData: []events.Event{},
+		Presence:    []events.Event{}}
+
+	if response.Visibility == "" {
+		response.Visibility = createroom.VisibilityTypePrivate
+	}
+
+	// Messages are returned in chronological order
+	for i := len(recent.Chunk) - 1; i >= 0; i-- {
+		response.Messages.Chunk = append(response.Messages.Chunk, recent.Chunk[i])
+	}
+
+	if userID != "" {
+		if memberEvent, memberPosition, exists := backend.userMembership(room.ID(), userID, statePosition); exists {
+			response.Membership = memberContent(memberEvent).Membership
+
+			// User which left room can see only state at the moment of leaving
+			if response.Membership != events.MembershipJoin && !room.WorldReadable() {
+				statePosition = memberPosition
+			}
+		}
+	}
+
+	response.State = backend.roomState(room.ID(), statePosition)
+
+	return response, nil
+}
+
+func (user *User) PeekEvents(room internal.Room, from string, timeout time.Duration) (*peek.Response, models.ApiError) {
+	return user.backend.peekEvents(room, user.ID(), from, timeout)
+}
+
+// PeekEvents waits for new events of world readable room for unauthenticated user
+func (backend *Backend) PeekEvents(room internal.Room, from string, timeout time.Duration) (*peek.Response, models.ApiError) {
+	return backend.peekEvents(room, "", from, timeout)
+}
+
+// peekEvents returns events of room after from token which user can see.
+// If there are no such events it waits for them until timeout is reached.
+func (backend *Backend) peekEvents(room internal.Room, userID, from string, timeout time.Duration) (*peek.Response, models.ApiError) {
+	checker := backend.newVisibilityChecker(room.ID(), userID)
+	if !checker.canSeeRoom() {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not allowed to view events of room")
+	}
+
+	backend.mutex.RLock()
+	fromPosition := backend.streamPosition
+	backend.mutex.RUnlock()
+
+	if from != "" {
+		var ok bool
+		if fromPosition, ok = backend.streamToken(from); !ok {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong from token")
+		}
+	}
+
+	response := &peek.Response{
+		Start: strconv.FormatInt(fromPosition, 10),
+		End:   strconv.FormatInt(fromPosition, 10),
+		Chunk: []events.RoomEvent{}}
+
+	if timeout > peek.MaxTimeout {
+		timeout = peek.MaxTimeout
+	}
+	deadline := time.After(timeout)
+
+	for {
+		// Notifier is taken before events are read, so event stored in between isn't missed
+		notifier := backend.eventNotifier()
+
+		// Visibility of new events depends on state changes which could happen while waiting
+		checker = backend.newVisibilityChecker(room.ID(), userID)
+
+		for _, stored := range backend.roomEvents(room.ID(), fromPosition, 0, false, nil, checker) {
+			event, err := stored.roomEvent()
+			if err != nil {
+				continue
+			}
+
+			response.Chunk = append(response.Chunk, *event)
+			response.End = strconv.FormatInt(stored.Position, 10)
+		}
+
+		if len(response.Chunk) != 0 {
+			return response, nil
+		}
+
+		select {
+		case <-notifier:
+		case <-deadline:
+			return response, nil
+		}
+	}
+}
+
+// eventNotifier returns channel which is closed when next event is stored
+func (backend *Backend) eventNotifier() <-chan struct{} {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	return backend.newEvents
+}
+
+// notifyWaiters wakes up clients waiting for new events. Backend mutex must be locked.
+func (backend *Backend) notifyWaiters() {
+	close(backend.newEvents)
+	backend.newEvents = make(chan struct{})
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+)
+
+func TestPeekMessages(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1", Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+
+	_, err = backend.PeekMessages(room, "", "", messages.DirectionBackward, 10, nil)
+	assert.Error(t, err)
+
+	setHistoryVisibility(t, backend, room, user, rooms.HistoryVisibilityWorldReadable)
+	assert.NoError(t, user.SendMessage(room, "hello"))
+
+	response, err := backend.PeekMessages(room, "", "", messages.DirectionBackward, 10, nil)
+	assert.NoError(t, err)
+	assert.Len(t, messageBodies(response.Chunk), 1)
+}
+
+func TestRoomInitialSync(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1", Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "message1"))
+	assert.NoError(t, user1.SendMessage(room, "message2"))
+
+	response, err := user1.RoomInitialSync(room)
+	assert.NoError(t, err)
+	assert.Equal(t, room.ID(), response.RoomID)
+	assert.Equal(t, events.MembershipJoin, response.Membership)
+	assert.Equal(t, createroom.VisibilityTypePrivate, response.Visibility)
+	assert.NotEmpty(t, response.State)
+
+	bodies := messageBodies(response.Messages.Chunk)
+	assert.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], "message1")
+	assert.Contains(t, bodies[1], "message2")
+
+	// Room isn't world readable, so it can't be viewed by non-members
+	_, err = user2.RoomInitialSync(room)
+	assert.Error(t, err)
+	_, err = backend.PeekRoomInitialSync(room)
+	assert.Error(t, err)
+
+	setHistoryVisibility(t, backend, room, user1, rooms.HistoryVisibilityWorldReadable)
+
+	response, err = backend.PeekRoomInitialSync(room)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Membership)
+	assert.NotEmpty(t, response.State)
+}
+
+func TestPeekEvents(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1", Preset: createroom.PublicChat})
+	assert.NoError(t, err)
+
+	_, err = backend.PeekEvents(room, "", 0)
+	assert.Error(t, err)
+
+	setHistoryVisibility(t, backend, room, user, rooms.HistoryVisibilityWorldReadable)
+
+	// No new events until timeout
+	response, err := backend.PeekEvents(room, "", 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Chunk)
+	assert.Equal(t, response.Start, response.End)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		user.SendMessage(room, "hello")
+	}()
+
+	response, err = backend.PeekEvents(room, response.End, time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, messageBodies(response.Chunk), 1)
+
+	// Events after end token are returned immediately
+	assert.NoError(t, user.SendMessage(room, "message1"))
+	assert.NoError(t, user.SendMessage(room, "message2"))
+
+	response, err = backend.PeekEvents(room, response.End, time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, messageBodies(response.Chunk), 2)
+}
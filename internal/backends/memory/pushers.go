@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+	"github.com/signaller-matrix/signaller/internal/pushgateway"
+)
+
+const (
+	maxPushKeyLength = 512
+	maxAppIDLength   = 64
+)
+
+type pusher struct {
+	pushers.Pusher
+	createdTs int64 // unix timestamp (in seconds) of pushkey update
+}
+
+func (user *User) Pushers() []pushers.Pusher {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	result := make([]pushers.Pusher, 0, len(user.pushers))
+	for _, p := range user.pushers {
+		result = append(result, p.Pusher)
+	}
+
+	return result
+}
+
+func (user *User) SetPusher(request pushers.SetRequest) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	if request.AppID == "" || request.PushKey == "" {
+		return models.NewError(models.M_MISSING_PARAM, "app_id and pushkey are required")
+	}
+
+	// null kind deletes pusher
+	if request.Kind == "" {
+		user.removePusher(request.AppID, request.PushKey)
+		return nil
+	}
+
+	if request.Kind != pushers.KindHTTP {
+		return models.NewError(models.M_INVALID_PARAM, "unsupported pusher kind "+string(request.Kind))
+	}
+
+	if len(request.PushKey) > maxPushKeyLength || len(request.AppID) > maxAppIDLength {
+		return models.NewError(models.M_INVALID_PARAM, "pushkey or app_id is too long")
+	}
+
+	if err := pushgateway.ValidateURL(request.Data.URL); err != nil {
+		return models.NewError(models.M_INVALID_PARAM, err.Error())
+	}
+
+	if !request.Append {
+		user.backend.mutex.RLock()
+		var users []*User
+		for _, u := range user.backend.data {
+			if u.ID() != user.ID() {
+				users = append(users, u.(*User))
+			}
+		}
+		user.backend.mutex.RUnlock()
+
+		for _, u := range users {
+			u.removePusher(request.AppID, request.PushKey)
+		}
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	newPusher := pusher{
+		Pusher:    request.Pusher,
+		createdTs: time.Now().Unix()}
+
+	for i, p := range user.pushers {
+		if p.AppID == request.AppID && p.PushKey == request.PushKey {
+			user.pushers[i] = newPusher
+			return nil
+		}
+	}
+
+	user.pushers = append(user.pushers, newPusher)
+
+	return nil
+}
+
+func (user *User) removePusher(appID, pushKey string) {
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	for i, p := range user.pushers {
+		if p.AppID == appID && p.PushKey == pushKey {
+			user.pushers = append(user.pushers[:i], user.pushers[i+1:]...)
+			return
+		}
+	}
+}
+
+// disablePusher removes pusher which can't receive notifications
+func (backend *Backend) disablePusher(userID string, p pushers.Pusher) {
+	if user := backend.userByID(userID); user != nil {
+		user.removePusher(p.AppID, p.PushKey)
+	}
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+	"github.com/signaller-matrix/signaller/internal/pushgateway"
+)
+
+// pushContext is evaluation context of push rules for one receiver of event
+type pushContext struct {
+	user   *User
+	room   *Room
+	sender string
+}
+
+func (ctx *pushContext) UserDisplayName() string {
+	return ctx.user.Name() // TODO: use display name from member event when profiles will be implemented
+}
+
+func (ctx *pushContext) RoomMemberCount() int {
+	return len(ctx.room.Users())
+}
+
+func (ctx *pushContext) SenderHasNotificationPermission(key string) bool {
+	return ctx.room.powerLevel(ctx.sender) >= ctx.room.notificationPowerLevel(key)
+}
+
+// evaluatePushRules evaluates push rules of all room members and invitee of invite event except sender
+// and updates their unread notification counters
+func (backend *Backend) evaluatePushRules(event *events.RoomEvent) {
+	backend.mutex.RLock()
+	room, exists := backend.rooms[event.RoomID]
+	backend.mutex.RUnlock()
+
+	if !exists {
+		return
+	}
+	memRoom := room.(*Room)
+
+	entry, _ := backend.storedEvent(event.EventID)
+
+	for _, member := range backend.pushReceivers(memRoom, event) {
+		if member.ID() == event.Sender {
+			continue
+		}
+		memUser := member.(*User)
+
+		if memUser.ignoredUsers()[event.Sender] {
+			continue
+		}
+
+		memUser.mutex.RLock()
+		ruleset := memUser.pushRules.Copy()
+		memUser.mutex.RUnlock()
+
+		result := ruleset.Evaluate(event, &pushContext{user: memUser, room: memRoom, sender: event.Sender})
+		if !result.Notify {
+			continue
+		}
+
+		memUser.mutex.Lock()
+		counts := memUser.unreadNotifications[event.RoomID]
+		counts.NotificationCount++
+		if result.Highlight {
+			counts.HighlightCount++
+		}
+		memUser.unreadNotifications[event.RoomID] = counts
+		memUser.addNotification(event, entry.Position, result.Actions)
+
+		unread := 0
+		for _, roomCounts := range memUser.unreadNotifications {
+			unread += roomCounts.NotificationCount
+		}
+		userPushers := append([]pusher(nil), memUser.pushers...)
+		memUser.mutex.Unlock()
+
+		for _, p := range userPushers {
+			backend.pushWorker.Enqueue(pushgateway.Delivery{
+				UserID:       memUser.ID(),
+				Pusher:       p.Pusher,
+				Notification: pushgateway.NewNotification(event, p.Pusher, p.createdTs, unread, result.Actions)})
+		}
+	}
+}
+
+// pushReceivers returns joined members of room and invitee of invite event,
+// who isn't joined yet but must be notified by .m.rule.invite_for_me
+func (backend *Backend) pushReceivers(room *Room, event *events.RoomEvent) []internal.User {
+	receivers := room.Users()
+	if event.EType != events.Member || event.StateKey == nil {
+		return receivers
+	}
+
+	var content events.EventContent
+	if json.Unmarshal(event.ContentData, &content) != nil || content.Membership != events.MembershipInvite {
+		return receivers
+	}
+
+	invitee := backend.userByID(*event.StateKey)
+	if invitee == nil || room.isJoined(invitee.ID()) {
+		return receivers
+	}
+
+	return append(append([]internal.User(nil), receivers...), invitee)
+}
+
+func (user *User) PushRules() pushrules.Ruleset {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	return user.pushRules.Copy()
+}
+
+func (user *User) AddPushRule(kind pushrules.Kind, rule pushrules.PushRule, before, after string) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	return user.pushRules.AddRule(kind, rule, before, after)
+}
+
+func (user *User) DeletePushRule(kind pushrules.Kind, ruleID string) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	return user.pushRules.DeleteRule(kind, ruleID)
+}
+
+func (user *User) SetPushRuleEnabled(kind pushrules.Kind, ruleID string, enabled bool) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	return user.pushRules.SetEnabled(kind, ruleID, enabled)
+}
+
+func (user *User) SetPushRuleActions(kind pushrules.Kind, ruleID string, actions []pushrules.Action) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	return user.pushRules.SetActions(kind, ruleID, actions)
+}
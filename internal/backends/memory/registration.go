@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/admin"
+)
+
+const defaultRegistrationTokenLength = 16
+
+// registrationTokenRegexp matches allowed registration tokens
+// https://spec.matrix.org/v1.2/client-server-api/#token-authenticated-registration
+var registrationTokenRegexp = regexp.MustCompile(`^[A-Za-z0-9._~-]{1,64}$`)
+
+// RegistrationTokens returns all registration tokens sorted by token
+func (backend *Backend) RegistrationTokens() []admin.RegistrationToken {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	result := []admin.RegistrationToken{}
+	for _, token := range backend.registrationTokens {
+		result = append(result, *token)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Token < result[j].Token
+	})
+
+	return result
+}
+
+// RegistrationToken returns registration token
+func (backend *Backend) RegistrationToken(token string) (admin.RegistrationToken, models.ApiError) {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	registrationToken, exists := backend.registrationTokens[token]
+	if !exists {
+		return admin.RegistrationToken{}, models.NewError(models.M_NOT_FOUND, "registration token not found")
+	}
+
+	return *registrationToken, nil
+}
+
+// CreateRegistrationToken creates registration token. If token value is empty, random token of specified length is generated.
+func (backend *Backend) CreateRegistrationToken(request admin.NewRegistrationTokenRequest) (admin.RegistrationToken, models.ApiError) {
+	if request.Token == "" {
+		if request.Length == 0 {
+			request.Length = defaultRegistrationTokenLength
+		}
+
+		if request.Length < 0 || request.Length > 64 {
+			return admin.RegistrationToken{}, models.NewError(models.M_INVALID_PARAM, "length must be between 1 and 64")
+		}
+
+		request.Token = internal.RandomString((request.Length + 1) / 2)[:request.Length]
+	} else if !registrationTokenRegexp.MatchString(request.Token) {
+		return admin.RegistrationToken{}, models.NewError(models.M_INVALID_PARAM, "token must consist of up to 64 characters of A-Z, a-z, 0-9, '.', '_', '~' and '-'")
+	}
+
+	if apiErr := validateRegistrationTokenLimits(request.UsesAllowed, request.ExpiryTime); apiErr != nil {
+		return admin.RegistrationToken{}, apiErr
+	}
+
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if _, exists := backend.registrationTokens[request.Token]; exists {
+		return admin.RegistrationToken{}, models.NewError(models.M_INVALID_PARAM, "registration token already exists")
+	}
+
+	registrationToken := &admin.RegistrationToken{
+		Token:       request.Token,
+		UsesAllowed: request.UsesAllowed,
+		ExpiryTime:  request.ExpiryTime}
+	backend.registrationTokens[request.Token] = registrationToken
+
+	return *registrationToken, nil
+}
+
+// UpdateRegistrationToken replaces use limit and expiry time of registration token
+func (backend *Backend) UpdateRegistrationToken(token string, usesAllowed *int, expiryTime *int64) (admin.RegistrationToken, models.ApiError) {
+	if apiErr := validateRegistrationTokenLimits(usesAllowed, expiryTime); apiErr != nil {
+		return admin.RegistrationToken{}, apiErr
+	}
+
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	registrationToken, exists := backend.registrationTokens[token]
+	if !exists {
+		return admin.RegistrationToken{}, models.NewError(models.M_NOT_FOUND, "registration token not found")
+	}
+
+	registrationToken.UsesAllowed = usesAllowed
+	registrationToken.ExpiryTime = expiryTime
+
+	return *registrationToken, nil
+}
+
+// DeleteRegistrationToken deletes registration token. Registrations which are in progress with this token will fail.
+func (backend *Backend) DeleteRegistrationToken(token string) models.ApiError {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if _, exists := backend.registrationTokens[token]; !exists {
+		return models.NewError(models.M_NOT_FOUND, "registration token not found")
+	}
+
+	delete(backend.registrationTokens, token)
+
+	return nil
+}
+
+// IsRegistrationTokenValid reports whether registration token exists, isn't expired and has uses left
+func (backend *Backend) IsRegistrationTokenValid(token string) bool {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	registrationToken, exists := backend.registrationTokens[token]
+
+	return exists && isRegistrationTokenUsable(registrationToken, time.Now())
+}
+
+// ReserveRegistrationToken marks one use of registration token as pending. Reserved use must be released
+// with ReleaseRegistrationToken when registration is finished.
+func (backend *Backend) ReserveRegistrationToken(token string) models.ApiError {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	registrationToken, exists := backend.registrationTokens[token]
+	if !exists || !isRegistrationTokenUsable(registrationToken, time.Now()) {
+		return models.NewError(models.M_UNAUTHORIZED, "invalid registration token")
+	}
+
+	registrationToken.Pending++
+
+	return nil
+}
+
+// ReleaseRegistrationToken finishes pending use of registration token. If registration was successful, use is
+// counted as completed.
+func (backend *Backend) ReleaseRegistrationToken(token string, completed bool) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	registrationToken, exists := backend.registrationTokens[token]
+	if !exists {
+		return
+	}
+
+	registrationToken.Pending--
+	if completed {
+		registrationToken.Completed++
+	}
+}
+
+func isRegistrationTokenUsable(token *admin.RegistrationToken, now time.Time) bool {
+	if token.ExpiryTime != nil && now.UnixNano()/int64(time.Millisecond) >= *token.ExpiryTime {
+		return false
+	}
+
+	return token.UsesAllowed == nil || token.Pending+token.Completed < *token.UsesAllowed
+}
+
+func validateRegistrationTokenLimits(usesAllowed *int, expiryTime *int64) models.ApiError {
+	if usesAllowed != nil && *usesAllowed < 0 {
+		return models.NewError(models.M_INVALID_PARAM, "uses_allowed must be non-negative")
+	}
+
+	if expiryTime != nil && *expiryTime < time.Now().UnixNano()/int64(time.Millisecond) {
+		return models.NewError(models.M_INVALID_PARAM, "expiry_time must be in the future")
+	}
+
+	return nil
+}
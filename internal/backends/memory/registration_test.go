@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/admin"
+)
+
+func TestCreateRegistrationToken(t *testing.T) {
+	backend := newTestBackend(t)
+
+	token, err := backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, token.Token, defaultRegistrationTokenLength)
+	assert.Nil(t, token.UsesAllowed)
+	assert.Nil(t, token.ExpiryTime)
+
+	token, err = backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Length: 7})
+	assert.NoError(t, err)
+	assert.Len(t, token.Token, 7)
+
+	usesAllowed := 1
+	token, err = backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Token: "abc", UsesAllowed: &usesAllowed})
+	assert.NoError(t, err)
+	assert.Equal(t, admin.RegistrationToken{Token: "abc", UsesAllowed: &usesAllowed}, token)
+
+	_, err = backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Token: "abc"})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	_, err = backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Token: "a b"})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	_, err = backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Length: 65})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	past := time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)
+	_, err = backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Token: "expired", ExpiryTime: &past})
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	tokens := backend.RegistrationTokens()
+	assert.Len(t, tokens, 3)
+	assert.True(t, sort.SliceIsSorted(tokens, func(i, j int) bool { return tokens[i].Token < tokens[j].Token }))
+}
+
+func TestRegistrationTokenUses(t *testing.T) {
+	backend := newTestBackend(t)
+
+	usesAllowed := 2
+	_, err := backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Token: "abc", UsesAllowed: &usesAllowed})
+	assert.NoError(t, err)
+
+	assert.NoError(t, backend.ReserveRegistrationToken("abc"))
+	assert.NoError(t, backend.ReserveRegistrationToken("abc"))
+
+	// pending uses are counted
+	assert.False(t, backend.IsRegistrationTokenValid("abc"))
+	assert.Equal(t, models.M_UNAUTHORIZED.Code(), backend.ReserveRegistrationToken("abc").Code())
+
+	// failed registration returns use back
+	backend.ReleaseRegistrationToken("abc", false)
+	backend.ReleaseRegistrationToken("abc", true)
+
+	token, err := backend.RegistrationToken("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, token.Pending)
+	assert.Equal(t, 1, token.Completed)
+	assert.True(t, backend.IsRegistrationTokenValid("abc"))
+
+	assert.Equal(t, models.M_UNAUTHORIZED.Code(), backend.ReserveRegistrationToken("unknown").Code())
+}
+
+func TestUpdateRegistrationToken(t *testing.T) {
+	backend := newTestBackend(t)
+
+	usesAllowed := 0
+	_, err := backend.CreateRegistrationToken(admin.NewRegistrationTokenRequest{Token: "abc", UsesAllowed: &usesAllowed})
+	assert.NoError(t, err)
+	assert.False(t, backend.IsRegistrationTokenValid("abc"))
+
+	token, err := backend.UpdateRegistrationToken("abc", nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, token.UsesAllowed)
+	assert.True(t, backend.IsRegistrationTokenValid("abc"))
+
+	// token expires
+	backend.registrationTokens["abc"].ExpiryTime = new(int64)
+	assert.False(t, backend.IsRegistrationTokenValid("abc"))
+
+	_, err = backend.UpdateRegistrationToken("unknown", nil, nil)
+	assert.Equal(t, models.M_NOT_FOUND.Code(), err.Code())
+
+	assert.NoError(t, backend.DeleteRegistrationToken("abc"))
+	assert.False(t, backend.IsRegistrationTokenValid("abc"))
+	assert.Equal(t, models.M_NOT_FOUND.Code(), backend.DeleteRegistrationToken("abc").Code())
+}
+
+func TestCreateUser(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, err := backend.CreateUser("username1", "password1")
+	assert.NoError(t, err)
+	assert.Empty(t, user.(*User).Tokens)
+	assert.False(t, user.IsAdmin())
+
+	_, err = backend.CreateUser("username1", "password1")
+	assert.Equal(t, models.M_USER_IN_USE.Code(), err.Code())
+
+	// validation error doesn't leave backend locked
+	_, err = backend.CreateUser("a", "password1")
+	assert.Equal(t, models.M_INVALID_USERNAME.Code(), err.Code())
+	assert.NotNil(t, backend.GetUserByName("username1"))
+}
@@ -1,22 +1,26 @@
 package memory
 
 import (
+	"encoding/json"
 	"sync"
 
 	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
 )
 
 type Room struct {
-	id            string
-	visibility    createroom.VisibilityType
-	aliasName     string
-	name          string
-	topic         string
-	state         createroom.Preset
-	worldReadable bool
-	guestCanJoin  bool
-	avatarURL     string
+	id         string
+	visibility createroom.VisibilityType
+	aliasName  string
+	name       string
+	topic      string
+	state      createroom.Preset
+	avatarURL  string
+	version    string
+	upgraded   bool // room is being replaced or has been replaced by upgrade
 
 	creator internal.User
 	joined  []internal.User
@@ -53,10 +57,8 @@ func (room *Room) Aliases() []string {
 	defer room.server.mutex.RUnlock()
 
 	var aliases []string
-	for alias, serverRoom := range room.server.roomAliases {
-		if serverRoom.ID() == room.ID() {
-			aliases = append(aliases, alias)
-		}
+	for _, localpart := range room.server.aliasesByRoom[room.ID()] {
+		aliases = append(aliases, internal.GetCanonicalAlias(room.server.hostname, localpart))
 	}
 
 	return aliases
@@ -98,17 +100,11 @@ func (room *Room) State() createroom.Preset {
 }
 
 func (room *Room) WorldReadable() bool {
-	room.mutex.RLock()
-	defer room.mutex.RUnlock()
-
-	return room.worldReadable
+	return room.server.newVisibilityChecker(room.ID(), "").currentHistoryVisibility() == rooms.HistoryVisibilityWorldReadable
 }
 
 func (room *Room) GuestCanJoin() bool {
-	room.mutex.RLock()
-	defer room.mutex.RUnlock()
-
-	return room.guestCanJoin
+	return room.server.guestAccess(room.ID()) == rooms.GuestAccessCanJoin
 }
 
 func (room *Room) AvatarURL() string {
@@ -117,3 +113,109 @@ func (room *Room) AvatarURL() string {
 
 	return room.avatarURL
 }
+
+// isJoined checks that user is joined to room
+func (room *Room) isJoined(userID string) bool {
+	for _, roomMember := range room.Users() {
+		if roomMember.ID() == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// powerLevels returns current power levels of room. Spec defaults are used if room has no power levels event.
+func (room *Room) powerLevels() events.PowerLevelsContent {
+	content := events.NewPowerLevelsContent()
+
+	stateEvent, exists := room.server.currentStateEvent(room.ID(), events.PowerLevels, "")
+	if !exists || json.Unmarshal(stateEvent.Content, &content) != nil {
+		// Room creator has full power in room without power levels
+		if creator := room.Creator(); creator != nil {
+			content.Users[creator.ID()] = 100
+		}
+	}
+
+	return content
+}
+
+// powerLevel returns power level of specified user in room
+func (room *Room) powerLevel(userID string) int {
+	powerLevels := room.powerLevels()
+	return powerLevels.UserLevel(userID)
+}
+
+// notificationPowerLevel returns power level required to trigger notification of specified type
+func (room *Room) notificationPowerLevel(key string) int {
+	return room.powerLevels().Notifications.Room // "room" is the only notification type of spec
+}
+
+// historyVisibilityByPreset returns initial history visibility of room created with preset
+func historyVisibilityByPreset(preset createroom.Preset) rooms.HistoryVisibility {
+	return rooms.HistoryVisibilityShared // all presets of spec use shared history visibility
+}
+
+// guestAccessByPreset returns initial guest access of room created with preset
+func guestAccessByPreset(preset createroom.Preset) rooms.GuestAccess {
+	switch preset {
+	case createroom.PrivateChat, createroom.TrustedPrivateChat:
+		return rooms.GuestAccessCanJoin
+	}
+
+	return rooms.GuestAccessForbidden
+}
+
+// initialPowerLevels returns power levels of room created with preset. Invitees of trusted private chat
+// get the same power level as creator.
+func initialPowerLevels(creatorID string, preset createroom.Preset, invitees []string) events.PowerLevelsContent {
+	content := events.NewPowerLevelsContent()
+	content.Users[creatorID] = 100
+	content.Events = map[events.EventType]int{
+		events.Name:              50,
+		events.PowerLevels:       100,
+		events.HistoryVisibility: 100,
+		events.CanonicalAlias:    50,
+		events.Avatar:            50}
+
+	if preset == createroom.TrustedPrivateChat {
+		for _, invitee := range invitees {
+			content.Users[invitee] = 100
+		}
+	}
+
+	return content
+}
+
+// joinRuleByPreset returns initial join rule of room created with preset
+func joinRuleByPreset(preset createroom.Preset) rooms.JoinRule {
+	if preset == createroom.PublicChat {
+		return rooms.Public
+	}
+
+	return rooms.Invite
+}
+
+// creationContent returns content of create event. Creator and room version of user provided content are overwritten.
+func creationContent(creatorID, roomVersion string, userContent json.RawMessage) (json.RawMessage, models.ApiError) {
+	content := make(map[string]json.RawMessage)
+	if len(userContent) != 0 && (json.Unmarshal(userContent, &content) != nil || content == nil) {
+		return nil, models.NewError(models.M_BAD_JSON, "creation_content must be object")
+	}
+
+	content["creator"], _ = json.Marshal(creatorID)
+	content["room_version"], _ = json.Marshal(roomVersion)
+
+	result, err := json.Marshal(content)
+	if err != nil {
+		return nil, models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
+	return result, nil
+}
+
+// isJSONObject checks that data is JSON object
+func isJSONObject(data json.RawMessage) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal(data, &object) == nil && object != nil
+}
@@ -1,15 +1,18 @@
 package memory
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/signaller-matrix/signaller/internal/models"
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateRoom(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -31,8 +34,91 @@ func TestCreateRoom(t *testing.T) {
 	assert.Equal(t, "!"+room.(*Room).id+":"+backend.hostname, room.ID())
 }
 
+func TestCreateRoomEvents(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{
+		RoomAliasName:   "room1",
+		Name:            "room1",
+		Topic:           "topic",
+		Invite:          []string{user2.ID()},
+		Visibility:      createroom.VisibilityTypePublic,
+		CreationContent: json.RawMessage(`{"m.federate":false,"creator":"@somebody:localhost"}`),
+		InitialState: []events.StateEvent{
+			{Type: string(events.HistoryVisibility), Content: json.RawMessage(`{"history_visibility":"world_readable"}`)},
+			{Type: string(events.Name), Content: json.RawMessage(`{"name":"overridden"}`)}}})
+	assert.NoError(t, err)
+	assert.Equal(t, createroom.PublicChat, room.State())
+	assert.Equal(t, "room1", room.Name())
+	assert.True(t, room.WorldReadable())
+
+	var (
+		types    []events.EventType
+		contents = make(map[events.EventType]string)
+	)
+	for _, stored := range backend.roomEvents(room.ID(), 0, 0, false, nil, nil) {
+		event, err := stored.roomEvent()
+		assert.NoError(t, err)
+		types = append(types, event.EType)
+		contents[event.EType] = string(event.ContentData)
+	}
+
+	assert.Equal(t, []events.EventType{
+		events.Create,
+		events.Member,
+		events.PowerLevels,
+		events.JoinRules,
+		events.HistoryVisibility,
+		events.GuestAccess,
+		events.HistoryVisibility,
+		events.Name,
+		events.Name,
+		events.Topic,
+		events.Member,
+		events.CanonicalAlias}, types)
+
+	assert.JSONEq(t, `{"creator":"@user1:localhost","room_version":"`+defaultRoomVersion+`","m.federate":false}`, contents[events.Create])
+	assert.JSONEq(t, `{"join_rule":"public"}`, contents[events.JoinRules])
+	assert.JSONEq(t, `{"name":"room1"}`, contents[events.Name])
+	assert.JSONEq(t, `{"alias":"#room1:localhost"}`, contents[events.CanonicalAlias])
+	assert.Equal(t, room, backend.GetRoomByAlias("#room1:localhost"))
+}
+
+func TestCreateRoomInvalidRequest(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	_, err = user.CreateRoom(createroom.Request{RoomVersion: "unknown"})
+	assert.Equal(t, models.M_UNSUPPORTED_ROOM_VERSION.Code(), err.Code())
+
+	_, err = user.CreateRoom(createroom.Request{CreationContent: json.RawMessage(`[]`)})
+	assert.Error(t, err)
+
+	_, err = user.CreateRoom(createroom.Request{InitialState: []events.StateEvent{{Type: string(events.Create), Content: json.RawMessage(`{}`)}}})
+	assert.Error(t, err)
+
+	_, err = user.CreateRoom(createroom.Request{InitialState: []events.StateEvent{{Type: string(events.Topic), Content: json.RawMessage(`"topic"`)}}})
+	assert.Error(t, err)
+
+	assert.Empty(t, backend.rooms)
+
+	// Room name is taken from initial state without name parameter
+	room, err := user.CreateRoom(createroom.Request{InitialState: []events.StateEvent{{Type: string(events.Name), Content: json.RawMessage(`{"name":"room1"}`)}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "room1", room.Name())
+	assert.Equal(t, createroom.PrivateChat, room.State())
+}
+
 func TestCreateAlreadyExistingRoom(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, _ := backend.Register("user1", "", "")
 
@@ -49,7 +135,7 @@ func TestCreateAlreadyExistingRoom(t *testing.T) {
 }
 
 func TestSetRoomTopic(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, _ := backend.Register("user1", "", "")
 
@@ -67,7 +153,7 @@ func TestSetRoomTopic(t *testing.T) {
 }
 
 func TestSetRoomTopicWithnprivelegedUser(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	creator, _, _ := backend.Register("user1", "", "")
 	user2, _, _ := backend.Register("user2", "", "")
@@ -85,7 +171,7 @@ func TestSetRoomTopicWithnprivelegedUser(t *testing.T) {
 }
 
 func TestLeaveRoom(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, _ := backend.Register("user1", "", "")
 
@@ -107,7 +193,7 @@ func TestLeaveRoom(t *testing.T) {
 }
 
 func TestRoomUserCount(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user1, _, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -123,27 +209,3 @@ func TestRoomUserCount(t *testing.T) {
 
 	// TODO: add join another user test
 }
-
-func TestRoomAliases(t *testing.T) {
-	backend := NewBackend("localhost")
-
-	user1, _, err := backend.Register("user1", "", "")
-	assert.NoError(t, err)
-
-	request := createroom.Request{
-		RoomAliasName: "room1",
-		Name:          "room1",
-		Topic:         "topic"}
-
-	room, err := user1.CreateRoom(request)
-	assert.NoError(t, err)
-	assert.NotNil(t, room)
-
-	var expecterRoomAliases = []string{"alias1", "alias2"}
-	for _, alias := range expecterRoomAliases {
-		err := user1.AddRoomAlias(room, alias)
-		assert.NoError(t, err)
-	}
-
-	assert.Equal(t, expecterRoomAliases, room.Aliases())
-}
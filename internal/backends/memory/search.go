@@ -0,0 +1,330 @@
+package memory
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/search"
+)
+
+// searchIndex is inverted index of searchable event fields
+type searchIndex struct {
+	postings  map[string]map[string]int // token -> event ID -> count of token in event
+	documents map[string]searchDocument // event ID -> indexed document
+	mutex     sync.RWMutex
+}
+
+type searchDocument struct {
+	roomID   string
+	key      string // one of search keys, e.g. content.body
+	position int64
+	length   int // count of tokens in document
+}
+
+type searchHit struct {
+	eventID  string
+	roomID   string
+	position int64
+	rank     float64
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings:  make(map[string]map[string]int),
+		documents: make(map[string]searchDocument)}
+}
+
+// add indexes message body, room name or topic of event
+func (index *searchIndex) add(event *events.RoomEvent, position int64) {
+	key, text := searchableText(event)
+	if key == "" {
+		return
+	}
+
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return
+	}
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.documents[event.EventID] = searchDocument{
+		roomID:   event.RoomID,
+		key:      key,
+		position: position,
+		length:   len(tokens)}
+
+	for _, token := range tokens {
+		if index.postings[token] == nil {
+			index.postings[token] = make(map[string]int)
+		}
+		index.postings[token][event.EventID]++
+	}
+}
+
+// search returns documents which contain all tokens, have one of keys and belong to one of rooms.
+// Candidates are taken from the shortest postings list and intersected with postings of other tokens.
+// Documents are ranked with tf-idf.
+func (index *searchIndex) search(tokens []string, keys []string, rooms map[string]bool) []searchHit {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	postings := make([]map[string]int, len(tokens))
+	shortest := 0
+	for i, token := range tokens {
+		postings[i] = index.postings[token]
+		if len(postings[i]) == 0 {
+			return nil
+		}
+
+		if len(postings[i]) < len(postings[shortest]) {
+			shortest = i
+		}
+	}
+
+	var hits []searchHit
+
+	for eventID := range postings[shortest] {
+		matched := true
+		for _, tokenPostings := range postings {
+			if tokenPostings[eventID] == 0 {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		document := index.documents[eventID]
+		if !rooms[document.roomID] || !inStringSlice(keys, document.key) {
+			continue
+		}
+
+		var rank float64
+		for _, tokenPostings := range postings {
+			idf := math.Log(1 + float64(len(index.documents))/float64(len(tokenPostings)))
+			rank += float64(tokenPostings[eventID]) / float64(document.length) * idf
+		}
+
+		hits = append(hits, searchHit{
+			eventID:  eventID,
+			roomID:   document.roomID,
+			position: document.position,
+			rank:     rank})
+	}
+
+	return hits
+}
+
+// searchableText returns search key and text of event which should be indexed
+func searchableText(event *events.RoomEvent) (key, text string) {
+	var content struct {
+		Body  string `json:"body"`
+		Name  string `json:"name"`
+		Topic string `json:"topic"`
+	}
+
+	if json.Unmarshal(event.ContentData, &content) != nil {
+		return "", ""
+	}
+
+	switch event.EType {
+	case events.Message:
+		return search.KeyContentBody, content.Body
+	case events.Name:
+		return search.KeyContentName, content.Name
+	case events.Topic:
+		return search.KeyContentTopic, content.Topic
+	}
+
+	return "", ""
+}
+
+// tokenize splits text to lowercase words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func inStringSlice(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Search searches room events which user can see. nextBatch is offset of first returned result.
+func (user *User) Search(criteria search.RoomEventsCriteria, nextBatch string) (*search.RoomEventsResults, models.ApiError) {
+	if err := user.guestAccessForbidden(); err != nil {
+		return nil, err
+	}
+
+	var offset int
+	if nextBatch != "" {
+		var err error
+		offset, err = strconv.Atoi(nextBatch)
+		if err != nil || offset < 0 {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong next_batch token")
+		}
+	}
+
+	orderBy := criteria.OrderBy
+	if orderBy == "" {
+		orderBy = search.OrderByRank
+	}
+	if orderBy != search.OrderByRank && orderBy != search.OrderByRecent {
+		return nil, models.NewError(models.M_INVALID_PARAM, "wrong order_by: "+string(orderBy))
+	}
+
+	keys := criteria.Keys
+	if len(keys) == 0 {
+		keys = search.Keys
+	}
+	for _, key := range keys {
+		if !inStringSlice(search.Keys, key) {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong key: "+key)
+		}
+	}
+
+	limit := search.DefaultLimit
+	if criteria.Filter != nil && criteria.Filter.Limit > 0 {
+		limit = criteria.Filter.Limit
+	}
+
+	rooms := make(map[string]bool)
+	checkers := make(map[string]*visibilityChecker)
+	for _, room := range user.backend.memberRooms(user.ID()) {
+		checker := user.backend.newVisibilityChecker(room.ID(), user.ID())
+		if checker.wasMember() {
+			rooms[room.ID()] = true
+			checkers[room.ID()] = checker
+		}
+	}
+
+	tokens := tokenize(criteria.SearchTerm)
+	hits := user.backend.searchIndex.search(tokens, keys, rooms)
+
+	var results []search.Result
+	var positions []int64
+	for _, hit := range hits {
+		stored, exists := user.backend.storedEvent(hit.eventID)
+		if !exists || !checkers[hit.roomID].canSee(hit.position) {
+			continue
+		}
+
+		event, err := stored.roomEvent()
+		if err != nil || checkers[hit.roomID].isIgnored(event) {
+			continue
+		}
+
+		if criteria.Filter != nil && !criteria.Filter.Match(event) {
+			continue
+		}
+
+		results = append(results, search.Result{Rank: hit.rank, Result: *event})
+		positions = append(positions, hit.position)
+	}
+
+	sort.Sort(byOrder{results: results, positions: positions, orderBy: orderBy})
+
+	response := &search.RoomEventsResults{
+		Count:      len(results),
+		Highlights: tokens,
+		Results:    []search.Result{}}
+
+	if offset >= len(results) {
+		return response, nil
+	}
+
+	end := offset + limit
+	if end < len(results) {
+		response.NextBatch = strconv.Itoa(end)
+	} else {
+		end = len(results)
+	}
+
+	for i := offset; i < end; i++ {
+		result := results[i]
+		if criteria.EventContext != nil {
+			result.Context = user.backend.eventContext(result.Result.RoomID, positions[i], criteria.EventContext, checkers[result.Result.RoomID])
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+// eventContext returns events around event at specified position of room
+func (backend *Backend) eventContext(roomID string, position int64, include *search.IncludeEventContext, checker *visibilityChecker) *search.EventContext {
+	beforeLimit, afterLimit := search.DefaultContextLimit, search.DefaultContextLimit
+	if include.BeforeLimit != nil {
+		beforeLimit = *include.BeforeLimit
+	}
+	if include.AfterLimit != nil {
+		afterLimit = *include.AfterLimit
+	}
+
+	context := &search.EventContext{
+		EventsBefore: []events.RoomEvent{},
+		EventsAfter:  []events.RoomEvent{}}
+
+	if beforeLimit > 0 {
+		for _, stored := range backend.roomEvents(roomID, position, beforeLimit, true, nil, checker) {
+			if event, err := stored.roomEvent(); err == nil {
+				context.EventsBefore = append(context.EventsBefore, *event)
+				context.Start = strconv.FormatInt(stored.Position, 10)
+			}
+		}
+	}
+
+	if afterLimit > 0 {
+		for _, stored := range backend.roomEvents(roomID, position, afterLimit, false, nil, checker) {
+			if event, err := stored.roomEvent(); err == nil {
+				context.EventsAfter = append(context.EventsAfter, *event)
+				context.End = strconv.FormatInt(stored.Position, 10)
+			}
+		}
+	}
+
+	// TODO: profile_info
+
+	return context
+}
+
+type byOrder struct {
+	results   []search.Result
+	positions []int64
+	orderBy   search.OrderBy
+}
+
+func (order byOrder) Len() int { return len(order.results) }
+
+func (order byOrder) Swap(i, j int) {
+	order.results[i], order.results[j] = order.results[j], order.results[i]
+	order.positions[i], order.positions[j] = order.positions[j], order.positions[i]
+}
+
+func (order byOrder) Less(i, j int) bool {
+	if order.orderBy == search.OrderByRank && order.results[i].Rank != order.results[j].Rank {
+		return order.results[i].Rank > order.results[j].Rank
+	}
+
+	return order.positions[i] > order.positions[j]
+}
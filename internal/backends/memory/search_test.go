@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+	"github.com/signaller-matrix/signaller/internal/models/search"
+)
+
+func TestSearch(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "Decisions", Topic: "architecture decisions"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "we decided to use Postgres"))
+	assert.NoError(t, user1.SendMessage(room, "lunch?"))
+	assert.NoError(t, user1.SendMessage(room, "postgres, postgres and only postgres"))
+	assert.NoError(t, user1.SendMessage(room, "ok"))
+
+	// Rank order
+	results, err := user1.Search(search.RoomEventsCriteria{SearchTerm: "POSTGRES"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, results.Count)
+	assert.Equal(t, []string{"postgres"}, results.Highlights)
+	assert.Contains(t, string(results.Results[0].Result.ContentData), "only postgres")
+	assert.True(t, results.Results[0].Rank > results.Results[1].Rank)
+
+	// Recent order with pagination
+	criteria := search.RoomEventsCriteria{
+		SearchTerm: "postgres",
+		OrderBy:    search.OrderByRecent,
+		Filter:     &filter.RoomEventFilter{Limit: 1}}
+
+	results, err = user1.Search(criteria, "")
+	assert.NoError(t, err)
+	assert.Len(t, results.Results, 1)
+	assert.Contains(t, string(results.Results[0].Result.ContentData), "only postgres")
+	assert.NotEmpty(t, results.NextBatch)
+
+	results, err = user1.Search(criteria, results.NextBatch)
+	assert.NoError(t, err)
+	assert.Len(t, results.Results, 1)
+	assert.Contains(t, string(results.Results[0].Result.ContentData), "decided")
+	assert.Empty(t, results.NextBatch)
+
+	// Context of result
+	one := 1
+	criteria = search.RoomEventsCriteria{
+		SearchTerm:   "lunch",
+		EventContext: &search.IncludeEventContext{BeforeLimit: &one}}
+
+	results, err = user1.Search(criteria, "")
+	assert.NoError(t, err)
+	assert.Len(t, results.Results, 1)
+	context := results.Results[0].Context
+	assert.Len(t, context.EventsBefore, 1)
+	assert.Contains(t, string(context.EventsBefore[0].ContentData), "decided")
+	assert.Len(t, context.EventsAfter, 2)
+	assert.Contains(t, string(context.EventsAfter[0].ContentData), "only postgres")
+	assert.NotEmpty(t, context.Start)
+	assert.NotEmpty(t, context.End)
+
+	// Room name and topic
+	results, err = user1.Search(search.RoomEventsCriteria{SearchTerm: "decisions", Keys: []string{search.KeyContentTopic}}, "")
+	assert.NoError(t, err)
+	assert.Len(t, results.Results, 1)
+	assert.Equal(t, events.Topic, results.Results[0].Result.EType)
+
+	// Filter
+	results, err = user1.Search(search.RoomEventsCriteria{
+		SearchTerm: "postgres",
+		Filter:     &filter.RoomEventFilter{NotSenders: []string{user1.ID()}}}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, results.Results)
+
+	// Not joined room is not searched
+	results, err = user2.Search(search.RoomEventsCriteria{SearchTerm: "postgres"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, results.Count)
+
+	_, err = user1.Search(search.RoomEventsCriteria{SearchTerm: "postgres", OrderBy: "wrong"}, "")
+	assert.Error(t, err)
+}
+
+func TestSearchIndexIntersection(t *testing.T) {
+	index := newSearchIndex()
+	add := func(eventID, roomID, body string, position int64) {
+		content, _ := json.Marshal(map[string]string{"body": body})
+		index.add(&events.RoomEvent{EventID: eventID, RoomID: roomID, EType: events.Message, ContentData: content}, position)
+	}
+
+	add("event1", "room1", "red apple", 1)
+	add("event2", "room1", "green apple", 2)
+	add("event3", "room1", "red car", 3)
+	add("event4", "room2", "red apple apple", 4)
+
+	rooms := map[string]bool{"room1": true, "room2": true}
+
+	hits := index.search([]string{"red", "apple"}, search.Keys, rooms)
+	var eventIDs []string
+	for _, hit := range hits {
+		eventIDs = append(eventIDs, hit.eventID)
+	}
+	assert.ElementsMatch(t, []string{"event1", "event4"}, eventIDs)
+
+	hits = index.search([]string{"red", "apple"}, search.Keys, map[string]bool{"room1": true})
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, "event1", hits[0].eventID)
+	}
+
+	assert.Empty(t, index.search([]string{"red", "banana"}, search.Keys, rooms))
+	assert.Empty(t, index.search([]string{"apple"}, []string{search.KeyContentName}, rooms))
+}
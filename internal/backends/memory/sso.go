@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+// ssoPasswordSize is size of random password of SSO users, the password is never revealed,
+// so SSO users can't log in with password
+const ssoPasswordSize = 32
+
+// ssoSubjectKey returns key of SSO user in backend index
+func ssoSubjectKey(issuer, subject string) string {
+	return issuer + "\x00" + subject
+}
+
+// GetUserBySSOSubject returns user which is linked to subject of SSO provider
+func (backend *Backend) GetUserBySSOSubject(issuer, subject string) internal.User {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	username, exists := backend.ssoSubjects[ssoSubjectKey(issuer, subject)]
+	if !exists {
+		return nil
+	}
+
+	return backend.data[username]
+}
+
+// CreateSSOUser registers user which is linked to subject of SSO provider. Username is normalized and validated
+// with username validation func. Existing users are never linked, so SSO can't take over accounts.
+func (backend *Backend) CreateSSOUser(username, issuer, subject string) (internal.User, models.ApiError) {
+	username = internal.NormalizeUsername(username)
+
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	key := ssoSubjectKey(issuer, subject)
+	if _, exists := backend.ssoSubjects[key]; exists {
+		return nil, models.NewError(models.M_USER_IN_USE, "SSO user is already registered")
+	}
+
+	user, err := backend.createUser(username, internal.RandomString(ssoPasswordSize))
+	if err != nil {
+		return nil, err
+	}
+
+	backend.ssoSubjects[key] = username
+
+	return user, nil
+}
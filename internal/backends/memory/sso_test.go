@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+func TestSSOUsers(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, err := backend.CreateSSOUser("Alice", "https://idp.example.com", "42")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", user.Name())
+	assert.Equal(t, user, backend.GetUserBySSOSubject("https://idp.example.com", "42"))
+	assert.Nil(t, backend.GetUserBySSOSubject("https://other.example.com", "42"))
+
+	// password of SSO user is unknown
+	_, _, err = backend.Login("alice", "", "")
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+
+	_, err = backend.CreateSSOUser("alice2", "https://idp.example.com", "42")
+	assert.Equal(t, models.M_USER_IN_USE.Code(), err.Code())
+
+	// existing users are not linked to SSO subjects
+	_, err = backend.CreateUser("bobby", "")
+	assert.NoError(t, err)
+	_, err = backend.CreateSSOUser("bobby", "https://idp.example.com", "43")
+	assert.Equal(t, models.M_USER_IN_USE.Code(), err.Code())
+	assert.Nil(t, backend.GetUserBySSOSubject("https://idp.example.com", "43"))
+
+	_, err = backend.CreateSSOUser("a b", "https://idp.example.com", "44")
+	assert.Equal(t, models.M_INVALID_USERNAME.Code(), err.Code())
+}
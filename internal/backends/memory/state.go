@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+)
+
+// stateID identifies state of room by event type and state key
+type stateID struct {
+	eventType events.EventType
+	key       string
+}
+
+// stateEntry is state event which is in force since position in stream
+type stateEntry struct {
+	id       stateID
+	position int64
+	event    events.StateEvent
+}
+
+// roomStateIndex is state of room which is updated when state events are stored,
+// so state at any position is found without replaying of room history.
+// It is guarded by mutex of backend.
+type roomStateIndex struct {
+	order   []stateID                     // state IDs in order of first appearance
+	history map[stateID][]stateEntry      // changes of every state in stream order
+	changes []stateEntry                  // all changes of state in stream order
+	current map[stateID]events.StateEvent // state events which are in force now
+
+	visibility []stateChange            // changes of history visibility in stream order
+	membership map[string][]stateChange // user ID -> changes of membership in stream order
+}
+
+func newRoomStateIndex() *roomStateIndex {
+	return &roomStateIndex{
+		history:    make(map[stateID][]stateEntry),
+		current:    make(map[stateID]events.StateEvent),
+		membership: make(map[string][]stateChange)}
+}
+
+// add adds state event stored at position
+func (index *roomStateIndex) add(event *events.RoomEvent, position int64) {
+	entry := stateEntry{
+		id:       stateID{eventType: event.EType, key: *event.StateKey},
+		position: position,
+		event: events.StateEvent{
+			Content:        event.ContentData,
+			Type:           string(event.EType),
+			EventID:        event.EventID,
+			Sender:         event.Sender,
+			OriginServerTs: int(event.OriginServerTs),
+			Unsigned:       event.Unsigned,
+			StateKey:       *event.StateKey}}
+
+	if _, exists := index.history[entry.id]; !exists {
+		index.order = append(index.order, entry.id)
+	}
+	index.history[entry.id] = append(index.history[entry.id], entry)
+	index.changes = append(index.changes, entry)
+	index.current[entry.id] = entry.event
+
+	switch event.EType {
+	case events.HistoryVisibility:
+		var content struct {
+			HistoryVisibility rooms.HistoryVisibility `json:"history_visibility"`
+		}
+		if json.Unmarshal(event.ContentData, &content) == nil {
+			index.visibility = append(index.visibility, stateChange{position, string(content.HistoryVisibility)})
+		}
+	case events.Member:
+		var content events.EventContent
+		if json.Unmarshal(event.ContentData, &content) == nil {
+			index.membership[entry.id.key] = append(index.membership[entry.id.key], stateChange{position, string(content.Membership)})
+		}
+	}
+}
+
+// entryAt returns state entry in force at position
+func (index *roomStateIndex) entryAt(id stateID, position int64) (stateEntry, bool) {
+	history := index.history[id]
+	i := sort.Search(len(history), func(i int) bool { return history[i].position > position })
+	if i == 0 {
+		return stateEntry{}, false
+	}
+
+	return history[i-1], true
+}
+
+// stateAt returns state events in force at position filtered by event type if it is not empty
+func (index *roomStateIndex) stateAt(position int64, eventType events.EventType) []events.StateEvent {
+	state := []events.StateEvent{}
+	for _, id := range index.order {
+		if eventType != "" && id.eventType != eventType {
+			continue
+		}
+
+		if entry, exists := index.entryAt(id, position); exists {
+			state = append(state, entry.event)
+		}
+	}
+
+	return state
+}
+
+// stateBetween returns state events in force at position which were changed after from position
+func (index *roomStateIndex) stateBetween(from, position int64) []events.StateEvent {
+	start := sort.Search(len(index.changes), func(i int) bool { return index.changes[i].position > from })
+
+	state := []events.StateEvent{}
+	changed := make(map[stateID]bool)
+	for _, change := range index.changes[start:] {
+		if change.position > position {
+			break
+		}
+
+		if changed[change.id] {
+			continue
+		}
+		changed[change.id] = true
+
+		entry, _ := index.entryAt(change.id, position)
+		state = append(state, entry.event)
+	}
+
+	return state
+}
+
+// indexState adds state event to state of its room and to rooms of member. Backend mutex must be locked.
+func (backend *Backend) indexState(event events.Event, position int64) {
+	roomEvent, ok := event.(*events.RoomEvent)
+	if !ok || roomEvent.StateKey == nil {
+		return
+	}
+
+	index := backend.roomStates[roomEvent.RoomID]
+	if index == nil {
+		index = newRoomStateIndex()
+		backend.roomStates[roomEvent.RoomID] = index
+	}
+	index.add(roomEvent, position)
+
+	if roomEvent.EType == events.Member {
+		userID := *roomEvent.StateKey
+		if backend.userRooms[userID] == nil {
+			backend.userRooms[userID] = make(map[string]bool)
+		}
+		backend.userRooms[userID][roomEvent.RoomID] = true
+	}
+}
+
+// stateEntryAt returns state of room with specified type and state key in force at position
+func (backend *Backend) stateEntryAt(roomID string, eventType events.EventType, key string, position int64) (stateEntry, bool) {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	index := backend.roomStates[roomID]
+	if index == nil {
+		return stateEntry{}, false
+	}
+
+	return index.entryAt(stateID{eventType: eventType, key: key}, position)
+}
+
+// memberRooms returns rooms which user has been invited to, joined or left
+func (backend *Backend) memberRooms(userID string) []internal.Room {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	rooms := make([]internal.Room, 0, len(backend.userRooms[userID]))
+	for roomID := range backend.userRooms[userID] {
+		if room, exists := backend.rooms[roomID]; exists {
+			rooms = append(rooms, room)
+		}
+	}
+
+	return rooms
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+func TestRoomStateIndex(t *testing.T) {
+	index := newRoomStateIndex()
+
+	index.add(&events.RoomEvent{EventID: "1", EType: events.Name, StateKey: stateKey("")}, 1)
+	index.add(&events.RoomEvent{EventID: "2", EType: events.Member, StateKey: stateKey("@user1:localhost")}, 2)
+	index.add(&events.RoomEvent{EventID: "3", EType: events.Name, StateKey: stateKey("")}, 4)
+	index.add(&events.RoomEvent{EventID: "4", EType: events.Member, StateKey: stateKey("@user2:localhost")}, 5)
+
+	eventIDs := func(state []events.StateEvent) []string {
+		result := []string{}
+		for _, stateEvent := range state {
+			result = append(result, stateEvent.EventID)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{}, eventIDs(index.stateAt(0, "")))
+	assert.Equal(t, []string{"1", "2"}, eventIDs(index.stateAt(3, "")))
+	assert.Equal(t, []string{"3", "2", "4"}, eventIDs(index.stateAt(5, "")))
+	assert.Equal(t, []string{"2", "4"}, eventIDs(index.stateAt(5, events.Member)))
+
+	assert.Equal(t, []string{"3", "4"}, eventIDs(index.stateBetween(2, 5)))
+	assert.Equal(t, []string{"2", "3"}, eventIDs(index.stateBetween(1, 4)))
+
+	entry, exists := index.entryAt(stateID{eventType: events.Name}, 3)
+	assert.True(t, exists)
+	assert.Equal(t, int64(1), entry.position)
+
+	_, exists = index.entryAt(stateID{eventType: events.Member, key: "@user2:localhost"}, 4)
+	assert.False(t, exists)
+
+	assert.Equal(t, "3", index.current[stateID{eventType: events.Name}].EventID)
+}
+
+func TestRoomStateIndexVisibilityChanges(t *testing.T) {
+	index := newRoomStateIndex()
+
+	index.add(&events.RoomEvent{EType: events.HistoryVisibility, StateKey: stateKey(""), ContentData: []byte(`{"history_visibility":"joined"}`)}, 1)
+	index.add(&events.RoomEvent{EType: events.Member, StateKey: stateKey("@user1:localhost"), ContentData: []byte(`{"membership":"join"}`)}, 2)
+	index.add(&events.RoomEvent{EType: events.Member, StateKey: stateKey("@user1:localhost"), ContentData: []byte(`{"membership":"leave"}`)}, 3)
+
+	assert.Equal(t, []stateChange{{1, "joined"}}, index.visibility)
+	assert.Equal(t, []stateChange{{2, "join"}, {3, "leave"}}, index.membership["@user1:localhost"])
+	assert.Empty(t, index.membership["@user2:localhost"])
+}
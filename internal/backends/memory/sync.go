@@ -0,0 +1,334 @@
+package memory
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/common"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+)
+
+const (
+	defaultTimelineLimit = 10
+	maxHeroesCount       = 5
+)
+
+func (user *User) Sync(token string, request mSync.SyncRequest) (response *mSync.SyncReply, err models.ApiError) {
+	syncFilter, err := user.syncFilter(request.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := user.backend
+
+	var sincePosition int64
+	if request.Since != "" {
+		var ok bool
+		if sincePosition, ok = backend.streamToken(request.Since); !ok {
+			return nil, models.NewError(models.M_INVALID_PARAM, "wrong since token")
+		}
+	}
+
+	backend.mutex.RLock()
+	currentPosition := backend.streamPosition
+	device := user.Tokens[token].Device
+	backend.mutex.RUnlock()
+
+	// Client has no state after initial sync, so all members must be sent again
+	if request.Since == "" || request.FullState {
+		user.mutex.Lock()
+		delete(user.lazyLoadedMembers, device)
+		user.mutex.Unlock()
+	}
+
+	response = mSync.BuildEmptySyncReply()
+
+	ignored := user.ignoredUsers()
+	ignoreChanged := user.ignoreChangesBetween(sincePosition, currentPosition)
+
+	for _, room := range backend.memberRooms(user.ID()) {
+		if !matchRoomFilter(syncFilter.Room.Rooms, syncFilter.Room.NotRooms, room.ID()) {
+			continue
+		}
+
+		memberEvent, memberPosition, exists := backend.userMembership(room.ID(), user.ID(), currentPosition)
+		if !exists {
+			continue
+		}
+
+		switch memberContent(memberEvent).Membership {
+		case events.MembershipJoin:
+			// Room is synced from scratch if events of some its member have been hidden or revealed since previous sync
+			if sincePosition != 0 && backend.hasMembers(room.ID(), currentPosition, ignoreChanged) {
+				user.mutex.Lock()
+				delete(user.lazyLoadedMembers[device], room.ID())
+				user.mutex.Unlock()
+
+				response.Rooms.Join[room.ID()] = user.joinedRoomSync(room, 0, currentPosition, true, syncFilter, device)
+				continue
+			}
+
+			response.Rooms.Join[room.ID()] = user.joinedRoomSync(room, sincePosition, currentPosition, request.FullState, syncFilter, device)
+		case events.MembershipInvite:
+			// Invites from ignored users are dropped
+			if memberPosition > sincePosition && !ignored[memberEvent.Sender] {
+				response.Rooms.Invite[room.ID()] = user.invitedRoomSync(room.ID(), memberEvent, memberPosition)
+			}
+		case events.MembershipLeave, events.MembershipBan:
+			if syncFilter.Room.IncludeLeave && memberPosition > sincePosition {
+				response.Rooms.Leave[room.ID()] = user.leftRoomSync(room.ID(), sincePosition, memberPosition, request.FullState, syncFilter, device)
+			}
+		}
+	}
+
+	response.AccountData.Events = user.accountDataBetween("", sincePosition, currentPosition, syncFilter.AccountData.MatchType)
+	response.NextBatch = strconv.FormatInt(currentPosition, 10)
+
+	// TODO: wait for new events until timeout is reached
+
+	return response, nil
+}
+
+// syncFilter returns filter by ID or filter passed as JSON object
+func (user *User) syncFilter(filterParam string) (*common.Filter, models.ApiError) {
+	if filterParam == "" {
+		return &common.Filter{}, nil
+	}
+
+	if strings.HasPrefix(filterParam, "{") {
+		syncFilter := new(common.Filter)
+		if err := json.Unmarshal([]byte(filterParam), syncFilter); err != nil {
+			return nil, models.NewError(models.M_BAD_JSON, "wrong filter: "+err.Error())
+		}
+		return syncFilter, nil
+	}
+
+	syncFilter := user.GetFilterByID(filterParam)
+	if syncFilter == nil {
+		return nil, models.NewError(models.M_NOT_FOUND, "filter not found")
+	}
+
+	return syncFilter, nil
+}
+
+func (user *User) joinedRoomSync(room internal.Room, sincePosition, currentPosition int64, fullState bool, syncFilter *common.Filter, device string) mSync.JoinedRoom {
+	timeline, state := user.roomTimeline(room.ID(), sincePosition, currentPosition, fullState, syncFilter, device)
+
+	return mSync.JoinedRoom{
+		RoomSummary:         user.backend.roomSummary(room.ID(), currentPosition, user.ID()),
+		State:               events.State{Events: state},
+		Timeline:            timeline,
+		AccountData:         user.roomAccountDataSync(room.ID(), sincePosition, currentPosition, syncFilter),
+		UnreadNotifications: user.unreadNotificationCounts(room.ID())}
+}
+
+// roomAccountDataSync returns changes of room account data since previous sync
+func (user *User) roomAccountDataSync(roomID string, sincePosition, untilPosition int64, syncFilter *common.Filter) mSync.AccountData {
+	accountDataFilter := syncFilter.Room.AccountData
+	if !matchRoomFilter(accountDataFilter.Rooms, accountDataFilter.NotRooms, roomID) {
+		return mSync.AccountData{Events: []events.Event{}}
+	}
+
+	return mSync.AccountData{Events: user.accountDataBetween(roomID, sincePosition, untilPosition, accountDataFilter.MatchType)}
+}
+
+// roomTimeline returns timeline of room events after since position up to until position (inclusive)
+// and state of room at the start of timeline
+func (user *User) roomTimeline(roomID string, sincePosition, untilPosition int64, fullState bool, syncFilter *common.Filter, device string) (mSync.Timeline, []events.StateEvent) {
+	backend := user.backend
+	timelineFilter := syncFilter.Room.Timeline
+
+	limit := timelineFilter.Limit
+	if limit <= 0 {
+		limit = defaultTimelineLimit
+	}
+
+	// One more event is requested to find out if timeline is limited
+	var timelineEvents []storedEvent
+	for _, stored := range backend.roomEvents(roomID, untilPosition+1, limit+1, true, &timelineFilter, backend.newVisibilityChecker(roomID, user.ID())) {
+		if stored.Position <= sincePosition {
+			break
+		}
+		timelineEvents = append(timelineEvents, stored)
+	}
+
+	limited := len(timelineEvents) > limit
+	if limited {
+		timelineEvents = timelineEvents[:limit]
+	}
+
+	timelineStart := untilPosition + 1
+	timeline := mSync.Timeline{Events: []events.RoomEvent{}, Limited: limited}
+	senders := make(map[string]bool)
+
+	for i := len(timelineEvents) - 1; i >= 0; i-- {
+		event, err := timelineEvents[i].roomEvent()
+		if err != nil {
+			continue
+		}
+
+		timeline.Events = append(timeline.Events, *event)
+		senders[event.Sender] = true
+	}
+
+	if len(timelineEvents) != 0 {
+		timelineStart = timelineEvents[len(timelineEvents)-1].Position
+	}
+	timeline.PrevBatch = strconv.FormatInt(timelineStart, 10)
+
+	// State up to the start of timeline, or state changes since previous sync
+	var state []events.StateEvent
+	if sincePosition == 0 || fullState {
+		state = backend.roomState(roomID, timelineStart-1)
+	} else {
+		state = backend.roomStateBetween(roomID, sincePosition, timelineStart-1)
+	}
+
+	if syncFilter.Room.State.LazyLoadMembers {
+		state = user.lazyLoadMembers(roomID, device, state, timeline.Events, senders, timelineStart, syncFilter.Room.State.IncludeRedundantMembers)
+	}
+
+	return timeline, state
+}
+
+// invitedRoomSync returns stripped state of room which user has been invited to
+func (user *User) invitedRoomSync(roomID string, invite events.StateEvent, position int64) mSync.InvitedRoom {
+	inviteState := []events.StrippedState{}
+
+	for _, stateEvent := range user.backend.roomState(roomID, position) {
+		switch events.EventType(stateEvent.Type) {
+		case events.Name, events.Avatar, events.JoinRules, events.CanonicalAlias:
+		case events.Member:
+			if stateEvent.StateKey != invite.Sender && stateEvent.StateKey != user.ID() {
+				continue
+			}
+		default:
+			continue
+		}
+
+		inviteState = append(inviteState, events.StrippedState{
+			Content:  stateEvent.Content,
+			StateKey: stateEvent.StateKey,
+			Type:     stateEvent.Type,
+			Sender:   stateEvent.Sender})
+	}
+
+	return mSync.InvitedRoom{InviteState: mSync.InviteState{Events: inviteState}}
+}
+
+// leftRoomSync returns timeline of room up to the moment when user left it
+func (user *User) leftRoomSync(roomID string, sincePosition, leavePosition int64, fullState bool, syncFilter *common.Filter, device string) mSync.LeftRoom {
+	timeline, state := user.roomTimeline(roomID, sincePosition, leavePosition, fullState, syncFilter, device)
+
+	return mSync.LeftRoom{
+		State:       events.State{Events: state},
+		Timeline:    timeline,
+		AccountData: user.roomAccountDataSync(roomID, sincePosition, leavePosition, syncFilter)}
+}
+
+// userMembership returns current member event of user in room and its position in stream
+func (backend *Backend) userMembership(roomID, userID string, position int64) (memberEvent events.StateEvent, memberPosition int64, exists bool) {
+	entry, exists := backend.stateEntryAt(roomID, events.Member, userID, position)
+
+	return entry.event, entry.position, exists
+}
+
+// lazyLoadMembers leaves in state only member events of timeline senders which weren't sent to device yet
+func (user *User) lazyLoadMembers(roomID, device string, state []events.StateEvent, timeline []events.RoomEvent, senders map[string]bool, timelineStart int64, includeRedundant bool) []events.StateEvent {
+	result := []events.StateEvent{}
+	for _, stateEvent := range state {
+		if stateEvent.Type != string(events.Member) {
+			result = append(result, stateEvent)
+		}
+	}
+
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	if user.lazyLoadedMembers[device] == nil {
+		user.lazyLoadedMembers[device] = make(map[string]map[string]string)
+	}
+	sent := user.lazyLoadedMembers[device][roomID]
+	if sent == nil {
+		sent = make(map[string]string)
+		user.lazyLoadedMembers[device][roomID] = sent
+	}
+
+	for _, memberEvent := range user.backend.memberEvents(roomID, timelineStart-1) {
+		if !senders[memberEvent.StateKey] {
+			continue
+		}
+
+		if !includeRedundant && sent[memberEvent.StateKey] == memberEvent.EventID {
+			continue
+		}
+
+		result = append(result, memberEvent)
+		sent[memberEvent.StateKey] = memberEvent.EventID
+	}
+
+	// Member events from timeline are known by client too
+	for _, event := range timeline {
+		if event.EType == events.Member && event.StateKey != nil {
+			sent[*event.StateKey] = event.EventID
+		}
+	}
+
+	return result
+}
+
+// roomSummary returns summary of room for specified user. Heroes are needed
+// to calculate name of room, so they are omitted if room has name or canonical alias.
+func (backend *Backend) roomSummary(roomID string, position int64, userID string) mSync.RoomSummary {
+	summary := mSync.RoomSummary{Heroes: []string{}}
+
+	_, named := backend.stateEntryAt(roomID, events.Name, "", position)
+	_, aliased := backend.stateEntryAt(roomID, events.CanonicalAlias, "", position)
+	needHeroes := !named && !aliased
+
+	for _, memberEvent := range backend.memberEvents(roomID, position) {
+		membership := memberContent(memberEvent).Membership
+
+		switch membership {
+		case events.MembershipJoin:
+			summary.JoinedMemberCount++
+		case events.MembershipInvite:
+			summary.InvitedMemberCount++
+		default:
+			continue
+		}
+
+		if needHeroes && memberEvent.StateKey != userID && len(summary.Heroes) < maxHeroesCount {
+			summary.Heroes = append(summary.Heroes, memberEvent.StateKey)
+		}
+	}
+
+	return summary
+}
+
+func matchRoomFilter(rooms, notRooms []string, roomID string) bool {
+	if inStringSlice(notRooms, roomID) {
+		return false
+	}
+
+	return rooms == nil || inStringSlice(rooms, roomID)
+}
+
+// hasMembers reports whether some of users has member event in room at position
+func (backend *Backend) hasMembers(roomID string, position int64, users map[string]bool) bool {
+	if len(users) == 0 {
+		return false
+	}
+
+	for userID := range users {
+		if _, exists := backend.stateEntryAt(roomID, events.Member, userID, position); exists {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+)
+
+func TestSyncTimeline(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, token, err := backend.Register("user1", "", "device1")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user.SendMessage(room, "message 1"))
+
+	response, err := user.Sync(token, mSync.SyncRequest{Filter: `{"room":{"timeline":{"limit":1}}}`})
+	assert.NoError(t, err)
+	joinedRoom := response.Rooms.Join[room.ID()]
+	assert.Len(t, joinedRoom.Timeline.Events, 1)
+	assert.True(t, joinedRoom.Timeline.Limited)
+	assert.NotEmpty(t, joinedRoom.State.Events)
+	assert.NotEmpty(t, response.NextBatch)
+
+	assert.NoError(t, user.SendMessage(room, "message 2"))
+
+	response, err = user.Sync(token, mSync.SyncRequest{Since: response.NextBatch})
+	assert.NoError(t, err)
+	joinedRoom = response.Rooms.Join[room.ID()]
+	assert.Len(t, joinedRoom.Timeline.Events, 1)
+	assert.Contains(t, string(joinedRoom.Timeline.Events[0].ContentData), "message 2")
+	assert.False(t, joinedRoom.Timeline.Limited)
+	assert.Empty(t, joinedRoom.State.Events)
+
+	_, err = user.Sync(token, mSync.SyncRequest{Filter: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestSyncRoomSummary(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, token, err := backend.Register("user1", "", "device1")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	user3, _, err := backend.Register("user3", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.NoError(t, user1.Invite(room, user3))
+
+	response, err := user1.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+
+	summary := response.Rooms.Join[room.ID()].RoomSummary
+	assert.Equal(t, 2, summary.JoinedMemberCount)
+	assert.Equal(t, 1, summary.InvitedMemberCount)
+	assert.Equal(t, []string{user2.ID(), user3.ID()}, summary.Heroes)
+
+	// Named room doesn't need heroes
+	namedRoom, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(namedRoom))
+
+	response, err = user1.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+
+	summary = response.Rooms.Join[namedRoom.ID()].RoomSummary
+	assert.Equal(t, 2, summary.JoinedMemberCount)
+	assert.Empty(t, summary.Heroes)
+}
+
+func TestSyncLazyLoadMembers(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, token, err := backend.Register("user1", "", "device1")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	user3, _, err := backend.Register("user3", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.NoError(t, user3.JoinRoom(room))
+	assert.NoError(t, user2.SendMessage(room, "message from user2"))
+
+	lazyFilter := `{"room":{"timeline":{"limit":1},"state":{"lazy_load_members":true}}}`
+
+	memberStateKeys := func(response *mSync.SyncReply) []string {
+		var result []string
+		for _, stateEvent := range response.Rooms.Join[room.ID()].State.Events {
+			if stateEvent.Type == string(events.Member) {
+				result = append(result, stateEvent.StateKey)
+			}
+		}
+		return result
+	}
+
+	// Only member event of timeline sender
+	response, err := user1.Sync(token, mSync.SyncRequest{Filter: lazyFilter})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{user2.ID()}, memberStateKeys(response))
+
+	// Member event isn't sent to the same device twice
+	assert.NoError(t, user2.SendMessage(room, "another message from user2"))
+	response, err = user1.Sync(token, mSync.SyncRequest{Filter: lazyFilter, Since: response.NextBatch})
+	assert.NoError(t, err)
+	assert.Empty(t, memberStateKeys(response))
+
+	// ...unless redundant members are requested
+	assert.NoError(t, user2.SendMessage(room, "one more message from user2"))
+	response, err = user1.Sync(token, mSync.SyncRequest{
+		Filter: `{"room":{"timeline":{"limit":1},"state":{"lazy_load_members":true,"include_redundant_members":true}}}`,
+		Since:  response.NextBatch})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{user2.ID()}, memberStateKeys(response))
+
+	// Without lazy loading all members are sent
+	response, err = user1.Sync(token, mSync.SyncRequest{Filter: `{"room":{"timeline":{"limit":1}}}`})
+	assert.NoError(t, err)
+	assert.Len(t, memberStateKeys(response), 3)
+}
+
+func TestSyncInvitedRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, token, err := backend.Register("user2", "", "device1")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1", Topic: "topic"})
+	assert.NoError(t, err)
+	assert.NoError(t, user1.Invite(room, user2))
+
+	response, err := user2.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.NotContains(t, response.Rooms.Join, room.ID())
+	assert.Contains(t, response.Rooms.Invite, room.ID())
+
+	stateTypes := make(map[string][]string)
+	for _, stateEvent := range response.Rooms.Invite[room.ID()].InviteState.Events {
+		stateTypes[stateEvent.Type] = append(stateTypes[stateEvent.Type], stateEvent.StateKey)
+	}
+	assert.Contains(t, stateTypes, string(events.Name))
+	assert.Contains(t, stateTypes, string(events.JoinRules))
+	assert.NotContains(t, stateTypes, string(events.Topic))
+	assert.ElementsMatch(t, []string{user1.ID(), user2.ID()}, stateTypes[string(events.Member)])
+
+	// Invite isn't repeated in incremental sync
+	response, err = user2.Sync(token, mSync.SyncRequest{Since: response.NextBatch})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Rooms.Invite)
+
+	// Joined room replaces invite
+	assert.NoError(t, user2.JoinRoom(room))
+	response, err = user2.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Rooms.Invite)
+	assert.Contains(t, response.Rooms.Join, room.ID())
+}
+
+func TestSyncLeftRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, token, err := backend.Register("user2", "", "device1")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.NoError(t, user1.SendMessage(room, "before leave"))
+	assert.NoError(t, user2.LeaveRoom(room))
+	assert.NoError(t, user1.SendMessage(room, "after leave"))
+
+	response, err := user2.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Rooms.Join)
+	assert.Empty(t, response.Rooms.Leave)
+
+	response, err = user2.Sync(token, mSync.SyncRequest{Filter: `{"room":{"include_leave":true}}`})
+	assert.NoError(t, err)
+	assert.Contains(t, response.Rooms.Leave, room.ID())
+
+	timeline := response.Rooms.Leave[room.ID()].Timeline.Events
+	lastEvent := timeline[len(timeline)-1]
+	assert.Equal(t, events.Member, lastEvent.EType)
+	assert.Equal(t, user2.ID(), *lastEvent.StateKey)
+	assert.Contains(t, string(timeline[len(timeline)-2].ContentData), "before leave")
+
+	// Left room isn't repeated in incremental sync
+	response, err = user2.Sync(token, mSync.SyncRequest{Filter: `{"room":{"include_leave":true}}`, Since: response.NextBatch})
+	assert.NoError(t, err)
+	assert.Empty(t, response.Rooms.Leave)
+}
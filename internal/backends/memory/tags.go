@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/tags"
+)
+
+// Tags returns tags of room. Tags are stored as m.tag room account data.
+// https://matrix.org/docs/spec/client_server/latest#room-tagging
+func (user *User) Tags(room internal.Room) (map[string]tags.Tag, models.ApiError) {
+	content, err := user.getAccountData(room.ID(), string(events.Tag))
+	if err != nil {
+		return make(map[string]tags.Tag), nil
+	}
+
+	return decodeTags(content)
+}
+
+func (user *User) SetTag(room internal.Room, name string, tag tags.Tag) models.ApiError {
+	if len(name) > tags.MaxNameLength {
+		return models.NewError(models.M_INVALID_PARAM, "tag name is too long")
+	}
+
+	return user.updateTags(room, func(roomTags map[string]tags.Tag) {
+		roomTags[name] = tag
+	})
+}
+
+func (user *User) DeleteTag(room internal.Room, name string) models.ApiError {
+	return user.updateTags(room, func(roomTags map[string]tags.Tag) {
+		delete(roomTags, name)
+	})
+}
+
+func (user *User) updateTags(room internal.Room, update func(map[string]tags.Tag)) models.ApiError {
+	return user.updateAccountData(room.ID(), string(events.Tag), func(content json.RawMessage, position int64) (json.RawMessage, models.ApiError) {
+		roomTags := make(map[string]tags.Tag)
+		if content != nil {
+			var err models.ApiError
+			if roomTags, err = decodeTags(content); err != nil {
+				return nil, err
+			}
+		}
+
+		update(roomTags)
+
+		newContent, err := json.Marshal(tags.Response{Tags: roomTags})
+		if err != nil {
+			return nil, models.NewError(models.M_UNKNOWN, err.Error())
+		}
+
+		return newContent, nil
+	})
+}
+
+func decodeTags(content json.RawMessage) (map[string]tags.Tag, models.ApiError) {
+	var response tags.Response
+	if err := json.Unmarshal(content, &response); err != nil {
+		return nil, models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
+	if response.Tags == nil {
+		response.Tags = make(map[string]tags.Tag)
+	}
+
+	return response.Tags, nil
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+	"github.com/signaller-matrix/signaller/internal/models/tags"
+)
+
+func TestTags(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, token, err := backend.Register("user1", "", "device1")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	roomTags, err := user.Tags(room)
+	assert.NoError(t, err)
+	assert.Empty(t, roomTags)
+
+	order := 0.5
+	assert.NoError(t, user.SetTag(room, tags.Favourite, tags.Tag{Order: &order}))
+	assert.NoError(t, user.SetTag(room, "u.work", tags.Tag{}))
+	assert.Error(t, user.SetTag(room, strings.Repeat("a", tags.MaxNameLength+1), tags.Tag{}))
+
+	roomTags, err = user.Tags(room)
+	assert.NoError(t, err)
+	assert.Len(t, roomTags, 2)
+	assert.Equal(t, 0.5, *roomTags[tags.Favourite].Order)
+	assert.Nil(t, roomTags["u.work"].Order)
+
+	response, err := user.Sync(token, mSync.SyncRequest{})
+	assert.NoError(t, err)
+	accountData := response.Rooms.Join[room.ID()].AccountData.Events
+	assert.Len(t, accountData, 1)
+	assert.Equal(t, events.Tag, accountData[0].Type())
+
+	assert.NoError(t, user.DeleteTag(room, "u.work"))
+
+	roomTags, err = user.Tags(room)
+	assert.NoError(t, err)
+	assert.Len(t, roomTags, 1)
+
+	response, err = user.Sync(token, mSync.SyncRequest{Since: response.NextBatch})
+	assert.NoError(t, err)
+	accountData = response.Rooms.Join[room.ID()].AccountData.Events
+	assert.Len(t, accountData, 1)
+	assert.JSONEq(t, `{"tags":{"m.favourite":{"order":0.5}}}`, string(accountData[0].Content()))
+}
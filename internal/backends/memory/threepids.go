@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
+)
+
+// threePIDKey returns key of third party identifier in backend index
+func threePIDKey(medium, address string) string {
+	return medium + ":" + address
+}
+
+// GetUserByThreePID returns user which is associated with third party identifier
+func (backend *Backend) GetUserByThreePID(medium, address string) internal.User {
+	address, err := internal.CanonicalThreePID(medium, address)
+	if err != nil {
+		return nil
+	}
+
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	username, exists := backend.threePIDs[threePIDKey(medium, address)]
+	if !exists {
+		return nil
+	}
+
+	return backend.data[username]
+}
+
+// ThreePIDs returns third party identifiers which are associated with user
+func (user *User) ThreePIDs() []threepids.ThirdPartyIdentifier {
+	user.backend.mutex.RLock()
+	defer user.backend.mutex.RUnlock()
+
+	return append([]threepids.ThirdPartyIdentifier{}, user.threePIDs...)
+}
+
+// AddThreePID associates validated third party identifier with user
+func (user *User) AddThreePID(medium, address string) models.ApiError {
+	address, err := internal.CanonicalThreePID(medium, address)
+	if err != nil {
+		return models.NewError(models.M_INVALID_PARAM, err.Error())
+	}
+
+	user.backend.mutex.Lock()
+	defer user.backend.mutex.Unlock()
+
+	key := threePIDKey(medium, address)
+	if _, exists := user.backend.threePIDs[key]; exists {
+		return models.NewError(models.M_THREEPID_IN_USE, "third party identifier is already in use")
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	user.threePIDs = append(user.threePIDs, threepids.ThirdPartyIdentifier{
+		Medium:      medium,
+		Address:     address,
+		ValidatedAt: now,
+		AddedAt:     now})
+	user.backend.threePIDs[key] = user.name
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
+)
+
+func TestThreePIDs(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("username1", "", "")
+	assert.NoError(t, err)
+	user2, _, err := backend.Register("username2", "", "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.AddThreePID(threepids.MediumEmail, "User1@Example.com"))
+	assert.NoError(t, user1.AddThreePID(threepids.MediumMSISDN, "+44 7700 900123"))
+
+	err = user2.AddThreePID(threepids.MediumEmail, "user1@example.com")
+	assert.Equal(t, models.M_THREEPID_IN_USE.Code(), err.Code())
+
+	err = user2.AddThreePID("fax", "123")
+	assert.Equal(t, models.M_INVALID_PARAM.Code(), err.Code())
+
+	assert.Equal(t, user1, backend.GetUserByThreePID(threepids.MediumEmail, "USER1@example.com"))
+	assert.Equal(t, user1, backend.GetUserByThreePID(threepids.MediumMSISDN, "447700900123"))
+	assert.Nil(t, backend.GetUserByThreePID(threepids.MediumEmail, "user2@example.com"))
+
+	identifiers := user1.ThreePIDs()
+	if assert.Len(t, identifiers, 2) {
+		assert.Equal(t, "user1@example.com", identifiers[0].Address)
+		assert.Equal(t, "447700900123", identifiers[1].Address)
+		assert.NotZero(t, identifiers[1].AddedAt)
+	}
+	assert.Empty(t, user2.ThreePIDs())
+}
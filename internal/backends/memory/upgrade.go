@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// upgradedStateTypes are types of state events which are copied to replacement room
+var upgradedStateTypes = []events.EventType{
+	events.JoinRules,
+	events.HistoryVisibility,
+	events.GuestAccess,
+	events.Name,
+	events.Topic,
+	events.Avatar,
+	events.CanonicalAlias}
+
+// UpgradeRoom replaces room with new room of specified version. Old room gets tombstone event and
+// only users with elevated power level can speak there.
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-upgrade
+func (user *User) UpgradeRoom(room internal.Room, version string) (internal.Room, models.ApiError) {
+	if err := user.guestAccessForbidden(); err != nil {
+		return nil, err
+	}
+
+	if !isSupportedRoomVersion(version) {
+		return nil, models.NewError(models.M_UNSUPPORTED_ROOM_VERSION, "unsupported room version "+version)
+	}
+
+	oldRoom := room.(*Room)
+
+	if !oldRoom.isJoined(user.ID()) {
+		return nil, models.NewError(models.M_FORBIDDEN, "you are not a member of room")
+	}
+
+	powerLevels := oldRoom.powerLevels()
+	if powerLevels.UserLevel(user.ID()) < powerLevels.EventLevel(events.Tombstone, true) {
+		return nil, models.NewError(models.M_FORBIDDEN, "not enough power level to upgrade room")
+	}
+
+	if _, replaced := user.backend.currentStateEvent(oldRoom.ID(), events.Tombstone, ""); replaced {
+		return nil, models.NewError(models.M_BAD_STATE, "room has been already replaced")
+	}
+
+	// Concurrent upgrades of the same room are rejected
+	oldRoom.mutex.Lock()
+	if oldRoom.upgraded {
+		oldRoom.mutex.Unlock()
+		return nil, models.NewError(models.M_BAD_STATE, "room has been already replaced")
+	}
+	oldRoom.upgraded = true
+
+	newRoom := &Room{
+		id:         internal.RandomString(groupIDSize),
+		aliasName:  oldRoom.aliasName,
+		name:       oldRoom.name,
+		topic:      oldRoom.topic,
+		avatarURL:  oldRoom.avatarURL,
+		creator:    user,
+		joined:     []internal.User{user},
+		visibility: oldRoom.visibility,
+		server:     user.backend,
+		state:      oldRoom.state,
+		version:    version}
+	oldRoom.mutex.Unlock()
+
+	// Tombstone event ID is known before sending to refer it as predecessor
+	tombstoneContent, _ := json.Marshal(events.TombstoneContent{
+		Body:            "This room has been replaced",
+		ReplacementRoom: newRoom.ID()})
+	tombstoneEvent := newStateEvent(oldRoom, user, events.Tombstone, "", tombstoneContent)
+
+	createContent, _ := json.Marshal(events.CreateContent{
+		Creator:     user.ID(),
+		RoomVersion: version,
+		Predecessor: &events.PreviousRoom{
+			RoomID:  oldRoom.ID(),
+			EventID: tombstoneEvent.EventID}})
+	powerLevelsContent, _ := json.Marshal(powerLevels)
+
+	newEvents := []*events.RoomEvent{
+		newStateEvent(newRoom, user, events.Create, "", createContent),
+		newMemberEvent(newRoom, user, user, events.MembershipJoin),
+		newStateEvent(newRoom, user, events.PowerLevels, "", powerLevelsContent)}
+	for _, eventType := range upgradedStateTypes {
+		if stateEvent, exists := user.backend.currentStateEvent(oldRoom.ID(), eventType, ""); exists {
+			newEvents = append(newEvents, newStateEvent(newRoom, user, eventType, "", stateEvent.Content))
+		}
+	}
+
+	for _, event := range newEvents {
+		user.backend.PutEvent(event)
+	}
+
+	// Aliases and room directory entry are moved to replacement room
+	user.backend.mutex.Lock()
+	user.backend.rooms[newRoom.ID()] = newRoom
+	movedAliases := user.backend.aliasesByRoom[oldRoom.ID()]
+	for _, localpart := range movedAliases {
+		user.backend.roomAliases[localpart] = newRoom
+	}
+	user.backend.aliasesByRoom[newRoom.ID()] = movedAliases
+	delete(user.backend.aliasesByRoom, oldRoom.ID())
+	user.backend.directoryVersion++
+	user.backend.mutex.Unlock()
+
+	oldRoom.mutex.Lock()
+	oldRoom.aliasName = ""
+	oldRoom.mutex.Unlock()
+
+	user.removeMovedAliases(oldRoom, movedAliases)
+
+	// Tombstone delists old room from public room directory
+	user.backend.PutEvent(tombstoneEvent)
+
+	// Restrict speaking in old room
+	restrictedLevel := 50
+	if powerLevels.UsersDefault+1 > restrictedLevel {
+		restrictedLevel = powerLevels.UsersDefault + 1
+	}
+	if powerLevels.EventsDefault < restrictedLevel {
+		powerLevels.EventsDefault = restrictedLevel
+	}
+	if powerLevels.Invite < restrictedLevel {
+		powerLevels.Invite = restrictedLevel
+	}
+	restrictedContent, _ := json.Marshal(powerLevels)
+	user.backend.PutEvent(newStateEvent(oldRoom, user, events.PowerLevels, "", restrictedContent))
+
+	return newRoom, nil
+}
+
+// removeMovedAliases sends canonical alias event without aliases which have been moved to replacement room
+func (user *User) removeMovedAliases(oldRoom *Room, movedAliases []string) {
+	stateEvent, exists := user.backend.currentStateEvent(oldRoom.ID(), events.CanonicalAlias, "")
+	if !exists || len(movedAliases) == 0 {
+		return
+	}
+
+	var content events.CanonicalAliasContent
+	if json.Unmarshal(stateEvent.Content, &content) != nil {
+		return
+	}
+
+	moved := make(map[string]bool)
+	for _, localpart := range movedAliases {
+		moved[internal.GetCanonicalAlias(user.backend.hostname, localpart)] = true
+	}
+
+	changed := moved[content.Alias]
+	if changed {
+		content.Alias = ""
+	}
+
+	var altAliases []string
+	for _, altAlias := range content.AltAliases {
+		if moved[altAlias] {
+			changed = true
+			continue
+		}
+		altAliases = append(altAliases, altAlias)
+	}
+	content.AltAliases = altAliases
+
+	if changed {
+		newContent, _ := json.Marshal(content)
+		user.backend.PutEvent(newStateEvent(oldRoom, user, events.CanonicalAlias, "", newContent))
+	}
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+func TestRoomVersions(t *testing.T) {
+	backend := newTestBackend(t)
+
+	versions := backend.RoomVersions()
+	assert.Contains(t, versions.Available, versions.Default)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{RoomVersion: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", room.(*Room).Version())
+}
+
+func TestUpgradeRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{
+		RoomAliasName: "room1",
+		Name:          "room1",
+		Topic:         "topic",
+		Visibility:    createroom.VisibilityTypePublic,
+		RoomVersion:   "1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+
+	// Only users with enough power level can upgrade room
+	_, err = user2.UpgradeRoom(room, "5")
+	assert.Equal(t, models.M_FORBIDDEN.Code(), err.Code())
+
+	_, err = user1.UpgradeRoom(room, "unknown")
+	assert.Equal(t, models.M_UNSUPPORTED_ROOM_VERSION.Code(), err.Code())
+
+	newRoom, err := user1.UpgradeRoom(room, "5")
+	assert.NoError(t, err)
+	assert.Equal(t, "5", newRoom.(*Room).Version())
+	assert.Equal(t, "room1", newRoom.Name())
+	assert.Equal(t, newRoom, backend.GetRoomByAlias("#room1:localhost"))
+	assert.Equal(t, createroom.VisibilityTypePublic, newRoom.Visibility())
+	assert.Equal(t, createroom.VisibilityTypePrivate, room.Visibility())
+
+	// Tombstone points to replacement room which refers to tombstone as predecessor
+	tombstone, exists := backend.currentStateEvent(room.ID(), events.Tombstone, "")
+	assert.True(t, exists)
+	var tombstoneContent events.TombstoneContent
+	assert.NoError(t, json.Unmarshal(tombstone.Content, &tombstoneContent))
+	assert.Equal(t, newRoom.ID(), tombstoneContent.ReplacementRoom)
+
+	create, exists := backend.currentStateEvent(newRoom.ID(), events.Create, "")
+	assert.True(t, exists)
+	var createContent events.CreateContent
+	assert.NoError(t, json.Unmarshal(create.Content, &createContent))
+	assert.Equal(t, &events.PreviousRoom{RoomID: room.ID(), EventID: tombstone.EventID}, createContent.Predecessor)
+
+	// State is copied to replacement room
+	for _, eventType := range []events.EventType{events.PowerLevels, events.JoinRules, events.Name, events.Topic, events.CanonicalAlias} {
+		_, exists := backend.currentStateEvent(newRoom.ID(), eventType, "")
+		assert.True(t, exists, eventType)
+	}
+
+	// Moved alias is removed from canonical alias of old room
+	canonicalAlias, exists := backend.currentStateEvent(room.ID(), events.CanonicalAlias, "")
+	assert.True(t, exists)
+	assert.JSONEq(t, `{}`, string(canonicalAlias.Content))
+
+	// Regular users can't speak in old room anymore
+	assert.Error(t, user2.SendMessage(room, "message"))
+	assert.NoError(t, user1.SendMessage(room, "message"))
+	assert.NoError(t, user1.SendMessage(newRoom, "message"))
+
+	// Replaced room can't be upgraded again
+	_, err = user1.UpgradeRoom(room, "5")
+	assert.Equal(t, models.M_BAD_STATE.Code(), err.Code())
+}
+
+func TestConcurrentUpgradeRoom(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	room, err := user.CreateRoom(createroom.Request{RoomVersion: "1"})
+	assert.NoError(t, err)
+
+	const count = 5
+	results := make(chan models.ApiError, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			_, err := user.UpgradeRoom(room, "5")
+			results <- err
+		}()
+	}
+
+	upgraded := 0
+	for i := 0; i < count; i++ {
+		if err := <-results; err == nil {
+			upgraded++
+		} else {
+			assert.Equal(t, models.M_BAD_STATE.Code(), err.Code())
+		}
+	}
+	assert.Equal(t, 1, upgraded)
+}
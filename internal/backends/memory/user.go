@@ -1,7 +1,7 @@
 package memory
 
 import (
-	"fmt"
+	"encoding/json"
 	"sync"
 	"time"
 
@@ -11,20 +11,48 @@ import (
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
 	"github.com/signaller-matrix/signaller/internal/models/devices"
 	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
 	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
 )
 
 type User struct {
 	name     string
 	password string
+	guest    bool
+	admin    bool
 	Tokens   map[string]Token
 	filters  map[string]common.Filter
 
+	pushRules           pushrules.Ruleset
+	unreadNotifications map[string]mSync.UnreadNotificationCounts // room ID -> counts
+	pushers             []pusher
+	notifications       []notification
+	readMarkers         map[string]int64                        // room ID -> stream position of last read event
+	lazyLoadedMembers   map[string]map[string]map[string]string // device -> room ID -> user ID -> ID of member event sent to device
+	accountData         map[string]map[string]accountData       // room ID ("" for global data) -> type -> data
+	ignoreChanges       []stateChange                           // users which have been ignored or unignored, in stream order
+	threePIDs           []threepids.ThirdPartyIdentifier
+
 	backend *Backend
 
 	mutex sync.RWMutex
 }
 
+func newUser(backend *Backend, username, password string) *User {
+	return &User{
+		name:                username,
+		password:            password,
+		Tokens:              make(map[string]Token),
+		backend:             backend,
+		filters:             make(map[string]common.Filter),
+		pushRules:           pushrules.DefaultRuleset("@"+username+":"+backend.hostname, username),
+		unreadNotifications: make(map[string]mSync.UnreadNotificationCounts),
+		readMarkers:         make(map[string]int64),
+		lazyLoadedMembers:   make(map[string]map[string]map[string]string),
+		accountData:         make(map[string]map[string]accountData)}
+}
+
 func (user *User) ID() string {
 	return "@" + user.name + ":" + user.backend.hostname
 }
@@ -37,53 +65,101 @@ func (user *User) Password() string {
 	return user.password
 }
 
+// IsAdmin reports whether user is server administrator
+func (user *User) IsAdmin() bool {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
+
+	return user.admin
+}
+
+// SetAdmin grants or revokes server administrator rights
+func (user *User) SetAdmin(admin bool) {
+	user.mutex.Lock()
+	defer user.mutex.Unlock()
+
+	user.admin = admin
+}
+
+// CreateRoom creates room with events of spec order: create, creator join, power levels, join rules,
+// history visibility, guest access, initial state, name, topic, invites and alias
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-createroom
 func (user *User) CreateRoom(request createroom.Request) (internal.Room, models.ApiError) {
-	for _, existingRoom := range user.backend.rooms {
-		if existingRoom.AliasName() == request.RoomAliasName { // TODO: strip and check request room alias name before use
+	if err := user.guestAccessForbidden(); err != nil {
+		return nil, err
+	}
+
+	if request.RoomVersion == "" {
+		request.RoomVersion = defaultRoomVersion
+	}
+	if !isSupportedRoomVersion(request.RoomVersion) {
+		return nil, models.NewError(models.M_UNSUPPORTED_ROOM_VERSION, "unsupported room version "+request.RoomVersion)
+	}
+
+	if request.RoomAliasName != "" {
+		if _, err := user.backend.localAlias(internal.GetCanonicalAlias(user.backend.hostname, request.RoomAliasName)); err != nil {
+			return nil, err
+		}
+		if user.backend.GetRoomByAlias(request.RoomAliasName) != nil {
 			return nil, models.NewError(models.M_ROOM_IN_USE, "")
 		}
 	}
 
-	currentUnixTime := time.Now().Unix()
+	// Direct chat is trusted private chat unless other preset is requested
+	if request.IsDirect && request.Preset == "" {
+		request.Preset = createroom.TrustedPrivateChat
+	}
 
-	eventsSlice := make([]events.RoomEvent, 0)
+	if request.Visibility == "" {
+		request.Visibility = createroom.VisibilityTypePrivate
+	}
 
-	// Create room event
-	eventsSlice = append(eventsSlice, events.RoomEvent{
-		ContentData:    nil,
-		EType:          events.Create,
-		EventID:        internal.RandomString(eventIDSize),
-		Sender:         user.ID(),
-		OriginServerTs: currentUnixTime})
+	// Preset is determined by visibility if it is unspecified
+	if request.Preset == "" {
+		request.Preset = createroom.PrivateChat
+		if request.Visibility == createroom.VisibilityTypePublic {
+			request.Preset = createroom.PublicChat
+		}
+	}
 
-	// TODO: Add join room event
+	createContent, apiErr := creationContent(user.ID(), request.RoomVersion, request.CreationContent)
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
-	// Set join rules event
-	eventsSlice = append(eventsSlice, events.RoomEvent{
-		ContentData:    []byte(request.Visibility), // TODO: check visibility vs join rules
-		EType:          events.JoinRules,
-		EventID:        internal.RandomString(eventIDSize),
-		Sender:         user.ID(),
-		OriginServerTs: currentUnixTime})
+	for _, stateEvent := range request.InitialState {
+		switch events.EventType(stateEvent.Type) {
+		case events.Create, events.Member, events.PowerLevels:
+			return nil, models.NewError(models.M_INVALID_PARAM, stateEvent.Type+" can't be set by initial state")
+		}
+		if !isJSONObject(stateEvent.Content) {
+			return nil, models.NewError(models.M_INVALID_PARAM, "content of initial state event must be an object")
+		}
 
-	// Set room name event
-	if request.Name != "" {
-		eventsSlice = append(eventsSlice, events.RoomEvent{
-			ContentData:    nil, // TODO: add
-			EType:          events.Name,
-			EventID:        internal.RandomString(eventIDSize),
-			Sender:         user.ID(),
-			OriginServerTs: currentUnixTime})
+		// Only alias requested by room_alias_name can point to room which isn't created yet
+		if events.EventType(stateEvent.Type) == events.CanonicalAlias {
+			var content events.CanonicalAliasContent
+			if json.Unmarshal(stateEvent.Content, &content) != nil {
+				return nil, models.NewError(models.M_INVALID_PARAM, "invalid canonical alias content")
+			}
+			if err := user.backend.validateCanonicalAlias(content, func(localpart string) bool {
+				return localpart == request.RoomAliasName
+			}); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	// Set room alias event
-	if request.RoomAliasName != "" {
-		eventsSlice = append(eventsSlice, events.RoomEvent{
-			ContentData:    nil, // TODO: add
-			EType:          events.CanonicalAlias,
-			EventID:        internal.RandomString(eventIDSize),
-			Sender:         user.ID(),
-			OriginServerTs: currentUnixTime})
+	var invitees []internal.User
+	for _, inviteeID := range request.Invite {
+		invitee := user.backend.userByID(inviteeID)
+		if invitee == nil {
+			return nil, models.NewError(models.M_INVALID_PARAM, "unknown invitee "+inviteeID)
+		}
+		if invitee.ID() == user.ID() {
+			return nil, models.NewError(models.M_INVALID_PARAM, "room creator can't be invited")
+		}
+		invitees = append(invitees, invitee)
 	}
 
 	room := &Room{
@@ -93,24 +169,120 @@ func (user *User) CreateRoom(request createroom.Request) (internal.Room, models.
 		topic:      request.Topic,
 		creator:    user,
 		joined:     []internal.User{user},
+		invites:    invitees,
 		visibility: request.Visibility,
 		server:     user.backend,
-		state:      request.Preset}
+		state:      request.Preset,
+		version:    request.RoomVersion}
 
-	for i, _ := range eventsSlice {
-		eventsSlice[i].RoomID = room.id
+	eventsSlice := make([]events.RoomEvent, 0)
+
+	addStateEvent := func(eventType events.EventType, key string, content json.RawMessage) {
+		eventsSlice = append(eventsSlice, *newStateEvent(room, user, eventType, key, content))
+	}
+
+	// Create room event
+	addStateEvent(events.Create, "", createContent)
+
+	// Creator join event
+	eventsSlice = append(eventsSlice, *newMemberEvent(room, user, user, events.MembershipJoin))
+
+	// Set power levels event
+	powerLevelsContent, _ := json.Marshal(initialPowerLevels(user.ID(), request.Preset, request.Invite))
+	addStateEvent(events.PowerLevels, "", powerLevelsContent)
+
+	// Set join rules event
+	joinRulesContent, _ := json.Marshal(map[string]string{"join_rule": string(joinRuleByPreset(request.Preset))})
+	addStateEvent(events.JoinRules, "", joinRulesContent)
+
+	// Set history visibility event
+	historyVisibilityContent, _ := json.Marshal(map[string]string{"history_visibility": string(historyVisibilityByPreset(request.Preset))})
+	addStateEvent(events.HistoryVisibility, "", historyVisibilityContent)
+
+	// Set guest access event
+	guestAccessContent, _ := json.Marshal(map[string]string{"guest_access": string(guestAccessByPreset(request.Preset))})
+	addStateEvent(events.GuestAccess, "", guestAccessContent)
+
+	// Initial state overrides events set by preset
+	for _, stateEvent := range request.InitialState {
+		addStateEvent(events.EventType(stateEvent.Type), stateEvent.StateKey, stateEvent.Content)
+
+		var content struct {
+			Name  *string `json:"name"`
+			Topic *string `json:"topic"`
+		}
+		json.Unmarshal(stateEvent.Content, &content)
+		switch {
+		case stateEvent.Type == string(events.Name) && content.Name != nil && request.Name == "":
+			room.name = *content.Name
+		case stateEvent.Type == string(events.Topic) && content.Topic != nil && request.Topic == "":
+			room.topic = *content.Topic
+		}
+	}
+
+	// Set room name event
+	if request.Name != "" {
+		content, _ := json.Marshal(map[string]string{"name": request.Name})
+		addStateEvent(events.Name, "", content)
+	}
+
+	// Set room topic event
+	if request.Topic != "" {
+		content, _ := json.Marshal(map[string]string{"topic": request.Topic})
+		addStateEvent(events.Topic, "", content)
+	}
+
+	for _, invitee := range invitees {
+		inviteEvent := newMemberEvent(room, user, invitee, events.MembershipInvite)
+		if request.IsDirect {
+			inviteEvent.ContentData, _ = json.Marshal(events.EventContent{Membership: events.MembershipInvite, IsDirect: true})
+		}
+		eventsSlice = append(eventsSlice, *inviteEvent)
 	}
 
+	// Set room alias event
+	if request.RoomAliasName != "" {
+		content, _ := json.Marshal(events.CanonicalAliasContent{Alias: internal.GetCanonicalAlias(user.backend.hostname, request.RoomAliasName)})
+		addStateEvent(events.CanonicalAlias, "", content)
+	}
+
+	// Room is registered and its alias is reserved before its events are stored,
+	// so push rules and directory listing see it and concurrent creation can't take the same alias
+	user.backend.mutex.Lock()
+	if request.RoomAliasName != "" {
+		if _, exists := user.backend.roomAliases[request.RoomAliasName]; exists {
+			user.backend.mutex.Unlock()
+			return nil, models.NewError(models.M_ROOM_IN_USE, "")
+		}
+	}
+	user.backend.rooms[room.ID()] = room
+	if request.Visibility == createroom.VisibilityTypePublic {
+		user.backend.directoryVersion++
+	}
+	if request.RoomAliasName != "" {
+		user.backend.addAlias(request.RoomAliasName, room)
+	}
+	user.backend.mutex.Unlock()
+
 	for i, _ := range eventsSlice {
 		user.backend.PutEvent(&eventsSlice[i])
 	}
 
-	user.backend.rooms[room.ID()] = room
+	if request.IsDirect && len(invitees) != 0 {
+		user.addDirectRoom(room.ID(), request.Invite)
+	}
+
+	// Room can't be published if initial state makes it unjoinable
+	user.backend.delistUnlistable(room)
 
 	return room, nil
 }
 
 func (user *User) SetTopic(room internal.Room, topic string) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
 	memRoom := room.(*Room)
 
 	memRoom.mutex.Lock()
@@ -124,11 +296,19 @@ func (user *User) SetTopic(room internal.Room, topic string) models.ApiError {
 
 	memRoom.mutex.Unlock()
 
+	content, err := json.Marshal(map[string]string{"topic": topic})
+	if err != nil {
+		return models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
 	rEvent := &events.RoomEvent{
+		ContentData:    content,
 		EType:          events.Topic,
+		EventID:        internal.RandomString(eventIDSize),
 		Sender:         user.ID(),
 		OriginServerTs: time.Now().Unix(),
-		RoomID:         memRoom.id}
+		RoomID:         memRoom.ID(),
+		StateKey:       stateKey("")}
 
 	user.backend.PutEvent(rEvent)
 
@@ -136,10 +316,13 @@ func (user *User) SetTopic(room internal.Room, topic string) models.ApiError {
 }
 
 func (user *User) Invite(room internal.Room, invitee internal.User) models.ApiError {
+	if err := user.guestAccessForbidden(); err != nil {
+		return err
+	}
+
 	memRoom := room.(*Room)
 
 	memRoom.mutex.Lock()
-	defer memRoom.mutex.Unlock()
 
 	userInRoom := false
 
@@ -150,23 +333,30 @@ func (user *User) Invite(room internal.Room, invitee internal.User) models.ApiEr
 	}
 
 	if !userInRoom {
+		memRoom.mutex.Unlock()
 		return models.NewError(models.M_FORBIDDEN, "the inviter is not currently in the room") // TODO: check code
 	}
 
 	// TODO: remove repeated cycle
 	for _, roomUser := range memRoom.joined {
 		if roomUser.ID() == invitee.ID() {
+			memRoom.mutex.Unlock()
 			return models.NewError(models.M_FORBIDDEN, "the invitee is already a member of the room.") // TODO: check code
 		}
 	}
 
 	for _, inviteeUser := range memRoom.invites {
 		if inviteeUser.ID() == invitee.ID() {
+			memRoom.mutex.Unlock()
 			return models.NewError(models.M_FORBIDDEN, "user already has been invited") // TODO: check code
 		}
 	}
 
-	memRoom.invites = append(memRoom.invites, invitee) // TODO: add invite event + info about inviter
+	memRoom.invites = append(memRoom.invites, invitee)
+
+	memRoom.mutex.Unlock()
+
+	user.backend.PutEvent(newMemberEvent(room, user, invitee, events.MembershipInvite))
 
 	return nil
 }
@@ -175,26 +365,42 @@ func (user *User) LeaveRoom(room internal.Room) models.ApiError {
 	memRoom := room.(*Room)
 
 	memRoom.mutex.Lock()
-	defer memRoom.mutex.Unlock()
 
-	for i, roomMember := range room.(*Room).joined {
+	left := false
+
+	for i, roomMember := range memRoom.joined {
 		if roomMember.ID() == user.ID() {
-			room.(*Room).joined = append(room.(*Room).joined[:i], room.(*Room).joined[i+1:]...) // TODO: add event
-			return nil
+			memRoom.joined = append(memRoom.joined[:i], memRoom.joined[i+1:]...)
+			left = true
+			break
 		}
 	}
 
-	return models.NewError(models.M_BAD_STATE, "you are not a member of group") // TODO: check error code
+	// Leaving room user has been invited to rejects invite
+	for i, invitee := range memRoom.invites {
+		if invitee.ID() == user.ID() {
+			memRoom.invites = append(memRoom.invites[:i], memRoom.invites[i+1:]...)
+			left = true
+			break
+		}
+	}
+
+	memRoom.mutex.Unlock()
+
+	if !left {
+		return models.NewError(models.M_BAD_STATE, "you are not a member of group") // TODO: check error code
+	}
+
+	user.backend.PutEvent(newMemberEvent(room, user, user, events.MembershipLeave))
+
+	return nil
 }
 
 func (user *User) SendMessage(room internal.Room, text string) models.ApiError {
 	memRoom := room.(*Room)
 
-	memRoom.mutex.RLock()
-	defer memRoom.mutex.RUnlock()
-
 	userInRoom := false
-	for _, roomMember := range memRoom.joined {
+	for _, roomMember := range memRoom.Users() {
 		if roomMember.ID() == user.ID() {
 			userInRoom = true
 		}
@@ -204,13 +410,25 @@ func (user *User) SendMessage(room internal.Room, text string) models.ApiError {
 		return models.NewError(models.M_FORBIDDEN, "")
 	}
 
+	powerLevels := memRoom.powerLevels()
+	if powerLevels.UserLevel(user.ID()) < powerLevels.EventLevel(events.Message, false) {
+		return models.NewError(models.M_FORBIDDEN, "not enough power level to send messages")
+	}
+
+	content, err := json.Marshal(common.MessageTextContent{
+		Body:    text,
+		Msgtype: common.MessageTypeText})
+	if err != nil {
+		return models.NewError(models.M_UNKNOWN, err.Error())
+	}
+
 	rEvent := &events.RoomEvent{
-		ContentData:    nil,
+		ContentData:    content,
 		EType:          events.Message,
 		EventID:        internal.RandomString(defaultTokenSize),
 		Sender:         user.ID(),
 		OriginServerTs: time.Now().Unix(),
-		RoomID:         memRoom.id}
+		RoomID:         memRoom.ID()}
 
 	user.backend.PutEvent(rEvent)
 
@@ -224,9 +442,10 @@ func (user *User) JoinedRooms() []internal.Room {
 	var result []internal.Room
 
 	for _, room := range user.backend.rooms {
-		for _, user := range room.(*Room).joined {
-			if user.ID() == user.ID() {
+		for _, roomUser := range room.Users() {
+			if roomUser.ID() == user.ID() {
 				result = append(result, room)
+				break
 			}
 		}
 	}
@@ -242,7 +461,8 @@ func (user *User) Devices() []devices.Device {
 
 	for _, token := range user.Tokens {
 		device := devices.Device{
-			DeviceID: token.Device}
+			DeviceID:    token.Device,
+			DisplayName: token.DisplayName}
 
 		result = append(result, device)
 	}
@@ -250,21 +470,6 @@ func (user *User) Devices() []devices.Device {
 	return result
 }
 
-func (user *User) SetRoomVisibility(room internal.Room, visibilityType createroom.VisibilityType) models.ApiError {
-	if user.ID() != room.Creator().ID() {
-		return models.NewError(models.M_FORBIDDEN, "only room owner can change visibility") // TODO: room administrators can use this method too
-	}
-
-	memRoom := room.(*Room)
-
-	memRoom.mutex.Lock()
-	defer memRoom.mutex.Unlock()
-
-	memRoom.visibility = visibilityType
-
-	return nil
-}
-
 func (user *User) ChangePassword(newPassword string) {
 	user.mutex.Lock()
 	defer user.mutex.Unlock()
@@ -273,64 +478,53 @@ func (user *User) ChangePassword(newPassword string) {
 }
 
 func (user *User) Logout(token string) {
-	user.mutex.Lock()
-	defer user.mutex.Unlock()
+	user.backend.mutex.Lock()
+	defer user.backend.mutex.Unlock()
 
-	delete(user.Tokens, token)
+	if _, exists := user.Tokens[token]; exists {
+		delete(user.Tokens, token)
+		delete(user.backend.accessTokens, token)
+	}
 }
 
 func (user *User) LogoutAll() {
+	user.backend.mutex.Lock()
+	defer user.backend.mutex.Unlock()
+
+	for token := range user.Tokens {
+		delete(user.backend.accessTokens, token)
+	}
 	user.Tokens = make(map[string]Token)
 }
 
 func (user *User) JoinRoom(room internal.Room) models.ApiError {
+	if user.IsGuest() && !room.GuestCanJoin() {
+		return models.NewError(models.M_GUEST_ACCESS_FORBIDDEN, "guest users are not allowed to join room")
+	}
+
 	memRoom := room.(*Room)
 
 	memRoom.mutex.Lock()
-	defer memRoom.mutex.Unlock()
 
 	for _, roomUser := range memRoom.joined {
 		if roomUser.ID() == user.ID() {
+			memRoom.mutex.Unlock()
 			return models.NewError(models.M_BAD_STATE, "user already in room") // TODO: check code
 		}
 	}
 
 	memRoom.joined = append(memRoom.joined, user)
 
-	return nil
-}
-
-func (user *User) AddRoomAlias(room internal.Room, alias string) models.ApiError {
-	user.backend.mutex.Lock()
-	defer user.backend.mutex.Unlock()
-
-	if room.Creator().ID() != user.ID() {
-		return models.NewError(models.M_FORBIDDEN, "only room creator can add room alias") // TODO: make room admins can use this method
-	}
-
-	if _, exists := user.backend.roomAliases[alias]; exists {
-		return models.NewError(models.M_UNKNOWN, fmt.Sprintf("room alias #%s:%s already exists", alias, user.backend.hostname))
-	}
-
-	user.backend.roomAliases[alias] = room
-
-	return nil
-}
-
-func (user *User) DeleteRoomAlias(alias string) models.ApiError {
-	user.backend.mutex.Lock()
-	defer user.backend.mutex.Unlock()
-
-	room := user.backend.GetRoomByAlias(alias)
-	if room == nil {
-		return models.NewError(models.M_NOT_FOUND, "room not found")
+	for i, invitee := range memRoom.invites {
+		if invitee.ID() == user.ID() {
+			memRoom.invites = append(memRoom.invites[:i], memRoom.invites[i+1:]...)
+			break
+		}
 	}
 
-	if room.Creator().ID() != user.ID() {
-		return models.NewError(models.M_FORBIDDEN, "only room creator can delete room alias") // TODO: make room admins can use this method
-	}
+	memRoom.mutex.Unlock()
 
-	delete(user.backend.roomAliases, alias)
+	user.backend.PutEvent(newMemberEvent(room, user, user, events.MembershipJoin))
 
 	return nil
 }
@@ -353,63 +547,9 @@ func (user *User) GetFilterByID(filterID string) *common.Filter {
 	return nil
 }
 
-func (user *User) Sync(token string, request mSync.SyncRequest) (response *mSync.SyncReply, err models.ApiError) {
-	response = mSync.BuildEmptySyncReply()
-	emptyEventList := true
-	emptySinceToken := true
-	var eventsList []events.Event
-
-	if request.Since != "" {
-		emptySinceToken = false
-		eventsList := user.backend.GetEventsSince(user, request.Since, 0) // TODO filtering
-
-		if len(eventsList) != 0 {
-			emptyEventList = false
-		}
-	}
-
-	if !emptyEventList {
-		for _, room := range user.JoinedRooms() {
-			filteredEventList := filterEventsByRoom(room.ID(), eventsList)
-			var prevBatch string
-			if len(filteredEventList) != 0 {
-				prevBatch = filteredEventList[0].ID()
-			}
-			response.Rooms.Join[room.ID()] = mSync.JoinedRoom{
-				RoomSummary: mSync.RoomSummary{
-					Heroes:             nil,
-					JoinedMemberCount:  0,
-					InvitedMemberCount: 0,
-				},
-				State: events.State{
-					Events: nil,
-				},
-				Timeline: mSync.Timeline{
-					Events:    filteredEventList,
-					Limited:   false, // TODO filtering
-					PrevBatch: prevBatch,
-				},
-			}
-		}
-		response.NextBatch = eventsList[len(eventsList)-1].ID()
-	} else if emptySinceToken {
-		// TODO
-	} else {
-		// TODO wait for new events or just return empty response when timeout is reached
-	}
-
-	return response, nil
-}
-
-func filterEventsByRoom(roomID string, eventList []events.Event) []events.RoomEvent {
-	var filteredEventList []events.RoomEvent
-	for _, event := range eventList {
-		if roomEvent, ok := event.(*events.RoomEvent); ok {
-			if roomEvent.RoomID == roomID {
-				filteredEventList = append(filteredEventList, *event.(*events.RoomEvent))
-			}
-		}
-	}
+func (user *User) unreadNotificationCounts(roomID string) mSync.UnreadNotificationCounts {
+	user.mutex.RLock()
+	defer user.mutex.RUnlock()
 
-	return filteredEventList
+	return user.unreadNotifications[roomID]
 }
@@ -1,22 +1,31 @@
 package memory
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/signaller-matrix/signaller/internal"
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/notifications"
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+	"github.com/signaller-matrix/signaller/internal/pushgateway"
 )
 
 func TestUserID(t *testing.T) {
 	var (
 		userName       = "user1"
-		hostName       = "localhost"
 		expectedUserID = "@user1:localhost"
 	)
 
-	backend := NewBackend(hostName)
+	backend := newTestBackend(t)
 	user, _, err := backend.Register(userName, "", "")
 	assert.NoError(t, err)
 
@@ -24,7 +33,7 @@ func TestUserID(t *testing.T) {
 }
 
 func TestUserMessage(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -41,7 +50,7 @@ func TestUserMessage(t *testing.T) {
 }
 
 func TestUserMessageInWrongRoom(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user1, _, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -61,7 +70,7 @@ func TestUserMessageInWrongRoom(t *testing.T) {
 }
 
 func TestGetUserByToken(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, token, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -72,7 +81,7 @@ func TestGetUserByToken(t *testing.T) {
 }
 
 func TestGetUserByWrongToken(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	_, token, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -83,7 +92,7 @@ func TestGetUserByWrongToken(t *testing.T) {
 }
 
 func TestLogoutWithWrongToken(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "user1"
@@ -101,7 +110,7 @@ func TestLogoutWithWrongToken(t *testing.T) {
 }
 
 func TestJoinedRooms(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -119,7 +128,7 @@ func TestJoinedRooms(t *testing.T) {
 }
 
 func TestNewPassword(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var newPassword = "new password"
 
@@ -131,7 +140,7 @@ func TestNewPassword(t *testing.T) {
 }
 
 func TestDevices(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var expectedDeviceID = "my device"
 
@@ -144,7 +153,7 @@ func TestDevices(t *testing.T) {
 }
 
 func TestSetRoomVisibility(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user, _, err := backend.Register("user1", "", "")
 	assert.NoError(t, err)
@@ -152,7 +161,8 @@ func TestSetRoomVisibility(t *testing.T) {
 	request := createroom.Request{
 		RoomAliasName: "room1",
 		Name:          "room1",
-		Visibility:    createroom.VisibilityTypePrivate}
+		Visibility:    createroom.VisibilityTypePrivate,
+		Preset:        createroom.PublicChat}
 
 	room, err := user.CreateRoom(request)
 	assert.NoError(t, err)
@@ -173,7 +183,7 @@ func TestSetRoomVisibility(t *testing.T) {
 }
 
 func TestLogoutAll(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	var (
 		userName = "user1"
@@ -194,7 +204,7 @@ func TestLogoutAll(t *testing.T) {
 }
 
 func TestInviteUser(t *testing.T) {
-	backend := NewBackend("localhost")
+	backend := newTestBackend(t)
 
 	user1, _, err := backend.Register("username1", "", "")
 	assert.NoError(t, err)
@@ -214,3 +224,216 @@ func TestInviteUser(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, room.(*Room).invites, 1)
 }
+
+func TestUnreadNotifications(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	err = user2.JoinRoom(room)
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "hello"))
+	assert.NoError(t, user1.SendMessage(room, "hello user2"))
+
+	response, err := user2.Sync("", mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Rooms.Join[room.ID()].UnreadNotifications.NotificationCount)
+	assert.Equal(t, 1, response.Rooms.Join[room.ID()].UnreadNotifications.HighlightCount)
+
+	// Sender doesn't get notifications about own messages
+	response, err = user1.Sync("", mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, response.Rooms.Join[room.ID()].UnreadNotifications.NotificationCount)
+}
+
+func TestMutedRoomNotifications(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+
+	rule := pushrules.PushRule{
+		RuleID:  room.ID(),
+		Actions: []pushrules.Action{{Type: pushrules.ActionDontNotify}}}
+	err = user2.AddPushRule(pushrules.KindRoom, rule, "", "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "hello"))
+
+	response, err := user2.Sync("", mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, response.Rooms.Join[room.ID()].UnreadNotifications.NotificationCount)
+}
+
+func TestInviteNotification(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user1.Invite(room, user2))
+
+	// Invitee isn't member of room yet, but is notified by .m.rule.invite_for_me
+	response, err := user2.Notifications("", 0, "")
+	assert.NoError(t, err)
+	if assert.Len(t, response.Notifications, 1) {
+		assert.Equal(t, events.Member, response.Notifications[0].Event.EType)
+		assert.Equal(t, user2.ID(), *response.Notifications[0].Event.StateKey)
+	}
+
+	response, err = user1.Notifications("", 0, "")
+	assert.NoError(t, err)
+	assert.Empty(t, response.Notifications)
+}
+
+func TestPushers(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	request := pushers.SetRequest{Pusher: pushers.Pusher{
+		PushKey: "pushkey1",
+		Kind:    pushers.KindHTTP,
+		AppID:   "com.example.app",
+		Data:    pushers.PusherData{URL: "https://push.example.com" + pushgateway.NotifyPath}}}
+
+	assert.NoError(t, user1.SetPusher(request))
+	assert.Len(t, user1.Pushers(), 1)
+
+	// Same pusher for another user without append removes pusher of first user
+	assert.NoError(t, user2.SetPusher(request))
+	assert.Len(t, user1.Pushers(), 0)
+	assert.Len(t, user2.Pushers(), 1)
+
+	// Pusher with wrong url
+	wrongRequest := request
+	wrongRequest.Data.URL = "https://push.example.com/notify"
+	assert.Error(t, user1.SetPusher(wrongRequest))
+
+	// Null kind deletes pusher
+	request.Kind = ""
+	assert.NoError(t, user2.SetPusher(request))
+	assert.Len(t, user2.Pushers(), 0)
+}
+
+func TestPushNotificationDelivery(t *testing.T) {
+	received := make(chan pushers.NotifyRequest, 1)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request pushers.NotifyRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		received <- request
+
+		w.Write([]byte(`{"rejected":[]}`))
+	}))
+	defer gateway.Close()
+
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+
+	err = user2.SetPusher(pushers.SetRequest{Pusher: pushers.Pusher{
+		PushKey: "pushkey1",
+		Kind:    pushers.KindHTTP,
+		AppID:   "com.example.app",
+		Data:    pushers.PusherData{URL: gateway.URL + pushgateway.NotifyPath}}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "hello"))
+
+	select {
+	case request := <-received:
+		assert.Equal(t, room.ID(), request.Notification.RoomID)
+		assert.Equal(t, user1.ID(), request.Notification.Sender)
+		assert.Equal(t, 1, request.Notification.Counts.Unread)
+		assert.Equal(t, "pushkey1", request.Notification.Devices[0].PushKey)
+	case <-time.After(time.Second):
+		t.Fatal("notification was not delivered")
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+	assert.NoError(t, user2.JoinRoom(room))
+
+	assert.NoError(t, user1.SendMessage(room, "message 1"))
+	assert.NoError(t, user1.SendMessage(room, "message 2 for user2"))
+	assert.NoError(t, user1.SendMessage(room, "message 3"))
+
+	// Newest first with pagination
+	response, err := user2.Notifications("", 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, response.Notifications, 2)
+	assert.NotEmpty(t, response.NextToken)
+	assert.Equal(t, room.ID(), response.Notifications[0].RoomID)
+	assert.False(t, response.Notifications[0].Read)
+
+	secondEventID := response.Notifications[1].Event.EventID
+
+	response, err = user2.Notifications(response.NextToken, 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, response.Notifications, 1)
+	assert.Empty(t, response.NextToken)
+
+	response, err = user2.Notifications("", 0, notifications.OnlyHighlight)
+	assert.NoError(t, err)
+	assert.Len(t, response.Notifications, 1)
+	assert.Equal(t, secondEventID, response.Notifications[0].Event.EventID)
+
+	// Read receipt clears notifications up to receipted event
+	assert.NoError(t, user2.SetReadReceipt(room, secondEventID))
+
+	response, err = user2.Notifications("", 0, "")
+	assert.NoError(t, err)
+	assert.False(t, response.Notifications[0].Read)
+	assert.True(t, response.Notifications[1].Read)
+	assert.True(t, response.Notifications[2].Read)
+
+	syncResponse, err := user2.Sync("", mSync.SyncRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, syncResponse.Rooms.Join[room.ID()].UnreadNotifications.NotificationCount)
+	assert.Equal(t, 0, syncResponse.Rooms.Join[room.ID()].UnreadNotifications.HighlightCount)
+
+	// Receipt for unknown event
+	assert.Error(t, user2.SetReadReceipt(room, "wrong event"))
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"github.com/signaller-matrix/signaller/internal/models/capabilities"
+)
+
+// defaultRoomVersion is version of rooms created without requested version
+const defaultRoomVersion = "5"
+
+// roomVersions are room versions which server is able to create
+var roomVersions = map[string]capabilities.Stability{
+	"1": capabilities.Stable,
+	"2": capabilities.Stable,
+	"3": capabilities.Stable,
+	"4": capabilities.Stable,
+	"5": capabilities.Stable}
+
+// RoomVersions returns room versions supported by server
+// https://matrix.org/docs/spec/client_server/latest#m-room-versions-capability
+func (backend *Backend) RoomVersions() capabilities.RoomVersionsCapability {
+	available := make(map[string]capabilities.Stability)
+	for version, stability := range roomVersions {
+		available[version] = stability
+	}
+
+	return capabilities.RoomVersionsCapability{
+		Default:   defaultRoomVersion,
+		Available: available}
+}
+
+// isSupportedRoomVersion checks that rooms of version can be created
+func isSupportedRoomVersion(version string) bool {
+	_, exists := roomVersions[version]
+	return exists
+}
+
+// Version returns room version of room
+func (room *Room) Version() string {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+
+	return room.version
+}
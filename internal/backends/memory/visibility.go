@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+)
+
+// stateChange is value of state which is in force since position in stream
+type stateChange struct {
+	position int64
+	value    string
+}
+
+// visibilityChecker checks if user can see events of room according to history visibility
+// in force at each event and membership of user at that time.
+// https://matrix.org/docs/spec/client_server/latest#room-history-visibility
+type visibilityChecker struct {
+	visibility []stateChange   // changes of history visibility in stream order
+	membership []stateChange   // changes of user membership in stream order
+	ignored    map[string]bool // users ignored by user
+}
+
+// newVisibilityChecker returns visibility checker of room events for specified user
+func (backend *Backend) newVisibilityChecker(roomID, userID string) *visibilityChecker {
+	checker := new(visibilityChecker)
+
+	if user := backend.userByID(userID); user != nil {
+		checker.ignored = user.ignoredUsers()
+	}
+
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	index := backend.roomStates[roomID]
+	if index == nil {
+		return checker
+	}
+
+	// Changes are copied because index is updated by new events
+	checker.visibility = append([]stateChange(nil), index.visibility...)
+	if userID != "" {
+		checker.membership = append([]stateChange(nil), index.membership[userID]...)
+	}
+
+	return checker
+}
+
+// valueAt returns value of state in force at position
+func valueAt(changes []stateChange, position int64) string {
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].position > position })
+	if i == 0 {
+		return ""
+	}
+
+	return changes[i-1].value
+}
+
+func currentValue(changes []stateChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	return changes[len(changes)-1].value
+}
+
+// joinedSince reports whether membership has been join at any point since position
+func joinedSince(membership []stateChange, position int64) bool {
+	i := sort.Search(len(membership), func(i int) bool { return membership[i].position >= position })
+	for _, change := range membership[i:] {
+		if events.Membership(change.value) == events.MembershipJoin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// historyVisibility returns history visibility in force at position
+func (checker *visibilityChecker) historyVisibility(position int64) rooms.HistoryVisibility {
+	if visibility := valueAt(checker.visibility, position); visibility != "" {
+		return rooms.HistoryVisibility(visibility)
+	}
+
+	return rooms.HistoryVisibilityShared
+}
+
+// canSee reports whether user can see event at position.
+// Membership of user is checked both before and after event, so user can see own leave event.
+func (checker *visibilityChecker) canSee(position int64) bool {
+	visibility := checker.historyVisibility(position)
+	if visibility == rooms.HistoryVisibilityWorldReadable {
+		return true
+	}
+
+	before := events.Membership(valueAt(checker.membership, position-1))
+	after := events.Membership(valueAt(checker.membership, position))
+	if before == events.MembershipJoin || after == events.MembershipJoin {
+		return true
+	}
+
+	switch visibility {
+	case rooms.HistoryVisibilityShared:
+		return joinedSince(checker.membership, position)
+	case rooms.HistoryVisibilityInvited:
+		return before == events.MembershipInvite || after == events.MembershipInvite
+	}
+
+	return false
+}
+
+// currentHistoryVisibility returns current history visibility of room
+func (checker *visibilityChecker) currentHistoryVisibility() rooms.HistoryVisibility {
+	if visibility := currentValue(checker.visibility); visibility != "" {
+		return rooms.HistoryVisibility(visibility)
+	}
+
+	return rooms.HistoryVisibilityShared
+}
+
+// canSeeRoom reports whether user can see any events of room
+func (checker *visibilityChecker) canSeeRoom() bool {
+	return checker.currentHistoryVisibility() == rooms.HistoryVisibilityWorldReadable || checker.wasMember()
+}
+
+// wasMember reports whether user has ever been invited to room or joined it
+func (checker *visibilityChecker) wasMember() bool {
+	return len(checker.membership) != 0
+}
+
+// ignoresSomebody reports whether events of some users must be hidden
+func (checker *visibilityChecker) ignoresSomebody() bool {
+	return checker != nil && len(checker.ignored) != 0
+}
+
+// isIgnored reports whether event is sent by ignored user. State events are never ignored.
+func (checker *visibilityChecker) isIgnored(event *events.RoomEvent) bool {
+	return checker != nil && !event.IsState() && checker.ignored[event.Sender]
+}
@@ -0,0 +1,151 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/rooms"
+	"github.com/signaller-matrix/signaller/internal/models/search"
+)
+
+func setHistoryVisibility(t *testing.T, backend *Backend, room internal.Room, sender internal.User, visibility rooms.HistoryVisibility) {
+	assert.NoError(t, backend.PutEvent(&events.RoomEvent{
+		ContentData: []byte(`{"history_visibility":"` + string(visibility) + `"}`),
+		EType:       events.HistoryVisibility,
+		EventID:     internal.RandomString(eventIDSize),
+		Sender:      sender.ID(),
+		RoomID:      room.ID(),
+		StateKey:    stateKey("")}))
+}
+
+func messageBodies(chunk []events.RoomEvent) []string {
+	var result []string
+	for _, event := range chunk {
+		if event.EType == events.Message {
+			result = append(result, string(event.ContentData))
+		}
+	}
+	return result
+}
+
+func TestHistoryVisibility(t *testing.T) {
+	tests := []struct {
+		visibility rooms.HistoryVisibility
+		messages   int // count of visible messages for user which was invited after first message and joined after second one
+		found      int // count of visible messages sent after invite
+	}{
+		{rooms.HistoryVisibilityShared, 3, 2},
+		{rooms.HistoryVisibilityInvited, 2, 2},
+		{rooms.HistoryVisibilityJoined, 1, 1},
+		{rooms.HistoryVisibilityWorldReadable, 3, 2}}
+
+	for _, test := range tests {
+		backend := newTestBackend(t)
+
+		user1, _, err := backend.Register("user1", "", "")
+		assert.NoError(t, err)
+
+		user2, _, err := backend.Register("user2", "", "")
+		assert.NoError(t, err)
+
+		room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+		assert.NoError(t, err)
+		setHistoryVisibility(t, backend, room, user1, test.visibility)
+
+		assert.NoError(t, user1.SendMessage(room, "before invite"))
+		assert.NoError(t, user1.Invite(room, user2))
+		assert.NoError(t, user1.SendMessage(room, "after invite"))
+		assert.NoError(t, user2.JoinRoom(room))
+		assert.NoError(t, user1.SendMessage(room, "after join"))
+
+		response, err := user2.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+		assert.NoError(t, err)
+		assert.Len(t, messageBodies(response.Chunk), test.messages, string(test.visibility))
+
+		results, err := user2.Search(search.RoomEventsCriteria{SearchTerm: "after"}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, test.found, results.Count, string(test.visibility))
+	}
+}
+
+func TestHistoryVisibilityAfterLeave(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	user3, _, err := backend.Register("user3", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.NoError(t, user1.SendMessage(room, "before leave"))
+	assert.NoError(t, user2.LeaveRoom(room))
+	assert.NoError(t, user1.SendMessage(room, "after leave"))
+
+	response, err := user2.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+	assert.NoError(t, err)
+	bodies := messageBodies(response.Chunk)
+	assert.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], "before leave")
+
+	// Event sent after leave can't be fetched
+	response, err = user1.Messages(room, "", "", messages.DirectionBackward, 1, nil)
+	assert.NoError(t, err)
+	_, err = user2.GetEvent(room, response.Chunk[0].EventID)
+	assert.Error(t, err)
+	_, err = user2.EventContext(room, response.Chunk[0].EventID, 2, nil)
+	assert.Error(t, err)
+
+	// Events of world readable room can be read by anyone, but history visibility
+	// in force at each event is applied, so earlier events are still hidden
+	_, err = user3.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+	assert.Error(t, err)
+
+	assert.False(t, room.WorldReadable())
+	setHistoryVisibility(t, backend, room, user1, rooms.HistoryVisibilityWorldReadable)
+	assert.True(t, room.WorldReadable())
+	assert.NoError(t, user1.SendMessage(room, "world readable"))
+
+	response, err = user3.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+	assert.NoError(t, err)
+	bodies = messageBodies(response.Chunk)
+	assert.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], "world readable")
+}
+
+func TestSharedHistoryVisibilityAfterLeave(t *testing.T) {
+	backend := newTestBackend(t)
+
+	user1, _, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	user2, _, err := backend.Register("user2", "", "")
+	assert.NoError(t, err)
+
+	room, err := user1.CreateRoom(createroom.Request{Name: "room1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, user1.SendMessage(room, "before join"))
+	assert.NoError(t, user2.JoinRoom(room))
+	assert.NoError(t, user2.LeaveRoom(room))
+	assert.NoError(t, user1.SendMessage(room, "after leave"))
+
+	// Shared history sent before join stays visible after user has left
+	response, err := user2.Messages(room, "", "", messages.DirectionBackward, 100, nil)
+	assert.NoError(t, err)
+	bodies := messageBodies(response.Chunk)
+	if assert.Len(t, bodies, 1) {
+		assert.Contains(t, bodies[0], "before join")
+	}
+}
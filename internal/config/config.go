@@ -0,0 +1,355 @@
+// Package config contains server-wide configuration which is loaded from YAML file
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Backend types
+const (
+	BackendMemory = "memory" // all data is stored in memory and is lost on restart
+)
+
+// Registration policies
+const (
+	RegistrationOpen   = "open"   // anyone can register
+	RegistrationClosed = "closed" // nobody can register
+	RegistrationToken  = "token"  // users with registration token issued by administrator can register
+)
+
+// Config is server-wide configuration
+type Config struct {
+	ServerName    string       `yaml:"server_name"`     // Name of server which is used in user IDs, room IDs and aliases
+	PublicBaseURL string       `yaml:"public_base_url"` // URL of client API which is announced to clients after login, not announced if empty
+	Listeners     []Listener   `yaml:"listeners"`       // Addresses to serve client API on
+	Backend       Backend      `yaml:"backend"`         // Storage of server data
+	Registration  Registration `yaml:"registration"`    // Who can register new accounts
+	Usernames     Usernames    `yaml:"usernames"`       // Which usernames can be registered
+	Tokens        Tokens       `yaml:"tokens"`          // Lifetimes of issued tokens
+	Media         Media        `yaml:"media"`           // Limits of content repository
+	RateLimits    RateLimits   `yaml:"rate_limits"`     // Limits of request rate per client
+	Logging       Logging      `yaml:"logging"`         // Where and what to log
+	Capabilities  Capabilities `yaml:"capabilities"`    // Capabilities which are announced to clients
+	SSO           SSO          `yaml:"sso"`             // Single sign-on with OpenID Connect provider
+}
+
+// Listener is address to serve client API on
+type Listener struct {
+	Address string `yaml:"address"` // IP address or host name to bind to, empty for all interfaces
+	Port    int    `yaml:"port"`
+}
+
+// Backend describes storage of server data
+type Backend struct {
+	Type string `yaml:"type"` // One of backend types
+	Path string `yaml:"path"` // Location of data for backends which store it on disk
+}
+
+// Registration describes who can register new accounts
+type Registration struct {
+	Policy       string `yaml:"policy"`        // One of registration policies
+	AllowGuests  bool   `yaml:"allow_guests"`  // Whether guest accounts can be registered
+	SharedSecret string `yaml:"shared_secret"` // Secret for admin registration regardless of policy, empty to disable it
+}
+
+// Usernames describes which usernames can be registered. Usernames are compared in lower case.
+type Usernames struct {
+	MinLength           int      `yaml:"min_length"`           // Minimum length of localpart
+	MaxLength           int      `yaml:"max_length"`           // Maximum length of localpart, zero for limit of user ID length only
+	Reserved            []string `yaml:"reserved"`             // Localparts which can't be registered
+	ExclusiveNamespaces []string `yaml:"exclusive_namespaces"` // Regular expressions of full user IDs which are reserved for application services
+}
+
+// Tokens contains lifetimes of issued tokens
+type Tokens struct {
+	AccessTokenLifetime Duration `yaml:"access_token_lifetime"` // Zero lifetime means that access tokens never expire
+	LoginTokenLifetime  Duration `yaml:"login_token_lifetime"`  // Lifetime of single-use tokens for m.login.token login, can't be zero
+}
+
+// Media contains limits of content repository
+type Media struct {
+	MaxUploadSize int64 `yaml:"max_upload_size"` // Maximum size of uploaded file in bytes
+}
+
+// RateLimits contains limits of request rate per client. Client is identified by valid access token or by IP address.
+type RateLimits struct {
+	Enabled   bool    `yaml:"enabled"`
+	PerSecond float64 `yaml:"per_second"` // Number of requests per second allowed in the long run
+	Burst     int     `yaml:"burst"`      // Number of requests which can be sent at once
+}
+
+// Logging describes where and what to log
+type Logging struct {
+	File     string `yaml:"file"`     // Log file, empty for standard error
+	Requests bool   `yaml:"requests"` // Whether each request should be logged
+}
+
+// Capabilities contains capabilities which are announced to clients
+type Capabilities struct {
+	ChangePassword bool `yaml:"change_password"` // Whether users can change their passwords
+}
+
+// SSO describes single sign-on with OpenID Connect provider. Users are created on first login.
+type SSO struct {
+	Enabled                bool     `yaml:"enabled"`
+	Issuer                 string   `yaml:"issuer"`                   // Issuer URL of provider, discovery document is loaded from it
+	ClientID               string   `yaml:"client_id"`                // Client ID of server registered in provider
+	ClientSecret           string   `yaml:"client_secret"`            // Client secret of server registered in provider
+	Scopes                 []string `yaml:"scopes"`                   // Requested scopes, must contain openid
+	LocalpartTemplate      string   `yaml:"localpart_template"`       // Go template which makes localpart of new user from ID token claims
+	ClientRedirectPrefixes []string `yaml:"client_redirect_prefixes"` // Allowed prefixes of client redirect URLs, at least one is required
+}
+
+// Duration is time.Duration which is written as string like "1h30m" in config
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML parses duration string
+func (duration *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	duration.Duration = parsed
+	return nil
+}
+
+// MarshalYAML writes duration as string
+func (duration Duration) MarshalYAML() (interface{}, error) {
+	return duration.String(), nil
+}
+
+// Default returns config which is used for unspecified values
+func Default() *Config {
+	return &Config{
+		ServerName: "localhost",
+		Listeners:  []Listener{{Port: 8008}},
+		Backend:    Backend{Type: BackendMemory},
+		Registration: Registration{
+			Policy:      RegistrationOpen,
+			AllowGuests: true},
+		Usernames: Usernames{MinLength: 5},
+		Tokens:    Tokens{LoginTokenLifetime: Duration{2 * time.Minute}},
+		Media:     Media{MaxUploadSize: 10 << 20},
+		RateLimits: RateLimits{
+			PerSecond: 10,
+			Burst:     50},
+		Capabilities: Capabilities{ChangePassword: true},
+		SSO: SSO{
+			Scopes:            []string{"openid", "profile"},
+			LocalpartTemplate: "{{.preferred_username}}"}}
+}
+
+// Load reads config from YAML file. Values which are missing in file are taken from default config.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse parses YAML config. Unknown keys are reported as errors.
+func Parse(data []byte) (*Config, error) {
+	config := Default()
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks that config values are correct
+func (config *Config) Validate() error {
+	if config.ServerName == "" {
+		return fmt.Errorf("config: server_name is required")
+	}
+
+	if config.PublicBaseURL != "" {
+		if u, err := url.Parse(config.PublicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: public_base_url must be absolute http or https URL")
+		}
+	}
+
+	if len(config.Listeners) == 0 {
+		return fmt.Errorf("config: at least one listener is required")
+	}
+
+	for i, listener := range config.Listeners {
+		if listener.Port <= 0 || listener.Port > 65535 {
+			return fmt.Errorf("config: listeners[%d]: invalid port number %d", i, listener.Port)
+		}
+	}
+
+	switch config.Backend.Type {
+	case BackendMemory:
+		if config.Backend.Path != "" {
+			return fmt.Errorf("config: backend: %s backend doesn't store data on disk, path must be empty", BackendMemory)
+		}
+	default:
+		return fmt.Errorf("config: backend: unknown type %q", config.Backend.Type)
+	}
+
+	switch config.Registration.Policy {
+	case RegistrationOpen, RegistrationClosed, RegistrationToken:
+	default:
+		return fmt.Errorf("config: registration: unknown policy %q", config.Registration.Policy)
+	}
+
+	if config.Usernames.MinLength < 1 {
+		return fmt.Errorf("config: usernames: min_length must be positive")
+	}
+
+	if config.Usernames.MaxLength != 0 && config.Usernames.MaxLength < config.Usernames.MinLength {
+		return fmt.Errorf("config: usernames: max_length can't be less than min_length")
+	}
+
+	if _, err := config.Usernames.ExclusiveRegexps(); err != nil {
+		return err
+	}
+
+	if config.Tokens.AccessTokenLifetime.Duration < 0 {
+		return fmt.Errorf("config: tokens: access_token_lifetime can't be negative")
+	}
+
+	if config.Tokens.LoginTokenLifetime.Duration <= 0 {
+		return fmt.Errorf("config: tokens: login_token_lifetime must be positive")
+	}
+
+	if config.Media.MaxUploadSize <= 0 {
+		return fmt.Errorf("config: media: max_upload_size must be positive")
+	}
+
+	if config.RateLimits.Enabled && (config.RateLimits.PerSecond <= 0 || config.RateLimits.Burst <= 0) {
+		return fmt.Errorf("config: rate_limits: per_second and burst must be positive")
+	}
+
+	if config.SSO.Enabled {
+		if err := config.validateSSO(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (config *Config) validateSSO() error {
+	if config.PublicBaseURL == "" {
+		return fmt.Errorf("config: sso: public_base_url is required for callback URL")
+	}
+
+	if u, err := url.Parse(config.SSO.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("config: sso: issuer must be absolute http or https URL")
+	}
+
+	if config.SSO.ClientID == "" {
+		return fmt.Errorf("config: sso: client_id is required")
+	}
+
+	hasOpenID := false
+	for _, scope := range config.SSO.Scopes {
+		hasOpenID = hasOpenID || scope == "openid"
+	}
+	if !hasOpenID {
+		return fmt.Errorf("config: sso: scopes must contain openid")
+	}
+
+	if _, err := config.SSO.LocalpartTemplateParsed(); err != nil {
+		return err
+	}
+
+	// Login token must not be sent to arbitrary URL which is passed by attacker
+	if len(config.SSO.ClientRedirectPrefixes) == 0 {
+		return fmt.Errorf("config: sso: client_redirect_prefixes are required")
+	}
+
+	for i, prefix := range config.SSO.ClientRedirectPrefixes {
+		if u, err := url.Parse(prefix); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: sso: client_redirect_prefixes[%d] must be absolute http or https URL", i)
+		}
+	}
+
+	return nil
+}
+
+// LocalpartTemplateParsed returns parsed localpart template. Template fails on missing claims.
+func (sso *SSO) LocalpartTemplateParsed() (*template.Template, error) {
+	tmpl, err := template.New("localpart").Option("missingkey=error").Parse(sso.LocalpartTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("config: sso: localpart_template: %v", err)
+	}
+
+	return tmpl, nil
+}
+
+// ExclusiveRegexps returns compiled exclusive namespaces which match whole user ID
+func (usernames *Usernames) ExclusiveRegexps() ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for i, namespace := range usernames.ExclusiveNamespaces {
+		re, err := regexp.Compile("^(?:" + namespace + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("config: usernames: exclusive_namespaces[%d]: %v", i, err)
+		}
+
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+// Reload returns config with values of next config which can be changed without restart. Names of changed
+// values which require restart are returned too, these values are kept from current config.
+func Reload(current, next *Config) (*Config, []string) {
+	result := *next
+
+	var ignored []string
+	if next.ServerName != current.ServerName {
+		ignored = append(ignored, "server_name")
+		result.ServerName = current.ServerName
+	}
+
+	if !equalListeners(next.Listeners, current.Listeners) {
+		ignored = append(ignored, "listeners")
+		result.Listeners = current.Listeners
+	}
+
+	if next.Backend != current.Backend {
+		ignored = append(ignored, "backend")
+		result.Backend = current.Backend
+	}
+
+	return &result, ignored
+}
+
+func equalListeners(listeners1, listeners2 []Listener) bool {
+	if len(listeners1) != len(listeners2) {
+		return false
+	}
+
+	for i := range listeners1 {
+		if listeners1[i] != listeners2[i] {
+			return false
+		}
+	}
+
+	return true
+}
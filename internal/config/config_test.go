@@ -0,0 +1,118 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDefaults(t *testing.T) {
+	config, err := Parse([]byte("server_name: example.com"))
+	assert.NoError(t, err)
+
+	expected := Default()
+	expected.ServerName = "example.com"
+	assert.Equal(t, expected, config)
+}
+
+func TestParse(t *testing.T) {
+	data := `
+server_name: example.com
+listeners:
+  - address: 127.0.0.1
+    port: 8448
+registration:
+  policy: closed
+  allow_guests: false
+tokens:
+  access_token_lifetime: 1h30m
+rate_limits:
+  enabled: true
+  per_second: 0.5
+  burst: 3
+capabilities:
+  change_password: false
+`
+
+	config, err := Parse([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, []Listener{{Address: "127.0.0.1", Port: 8448}}, config.Listeners)
+	assert.Equal(t, Registration{Policy: RegistrationClosed}, config.Registration)
+	assert.Equal(t, 90*time.Minute, config.Tokens.AccessTokenLifetime.Duration)
+	assert.Equal(t, RateLimits{Enabled: true, PerSecond: 0.5, Burst: 3}, config.RateLimits)
+	assert.False(t, config.Capabilities.ChangePassword)
+	assert.Equal(t, Default().Media, config.Media)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"server_name: ''", "config: server_name is required"},
+		{"listeners: []", "config: at least one listener is required"},
+		{"listeners: [{port: 70000}]", "config: listeners[0]: invalid port number 70000"},
+		{"backend: {type: sql}", `config: backend: unknown type "sql"`},
+		{"backend: {path: /var/lib/signaller}", "config: backend: memory backend doesn't store data on disk, path must be empty"},
+		{"registration: {policy: invite}", `config: registration: unknown policy "invite"`},
+		{"usernames: {min_length: 0}", "config: usernames: min_length must be positive"},
+		{"usernames: {min_length: 5, max_length: 4}", "config: usernames: max_length can't be less than min_length"},
+		{"usernames: {exclusive_namespaces: ['@irc_(.*']}", "config: usernames: exclusive_namespaces[0]: error parsing regexp: missing closing ): `^(?:@irc_(.*)$`"},
+		{"tokens: {access_token_lifetime: -1h}", "config: tokens: access_token_lifetime can't be negative"},
+		{"tokens: {login_token_lifetime: 0s}", "config: tokens: login_token_lifetime must be positive"},
+		{"public_base_url: matrix.example.com", "config: public_base_url must be absolute http or https URL"},
+		{"media: {max_upload_size: 0}", "config: media: max_upload_size must be positive"},
+		{"rate_limits: {enabled: true, burst: 0}", "config: rate_limits: per_second and burst must be positive"},
+		{"sso: {enabled: true}", "config: sso: public_base_url is required for callback URL"},
+		{"public_base_url: https://m.example.com\nsso: {enabled: true, issuer: idp.example.com}", "config: sso: issuer must be absolute http or https URL"},
+		{"public_base_url: https://m.example.com\nsso: {enabled: true, issuer: https://idp.example.com}", "config: sso: client_id is required"},
+		{"public_base_url: https://m.example.com\nsso: {enabled: true, issuer: https://idp.example.com, client_id: c, scopes: [profile]}", "config: sso: scopes must contain openid"},
+		{"public_base_url: https://m.example.com\nsso: {enabled: true, issuer: https://idp.example.com, client_id: c, localpart_template: '{{.sub'}", "config: sso: localpart_template: template: localpart:1: unclosed action"},
+		{"public_base_url: https://m.example.com\nsso: {enabled: true, issuer: https://idp.example.com, client_id: c}", "config: sso: client_redirect_prefixes are required"},
+		{"public_base_url: https://m.example.com\nsso: {enabled: true, issuer: https://idp.example.com, client_id: c, client_redirect_prefixes: [client.example.com]}", "config: sso: client_redirect_prefixes[0] must be absolute http or https URL"}}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.data))
+		if assert.Error(t, err, test.data) {
+			assert.Equal(t, test.expected, err.Error())
+		}
+	}
+
+	_, err := Parse([]byte("unknown_key: 1"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte("tokens: {access_token_lifetime: week}"))
+	assert.Error(t, err)
+}
+
+func TestExclusiveRegexps(t *testing.T) {
+	usernames := Usernames{ExclusiveNamespaces: []string{"@irc_.*:example.com", "@bot"}}
+
+	regexps, err := usernames.ExclusiveRegexps()
+	assert.NoError(t, err)
+	assert.Len(t, regexps, 2)
+	assert.True(t, regexps[0].MatchString("@irc_user:example.com"))
+	assert.False(t, regexps[0].MatchString("@user_irc_user:example.com"))
+	assert.False(t, regexps[1].MatchString("@bot1:example.com"))
+}
+
+func TestReload(t *testing.T) {
+	current := Default()
+
+	next := Default()
+	next.ServerName = "example.com"
+	next.Listeners = []Listener{{Port: 8448}}
+	next.Registration.Policy = RegistrationClosed
+	next.RateLimits.Enabled = true
+
+	config, ignored := Reload(current, next)
+	assert.Equal(t, []string{"server_name", "listeners"}, ignored)
+	assert.Equal(t, current.ServerName, config.ServerName)
+	assert.Equal(t, current.Listeners, config.Listeners)
+	assert.Equal(t, RegistrationClosed, config.Registration.Policy)
+	assert.True(t, config.RateLimits.Enabled)
+
+	_, ignored = Reload(current, Default())
+	assert.Empty(t, ignored)
+}
@@ -2,6 +2,8 @@ package internal
 
 const (
 	Version = "r0.5.0"
+
+	deviceIDSize = 5 // size of random part of generated device IDs
 )
 
 // https://matrix.org/docs/spec/client_server/latest#authentication-types
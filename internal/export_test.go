@@ -0,0 +1,11 @@
+package internal
+
+import "net/http"
+
+// Handler returns handler of first listener, it is used by tests which serve client API without listening
+func (server *Server) Handler() http.Handler {
+	return server.httpServers[0].Handler
+}
+
+// IsAllowedSSORedirect is exported for tests of client redirect URL matching
+var IsAllowedSSORedirect = isAllowedSSORedirect
@@ -2,30 +2,49 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/signaller-matrix/signaller/internal/models/createroom"
 
 	"github.com/gorilla/mux"
 
+	"github.com/signaller-matrix/signaller/internal/config"
 	"github.com/signaller-matrix/signaller/internal/models"
 	"github.com/signaller-matrix/signaller/internal/models/capabilities"
 	"github.com/signaller-matrix/signaller/internal/models/common"
 	"github.com/signaller-matrix/signaller/internal/models/devices"
+	"github.com/signaller-matrix/signaller/internal/models/eventcontext"
+	"github.com/signaller-matrix/signaller/internal/models/events"
 	"github.com/signaller-matrix/signaller/internal/models/filter"
 	"github.com/signaller-matrix/signaller/internal/models/joinedrooms"
 	"github.com/signaller-matrix/signaller/internal/models/listroom"
 	"github.com/signaller-matrix/signaller/internal/models/login"
+	"github.com/signaller-matrix/signaller/internal/models/media"
+	"github.com/signaller-matrix/signaller/internal/models/members"
+	"github.com/signaller-matrix/signaller/internal/models/messages"
+	"github.com/signaller-matrix/signaller/internal/models/notifications"
 	"github.com/signaller-matrix/signaller/internal/models/password"
+	"github.com/signaller-matrix/signaller/internal/models/peek"
 	"github.com/signaller-matrix/signaller/internal/models/publicrooms"
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+	"github.com/signaller-matrix/signaller/internal/models/receipt"
 	"github.com/signaller-matrix/signaller/internal/models/register"
 	"github.com/signaller-matrix/signaller/internal/models/registeravailable"
 	"github.com/signaller-matrix/signaller/internal/models/roomalias"
+	"github.com/signaller-matrix/signaller/internal/models/roominitialsync"
+	"github.com/signaller-matrix/signaller/internal/models/roomupgrade"
+	"github.com/signaller-matrix/signaller/internal/models/search"
+	"github.com/signaller-matrix/signaller/internal/models/sendmessage"
 	mSync "github.com/signaller-matrix/signaller/internal/models/sync"
+	"github.com/signaller-matrix/signaller/internal/models/tags"
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
 	"github.com/signaller-matrix/signaller/internal/models/versions"
 	"github.com/signaller-matrix/signaller/internal/models/whoami"
 )
@@ -54,36 +73,179 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 			response := login.GetReply{
 				Flows: []login.Flow{
 					login.Flow{Type: common.AuthenticationTypePassword},
+					login.Flow{Type: common.AuthenticationTypeToken},
 				},
 			}
 
+			if currServer.Config().SSO.Enabled {
+				response.Flows = append(response.Flows, login.Flow{Type: common.AuthenticationTypeSSO})
+			}
+
 			sendJsonResponse(w, http.StatusOK, response)
 		}
 	// https://models.org/docs/spec/client_server/latest#post-models-client-r0-login
 	case "POST":
 		{
 			var request login.PostRequest
-			getRequest(r, &request) // TODO: handle error
+			if err := getRequest(r, &request); err != nil {
+				errorResponse(w, models.M_NOT_JSON, http.StatusBadRequest, err.Error())
+				return
+			}
 
-			// delete start "@" if presents
-			if strings.HasPrefix(request.Identifier.User, "@") {
-				request.Identifier.User = strings.TrimPrefix(request.Identifier.User, "@")
+			if request.DeviceID == "" {
+				request.DeviceID = RandomString(deviceIDSize)
+			}
+
+			var (
+				user   User
+				token  string
+				apiErr models.ApiError
+			)
+
+			switch request.Type {
+			case common.AuthenticationTypePassword:
+				var username string
+				if username, apiErr = loginUsername(request.Identifier); apiErr == nil {
+					user, token, apiErr = currServer.Backend.Login(username, request.Password, request.DeviceID)
+				}
+			case common.AuthenticationTypeToken:
+				user, token, apiErr = currServer.Backend.LoginByToken(request.Token, request.DeviceID)
+			default:
+				apiErr = models.NewError(models.M_UNKNOWN, "unsupported login type: "+string(request.Type))
 			}
 
-			_, token, apiErr := currServer.Backend.Login(request.Identifier.User, request.Password, request.DeviceID)
 			if apiErr != nil {
-				errorResponse(w, apiErr, http.StatusForbidden, "")
+				errorResponse(w, apiErr, loginHTTPCode(apiErr), "")
 				return
 			}
 
+			if request.InitialDeviceDisplayName != "" && user.IsNewDevice(request.DeviceID) {
+				user.SetDeviceDisplayName(request.DeviceID, request.InitialDeviceDisplayName)
+			}
+
 			response := login.PostReply{
-				UserID:      request.Identifier.User,
+				UserID:      user.ID(),
 				AccessToken: token,
+				HomeServer:  currServer.Address,
+				DeviceID:    request.DeviceID,
+			}
+
+			if baseURL := currServer.Config().PublicBaseURL; baseURL != "" {
+				response.WellKnown = &login.DiscoveryInformation{
+					HomeServer: login.HomeserverInformation{BaseURL: baseURL}}
 			}
 
 			sendJsonResponse(w, http.StatusOK, response)
 		}
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// loginUsername returns localpart of user which is identified by identifier of login request
+// https://matrix.org/docs/spec/client_server/latest#identifier-types
+func loginUsername(identifier common.UserIdentifier) (string, models.ApiError) {
+	switch identifier.Type {
+	case common.IdentifierTypeUser:
+		username := identifier.User
+		if strings.HasPrefix(username, "@") {
+			localpart, serverName, err := SplitUserID(username)
+			if err != nil {
+				return "", models.NewError(models.M_INVALID_PARAM, err.Error())
+			}
+
+			if serverName != currServer.Address {
+				return "", models.NewError(models.M_FORBIDDEN, "user is not registered on this server")
+			}
+
+			username = localpart
+		}
+
+		return NormalizeUsername(username), nil
+	case common.IdentifierTypeThirdparty, common.IdentifierTypePhone:
+		medium, address := identifier.Medium, identifier.Address
+		if identifier.Type == common.IdentifierTypePhone {
+			msisdn, err := PhoneToMSISDN(identifier.Country, identifier.Phone)
+			if err != nil {
+				return "", models.NewError(models.M_INVALID_PARAM, err.Error())
+			}
+
+			medium, address = threepids.MediumMSISDN, msisdn
+		}
+
+		user := currServer.Backend.GetUserByThreePID(medium, address)
+		if user == nil {
+			return "", models.NewError(models.M_FORBIDDEN, "unknown third party identifier")
+		}
+
+		return user.Name(), nil
+	}
+
+	return "", models.NewError(models.M_UNKNOWN, "unsupported identifier type: "+string(identifier.Type))
+}
+
+func loginHTTPCode(err models.ApiError) int {
+	switch err.Code() {
+	case models.M_FORBIDDEN.Code():
+		return http.StatusForbidden
+	}
+
+	return http.StatusBadRequest
+}
+
+// https://github.com/matrix-org/matrix-spec-proposals/pull/3882
+func loginGetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusUnauthorized, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusUnauthorized, "")
+		return
+	}
+
+	if user.IsGuest() {
+		errorResponse(w, models.M_GUEST_ACCESS_FORBIDDEN, http.StatusForbidden, "")
+		return
+	}
+
+	lifetime := currServer.Config().Tokens.LoginTokenLifetime.Duration
+
+	response := login.GetTokenReply{
+		LoginToken:  currServer.Backend.CreateLoginToken(user, lifetime),
+		ExpiresInMs: int64(lifetime / time.Millisecond)}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-account-3pid
+func threePIDsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
 	}
+
+	sendJsonResponse(w, http.StatusOK, threepids.Response{ThreePIDs: user.ThreePIDs()})
 }
 
 // https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-leave
@@ -108,7 +270,7 @@ func leaveRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomID"]) // TODO: can ["roomID"] throw panic?
 	if room == nil {
-		errorResponse(w, models.M_NOT_FOUND, http.StatusBadRequest, "room not found")
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
 		return
 	}
 
@@ -178,25 +340,169 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	kind := r.FormValue("kind")
-	if kind != "user" {
+	kind := r.URL.Query().Get("kind")
+	if kind == "" {
+		kind = "user"
+	}
+	if kind != "user" && kind != "guest" {
 		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong kind: "+kind)
 		return
 	}
 
+	registration := currServer.Config().Registration
+	if kind == "guest" && !registration.AllowGuests {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "guest access is disabled")
+		return
+	}
+
 	var request register.RegisterRequest
-	getRequest(r, &request) // TODO: handle error
+	if err := getRequest(r, &request); err != nil {
+		errorResponse(w, models.M_NOT_JSON, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if kind == "user" {
+		if registration.Policy == config.RegistrationClosed {
+			errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "registration is disabled")
+			return
+		}
+
+		if !registrationAuthCompleted(w, registration.Policy, request.Auth) {
+			return
+		}
+	}
+
+	if request.DeviceID == "" {
+		request.DeviceID = RandomString(deviceIDSize)
+	}
+
+	var (
+		user   User
+		token  string
+		apiErr models.ApiError
+	)
+
+	switch {
+	case kind == "guest":
+		user, token, apiErr = currServer.Backend.RegisterGuest(request.DeviceID)
+	case getTokenFromResponse(r) != "": // guest account upgrade
+		user = currServer.Backend.GetUserByToken(getTokenFromResponse(r))
+		if user == nil {
+			apiErr = models.NewError(models.M_UNKNOWN_TOKEN, "")
+			break
+		}
+
+		if request.Username != "" && request.Username != user.Name() {
+			apiErr = models.NewError(models.M_INVALID_USERNAME, "user ID of guest account can't be changed")
+			break
+		}
+
+		if apiErr = user.UpgradeGuest(request.Password); apiErr == nil && !request.InhibitLogin {
+			user, token, apiErr = currServer.Backend.Login(user.Name(), request.Password, request.DeviceID)
+		}
+	case request.InhibitLogin:
+		user, apiErr = currServer.Backend.CreateUser(request.Username, request.Password)
+	default:
+		user, token, apiErr = currServer.Backend.Register(request.Username, request.Password, request.DeviceID)
+	}
+
+	if kind == "user" && request.Auth.Type == common.AuthenticationTypeRegistrationToken {
+		currServer.Backend.ReleaseRegistrationToken(request.Auth.Token, apiErr == nil)
+	}
 
-	_, token, apiErr := currServer.Backend.Register(request.Username, request.Password, request.DeviceID)
 	if apiErr != nil {
-		errorResponse(w, apiErr, http.StatusBadRequest, "")
+		errorResponse(w, apiErr, registerHTTPCode(apiErr), "")
 		return
 	}
 
+	currServer.authSessions.take(request.Auth.Session)
+
+	if token != "" && request.InitialDeviceDisplayName != "" {
+		user.SetDeviceDisplayName(request.DeviceID, request.InitialDeviceDisplayName)
+	}
+
 	var response register.RegisterResponse
-	response.UserID = "@" + request.Username
-	response.DeviceID = request.DeviceID
-	response.AccessToken = token
+	response.UserID = user.ID()
+	if !request.InhibitLogin || kind == "guest" {
+		response.DeviceID = request.DeviceID
+		response.AccessToken = token
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// registrationAuthCompleted checks authentication data of registration request according to registration policy.
+// If authentication isn't completed, 401 response with required flows is sent. For completed registration token
+// stage one use of token is reserved, it must be released after registration.
+// https://matrix.org/docs/spec/client_server/latest#user-interactive-authentication-api
+func registrationAuthCompleted(w http.ResponseWriter, policy string, auth register.AuthenticationData) bool {
+	stage := common.AuthenticationTypeDummy
+	if policy == config.RegistrationToken {
+		stage = common.AuthenticationTypeRegistrationToken
+	}
+
+	// requests without authentication data are accepted for open registration for compatibility with old clients
+	if stage == common.AuthenticationTypeDummy && (auth.Type == "" || auth.Type == stage) {
+		return true
+	}
+
+	response := register.AuthResponse{
+		Flows:   []register.AuthFlow{{Stages: []common.AuthenticationType{stage}}},
+		Params:  map[common.AuthenticationType]map[string]string{},
+		Session: auth.Session}
+
+	if !currServer.authSessions.valid(auth.Session) {
+		response.Session = currServer.authSessions.new()
+		sendJsonResponse(w, http.StatusUnauthorized, response)
+		return false
+	}
+
+	if auth.Type != stage {
+		response.ErrCode = models.M_UNAUTHORIZED.Code()
+		response.Error = "unsupported authentication type: " + string(auth.Type)
+		sendJsonResponse(w, http.StatusUnauthorized, response)
+		return false
+	}
+
+	if apiErr := currServer.Backend.ReserveRegistrationToken(auth.Token); apiErr != nil {
+		response.ErrCode = apiErr.Code()
+		response.Error = apiErr.Message()
+		sendJsonResponse(w, http.StatusUnauthorized, response)
+		return false
+	}
+
+	return true
+}
+
+func registerHTTPCode(err models.ApiError) int {
+	switch err.Code() {
+	case models.M_UNKNOWN_TOKEN.Code():
+		return http.StatusForbidden
+	}
+
+	return http.StatusBadRequest
+}
+
+// https://spec.matrix.org/v1.2/client-server-api/#get_matrixclientv1registermloginregistration_tokenvalidity
+func registrationTokenValidityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	if currServer.Config().Registration.Policy != config.RegistrationToken {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "registration tokens are not used")
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	response := register.TokenValidityResponse{
+		Valid: currServer.Backend.IsRegistrationTokenValid(token)}
 
 	sendJsonResponse(w, http.StatusOK, response)
 }
@@ -268,6 +574,16 @@ func PasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.IsGuest() {
+		errorResponse(w, models.M_GUEST_ACCESS_FORBIDDEN, http.StatusForbidden, "")
+		return
+	}
+
+	if !currServer.Config().Capabilities.ChangePassword {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "password changing is disabled")
+		return
+	}
+
 	var request password.Request
 	getRequest(r, &request) // TODO: handle error
 
@@ -280,13 +596,17 @@ func PasswordHandler(w http.ResponseWriter, r *http.Request) {
 func SyncHandler(w http.ResponseWriter, r *http.Request) {
 	var request mSync.SyncRequest
 	request.Filter = r.FormValue("filter")
+	request.Since = r.FormValue("since")
+	request.FullState = r.FormValue("full_state") == "true"
 
-	timeout, err := strconv.Atoi(r.FormValue("timeout"))
-	if err != nil {
-		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "timeout parse failed")
-		return
+	if r.FormValue("timeout") != "" {
+		timeout, err := strconv.Atoi(r.FormValue("timeout"))
+		if err != nil {
+			errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "timeout parse failed")
+			return
+		}
+		request.Timeout = timeout
 	}
-	request.Timeout = timeout
 
 	token := getTokenFromResponse(r)
 	if token == "" {
@@ -300,7 +620,11 @@ func SyncHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, _ := user.Sync(token, request) // TODO: handle error
+	response, apiErr := user.Sync(token, request)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
 
 	sendJsonResponse(w, http.StatusOK, response)
 }
@@ -325,12 +649,12 @@ func CapabilitiesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var response capabilities.Response
-	response.Capabilities = currServer.Capabilities
+	response.Capabilities.ChangePassword.Enabled = currServer.Config().Capabilities.ChangePassword
+	response.Capabilities.RoomVersions = currServer.Backend.RoomVersions()
 
 	sendJsonResponse(w, http.StatusOK, response)
 }
 
-//
 func AddFilterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
@@ -418,21 +742,30 @@ func DevicesHandler(w http.ResponseWriter, r *http.Request) {
 
 // https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-register-available
 func registerAvailableHandler(w http.ResponseWriter, r *http.Request) {
-	var request registeravailable.Request
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
 
-	err := getRequest(r, &request)
-	if err != nil {
-		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+	username := NormalizeUsername(r.URL.Query().Get("username"))
+	if username == "" {
+		errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "username is required")
 		return
 	}
 
-	user := currServer.Backend.GetUserByName(request.Username) // TODO: add validation of username
-	if user != nil {
+	if validate := currServer.Backend.ValidateUsernameFunc(); validate != nil {
+		if apiErr := validate(username); apiErr != nil {
+			errorResponse(w, apiErr, http.StatusBadRequest, "")
+			return
+		}
+	}
+
+	if currServer.Backend.GetUserByName(username) != nil {
 		errorResponse(w, models.M_USER_IN_USE, http.StatusBadRequest, "Desired user ID is already taken.")
 		return
 	}
 
-	response := registeravailable.Response{Available: false}
+	response := registeravailable.Response{Available: true}
 	sendJsonResponse(w, http.StatusOK, response)
 }
 
@@ -441,7 +774,7 @@ func registerAvailableHandler(w http.ResponseWriter, r *http.Request) {
 func listRoomHandler(w http.ResponseWriter, r *http.Request) {
 	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomID"]) // TODO: can ["roomID"] throw panic?
 	if room == nil {
-		errorResponse(w, models.M_NOT_FOUND, http.StatusBadRequest, "room not found")
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
 		return
 	}
 
@@ -470,7 +803,10 @@ func listRoomHandler(w http.ResponseWriter, r *http.Request) {
 			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
 			return
 		}
-		user.SetRoomVisibility(room, request.Visibility)
+		if apiErr := user.SetRoomVisibility(room, request.Visibility); apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
 
 		sendJsonResponse(w, http.StatusOK, struct{}{})
 	default:
@@ -486,61 +822,48 @@ func publicRoomsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request publicrooms.Request
-	err := getRequest(r, &request)
-	if err != nil {
-		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	if request.Limit <= 0 {
-		request.Limit = 50 // TODO: move to const
-	}
 
 	if r.Method == http.MethodPost {
 		token := getTokenFromResponse(r)
 		if token == "" {
-			errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "")
+			errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
 			return
 		}
 
 		user := currServer.Backend.GetUserByToken(token)
 		if user == nil {
-			errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusBadRequest, "")
+			errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
 			return
 		}
-	}
-
-	publicRooms := currServer.Backend.PublicRooms(request.Filter.GenericSearchTerm) // TODO: make Post request as User method
-	chunks := roomsToPublicRoomsChunks(publicRooms)
 
-	var response publicrooms.Response
-
-	// TODO: test and rewrite this code
-	if request.Since == "" {
-		if request.Limit >= len(chunks) {
-			response.Chunk = chunks
-			// TODO: should fill response.TotalRoomCountEstimate?
-		} else {
-			response.Chunk = chunks[:request.Limit]
-			response.NextBatch = strconv.Itoa(request.Limit + 1)
-			response.TotalRoomCountEstimate = len(chunks)
+		if err := getRequest(r, &request); err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
 		}
 	} else {
-		response.PrevBatch = "0"
+		request.Since = r.FormValue("since")
 
-		from, err := strconv.Atoi(request.Since)
-		if err != nil {
-			errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "Wrong Since field specified") // TODO: check code
-			return
-		}
-		if len(chunks) >= from {
-			if request.Limit >= len(chunks) {
-				response.Chunk = chunks[from : from+request.Limit]
-			} else {
-				response.Chunk = chunks[from:]
+		if r.FormValue("limit") != "" {
+			limit, err := strconv.Atoi(r.FormValue("limit"))
+			if err != nil {
+				errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "limit must be integer")
+				return
 			}
+			request.Limit = limit
 		}
 	}
+
+	// Server is query parameter for both methods
+	if server := r.FormValue("server"); server != "" {
+		request.Server = server
+	}
+
+	response, apiErr := currServer.Backend.PublicRooms(request)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
 	sendJsonResponse(w, http.StatusOK, response)
 }
 
@@ -566,8 +889,8 @@ func createRoomHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	room, apiErr := user.CreateRoom(request)
-	if err != nil {
-		errorResponse(w, apiErr, http.StatusBadRequest, apiErr.Error())
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), apiErr.Error())
 		return
 	}
 
@@ -579,7 +902,11 @@ func createRoomHandler(w http.ResponseWriter, r *http.Request) {
 // https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-directory-room-roomalias
 // https://matrix.org/docs/spec/client_server/latest#delete-matrix-client-r0-directory-room-roomalias
 func roomAliasHandler(w http.ResponseWriter, r *http.Request) {
-	roomAlias := mux.Vars(r)["roomAlias"] // TODO: add validation of room alias
+	roomAlias := mux.Vars(r)["roomAlias"]
+	if _, _, err := SplitAlias(roomAlias); err != nil {
+		errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	var user User
 
@@ -627,15 +954,15 @@ func roomAliasHandler(w http.ResponseWriter, r *http.Request) {
 
 		err := user.AddRoomAlias(room, roomAlias)
 		if err != nil {
-			errorResponse(w, err, http.StatusConflict, "") // TODO: check http code
+			errorResponse(w, err, roomAliasHTTPCode(err), "")
 			return
 		}
 
 		response = struct{}{}
 	case http.MethodDelete:
-		err := user.DeleteRoomAlias(StripAlias(currServer.Address, roomAlias))
+		err := user.DeleteRoomAlias(roomAlias)
 		if err != nil {
-			errorResponse(w, err, http.StatusConflict, "") // TODO: check http code
+			errorResponse(w, err, roomAliasHTTPCode(err), "")
 			return
 		}
 
@@ -645,6 +972,37 @@ func roomAliasHandler(w http.ResponseWriter, r *http.Request) {
 	sendJsonResponse(w, http.StatusOK, response)
 }
 
+// roomAliasHTTPCode returns HTTP code of room alias directory error. Conflicting alias is reported as M_UNKNOWN.
+func roomAliasHTTPCode(err models.ApiError) int {
+	if err.Code() == models.M_UNKNOWN.Code() {
+		return http.StatusConflict
+	}
+
+	return apiErrorHTTPCode(err)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-media-r0-config
+func mediaConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	if currServer.Backend.GetUserByToken(token) == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, media.ConfigResponse{
+		UploadSize: currServer.Config().Media.MaxUploadSize})
+}
+
 func sendJsonResponse(w http.ResponseWriter, httpStatus int, data interface{}) error {
 	b, err := json.Marshal(data)
 	if err != nil {
@@ -665,3 +1023,1064 @@ func getRequest(r *http.Request, request interface{}) error {
 
 	return json.Unmarshal(b, request)
 }
+
+// getJSONObject returns body of request which must be JSON object
+func getJSONObject(r *http.Request) (json.RawMessage, error) {
+	var content map[string]json.RawMessage
+	if err := getRequest(r, &content); err != nil {
+		return nil, err
+	}
+
+	if content == nil {
+		return nil, errors.New("content must be JSON object")
+	}
+
+	return json.Marshal(content)
+}
+
+// getRoomEventFilter returns room event filter passed as JSON in filter query parameter
+func getRoomEventFilter(r *http.Request) (*filter.RoomEventFilter, error) {
+	if r.FormValue("filter") == "" {
+		return nil, nil
+	}
+
+	eventFilter := new(filter.RoomEventFilter)
+	err := json.Unmarshal([]byte(r.FormValue("filter")), eventFilter)
+
+	return eventFilter, err
+}
+
+// getOptionalUser returns user by access token of request or nil if request is unauthenticated.
+// If token is unknown error response is sent and ok is false.
+func getOptionalUser(w http.ResponseWriter, r *http.Request) (user User, ok bool) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		return nil, true
+	}
+
+	user = currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules-scope-kind-ruleid
+func pushRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	ruleset := user.PushRules()
+
+	vars := mux.Vars(r)
+	scope, scopeExists := vars["scope"]
+	if !scopeExists {
+		sendJsonResponse(w, http.StatusOK, pushrules.Response{Global: ruleset})
+		return
+	}
+
+	if scope != pushrules.ScopeGlobal {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "unknown scope "+scope)
+		return
+	}
+
+	kind, kindExists := vars["kind"]
+	if !kindExists {
+		sendJsonResponse(w, http.StatusOK, ruleset)
+		return
+	}
+
+	if !pushrules.IsValidKind(pushrules.Kind(kind)) {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "unknown kind "+kind)
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, ruleset.Rules(pushrules.Kind(kind)))
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules-scope-kind-ruleid
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid
+// https://matrix.org/docs/spec/client_server/latest#delete-matrix-client-r0-pushrules-scope-kind-ruleid
+func pushRuleHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if vars["scope"] != pushrules.ScopeGlobal {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "unknown scope "+vars["scope"])
+		return
+	}
+	kind := pushrules.Kind(vars["kind"])
+	ruleID := vars["ruleId"]
+
+	switch r.Method {
+	case http.MethodGet:
+		ruleset := user.PushRules()
+		rule, err := ruleset.Rule(kind, ruleID)
+		if err != nil {
+			errorResponse(w, err, http.StatusNotFound, "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, rule)
+	case http.MethodPut:
+		var request pushrules.PutRequest
+		err := getRequest(r, &request)
+		if err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rule := pushrules.PushRule{
+			RuleID:     ruleID,
+			Actions:    request.Actions,
+			Conditions: request.Conditions,
+			Pattern:    request.Pattern}
+
+		apiErr := user.AddPushRule(kind, rule, r.FormValue("before"), r.FormValue("after"))
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	case http.MethodDelete:
+		apiErr := user.DeletePushRule(kind, ruleID)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules-scope-kind-ruleid-enabled
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid-enabled
+func pushRuleEnabledHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if vars["scope"] != pushrules.ScopeGlobal {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "unknown scope "+vars["scope"])
+		return
+	}
+	kind := pushrules.Kind(vars["kind"])
+	ruleID := vars["ruleId"]
+
+	switch r.Method {
+	case http.MethodGet:
+		ruleset := user.PushRules()
+		rule, err := ruleset.Rule(kind, ruleID)
+		if err != nil {
+			errorResponse(w, err, http.StatusNotFound, "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, pushrules.EnabledRequest{Enabled: rule.Enabled})
+	case http.MethodPut:
+		var request pushrules.EnabledRequest
+		err := getRequest(r, &request)
+		if err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apiErr := user.SetPushRuleEnabled(kind, ruleID, request.Enabled)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules-scope-kind-ruleid-actions
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid-actions
+func pushRuleActionsHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if vars["scope"] != pushrules.ScopeGlobal {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "unknown scope "+vars["scope"])
+		return
+	}
+	kind := pushrules.Kind(vars["kind"])
+	ruleID := vars["ruleId"]
+
+	switch r.Method {
+	case http.MethodGet:
+		ruleset := user.PushRules()
+		rule, err := ruleset.Rule(kind, ruleID)
+		if err != nil {
+			errorResponse(w, err, http.StatusNotFound, "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, pushrules.ActionsRequest{Actions: rule.Actions})
+	case http.MethodPut:
+		var request pushrules.ActionsRequest
+		err := getRequest(r, &request)
+		if err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apiErr := user.SetPushRuleActions(kind, ruleID, request.Actions)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushers
+func pushersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	response := pushers.GetResponse{Pushers: user.Pushers()}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-pushers-set
+func setPusherHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	var request pushers.SetRequest
+	err := getRequest(r, &request)
+	if err != nil {
+		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	apiErr := user.SetPusher(request)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, struct{}{})
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-notifications
+func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	var limit int
+	if r.FormValue("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(r.FormValue("limit"))
+		if err != nil || limit < 0 {
+			errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "wrong limit")
+			return
+		}
+	}
+
+	only := r.FormValue("only")
+	if only != "" && only != notifications.OnlyHighlight {
+		errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "wrong only parameter: "+only)
+		return
+	}
+
+	response, apiErr := user.Notifications(r.FormValue("from"), limit, only)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-receipt-receipttype-eventid
+func receiptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	if receipt.ReceiptType(vars["receiptType"]) != receipt.ReceiptTypeRead {
+		errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "unsupported receipt type: "+vars["receiptType"])
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(vars["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	apiErr := user.SetReadReceipt(room, vars["eventId"])
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, struct{}{})
+}
+
+func apiErrorHTTPCode(err models.ApiError) int {
+	switch err.Code() {
+	case models.M_NOT_FOUND.Code():
+		return http.StatusNotFound
+	case models.M_FORBIDDEN.Code(), models.M_GUEST_ACCESS_FORBIDDEN.Code():
+		return http.StatusForbidden
+	}
+
+	return http.StatusBadRequest
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-search
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	var request search.Request
+	err := getRequest(r, &request)
+	if err != nil {
+		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var response search.Response
+
+	if request.SearchCategories.RoomEvents != nil {
+		if request.SearchCategories.RoomEvents.SearchTerm == "" {
+			errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "search_term is required")
+			return
+		}
+
+		results, apiErr := user.Search(*request.SearchCategories.RoomEvents, r.URL.Query().Get("next_batch"))
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		response.SearchCategories.RoomEvents = results
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-messages
+func roomMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	// Unauthenticated users can view world readable rooms
+	user, ok := getOptionalUser(w, r)
+	if !ok {
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	var limit int
+	if r.FormValue("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(r.FormValue("limit"))
+		if err != nil || limit < 0 {
+			errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "wrong limit")
+			return
+		}
+	}
+
+	eventFilter, err := getRoomEventFilter(r)
+	if err != nil {
+		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, "wrong filter: "+err.Error())
+		return
+	}
+
+	dir := messages.Direction(r.FormValue("dir"))
+	if dir == "" {
+		errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "dir is required")
+		return
+	}
+
+	var (
+		response *messages.Response
+		apiErr   models.ApiError
+	)
+	if user != nil {
+		response, apiErr = user.Messages(room, r.FormValue("from"), r.FormValue("to"), dir, limit, eventFilter)
+	} else {
+		response, apiErr = currServer.Backend.PeekMessages(room, r.FormValue("from"), r.FormValue("to"), dir, limit, eventFilter)
+	}
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-context-eventid
+func eventContextHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	room := currServer.Backend.GetRoomByID(vars["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	limit := eventcontext.DefaultLimit
+	if r.FormValue("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(r.FormValue("limit"))
+		if err != nil || limit < 0 {
+			errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "wrong limit")
+			return
+		}
+	}
+
+	eventFilter, err := getRoomEventFilter(r)
+	if err != nil {
+		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, "wrong filter: "+err.Error())
+		return
+	}
+
+	response, apiErr := user.EventContext(room, vars["eventId"], limit, eventFilter)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-event-eventid
+func roomEventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	room := currServer.Backend.GetRoomByID(vars["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	event, apiErr := user.GetEvent(room, vars["eventId"])
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, event)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-members
+func roomMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	chunk, apiErr := user.Members(room, r.FormValue("at"), events.Membership(r.FormValue("membership")), events.Membership(r.FormValue("not_membership")))
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, members.Response{Chunk: chunk})
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-joined-members
+func joinedMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	joined, apiErr := user.JoinedMembers(room)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, members.JoinedResponse{Joined: joined})
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-initialsync
+func roomInitialSyncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	// Unauthenticated users can view world readable rooms
+	user, ok := getOptionalUser(w, r)
+	if !ok {
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	var (
+		response *roominitialsync.Response
+		apiErr   models.ApiError
+	)
+	if user != nil {
+		response, apiErr = user.RoomInitialSync(room)
+	} else {
+		response, apiErr = currServer.Backend.PeekRoomInitialSync(room)
+	}
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-events
+func peekEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	// Unauthenticated users can peek into world readable rooms
+	user, ok := getOptionalUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.FormValue("room_id") == "" {
+		errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "room_id is required") // TODO: event stream of all rooms of user
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(r.FormValue("room_id"))
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	timeout := peek.DefaultTimeout
+	if r.FormValue("timeout") != "" {
+		milliseconds, err := strconv.Atoi(r.FormValue("timeout"))
+		if err != nil || milliseconds < 0 {
+			errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "wrong timeout")
+			return
+		}
+		// Milliseconds are clamped before conversion, so huge value doesn't overflow duration
+		if maxMilliseconds := int(peek.MaxTimeout / time.Millisecond); milliseconds > maxMilliseconds {
+			milliseconds = maxMilliseconds
+		}
+		timeout = time.Duration(milliseconds) * time.Millisecond
+	}
+
+	var (
+		response *peek.Response
+		apiErr   models.ApiError
+	)
+	if user != nil {
+		response, apiErr = user.PeekEvents(room, r.FormValue("from"), timeout)
+	} else {
+		response, apiErr = currServer.Backend.PeekEvents(room, r.FormValue("from"), timeout)
+	}
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, response)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-user-userid-account-data-type
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-user-userid-account-data-type
+func accountDataHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if vars["userId"] != user.ID() {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "account data of other users can't be accessed")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		content, apiErr := user.AccountData(vars["type"])
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, content)
+	case http.MethodPut:
+		content, err := getJSONObject(r)
+		if err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apiErr := user.SetAccountData(vars["type"], content)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-user-userid-rooms-roomid-account-data-type
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-user-userid-rooms-roomid-account-data-type
+func roomAccountDataHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if vars["userId"] != user.ID() {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "account data of other users can't be accessed")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(vars["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		content, apiErr := user.RoomAccountData(room, vars["type"])
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, content)
+	case http.MethodPut:
+		content, err := getJSONObject(r)
+		if err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apiErr := user.SetRoomAccountData(room, vars["type"], content)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, struct{}{})
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+	}
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-user-userid-rooms-roomid-tags
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-user-userid-rooms-roomid-tags-tag
+// https://matrix.org/docs/spec/client_server/latest#delete-matrix-client-r0-user-userid-rooms-roomid-tags-tag
+func roomTagsHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if vars["userId"] != user.ID() {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "tags of other users can't be accessed")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(vars["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	var apiErr models.ApiError
+
+	switch r.Method {
+	case http.MethodGet:
+		var roomTags map[string]tags.Tag
+		if roomTags, apiErr = user.Tags(room); apiErr == nil {
+			sendJsonResponse(w, http.StatusOK, tags.Response{Tags: roomTags})
+			return
+		}
+	case http.MethodPut:
+		var tag tags.Tag
+		if err := getRequest(r, &tag); err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apiErr = user.SetTag(room, vars["tag"], tag)
+	case http.MethodDelete:
+		apiErr = user.DeleteTag(room, vars["tag"])
+	default:
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, struct{}{})
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-upgrade
+func roomUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	var request roomupgrade.Request
+	if err := getRequest(r, &request); err != nil {
+		errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if request.NewVersion == "" {
+		errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "new_version is required")
+		return
+	}
+
+	newRoom, apiErr := user.UpgradeRoom(room, request.NewVersion)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, roomupgrade.Response{ReplacementRoom: newRoom.ID()})
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-aliases
+func roomAliasesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	aliases, apiErr := user.RoomAliases(room)
+	if apiErr != nil {
+		errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+		return
+	}
+
+	sendJsonResponse(w, http.StatusOK, roomalias.ResponseAliases{Aliases: aliases})
+}
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-canonical-alias
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-rooms-roomid-state-eventtype-statekey
+func canonicalAliasHandler(w http.ResponseWriter, r *http.Request) {
+	token := getTokenFromResponse(r)
+	if token == "" {
+		errorResponse(w, models.M_MISSING_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	user := currServer.Backend.GetUserByToken(token)
+	if user == nil {
+		errorResponse(w, models.M_UNKNOWN_TOKEN, http.StatusForbidden, "")
+		return
+	}
+
+	room := currServer.Backend.GetRoomByID(mux.Vars(r)["roomId"])
+	if room == nil {
+		errorResponse(w, models.M_NOT_FOUND, http.StatusNotFound, "room not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		content, apiErr := user.CanonicalAlias(room)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, content)
+	case http.MethodPut:
+		var content events.CanonicalAliasContent
+		if err := getRequest(r, &content); err != nil {
+			errorResponse(w, models.M_BAD_JSON, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		eventID, apiErr := user.SetCanonicalAlias(room, content)
+		if apiErr != nil {
+			errorResponse(w, apiErr, apiErrorHTTPCode(apiErr), "")
+			return
+		}
+
+		sendJsonResponse(w, http.StatusOK, sendmessage.SendMessageReply{EventID: eventID})
+	}
+}
@@ -0,0 +1,32 @@
+package admin
+
+// NonceResponse is reply with nonce for shared-secret registration
+type NonceResponse struct {
+	Nonce string `json:"nonce"` // Single-use value which must be signed with shared secret.
+}
+
+// RegisterRequest is shared-secret registration request. MAC is hex encoded HMAC-SHA1 of
+// nonce, username, password and "admin" or "notadmin" separated by zero bytes, keyed by shared secret.
+type RegisterRequest struct {
+	Nonce    string `json:"nonce"`               // Required. Nonce returned by GET request.
+	Username string `json:"username"`            // Required. Localpart of user ID of new user.
+	Password string `json:"password"`            // Required. Password of new user.
+	Admin    bool   `json:"admin,omitempty"`     // Whether new user is server administrator.
+	MAC      string `json:"mac"`                 // Required. Signature of request.
+	DeviceID string `json:"device_id,omitempty"` // ID of device of returned access token.
+}
+
+// RegisterResponse is reply of shared-secret registration
+type RegisterResponse struct {
+	UserID      string `json:"user_id"`      // User ID of registered user.
+	AccessToken string `json:"access_token"` // Access token of registered user.
+	HomeServer  string `json:"home_server"`  // Server name.
+	DeviceID    string `json:"device_id"`    // ID of device of access token.
+}
+
+// AddThreePIDRequest is request for association of third party identifier with user.
+// Identifier is considered validated by administrator.
+type AddThreePIDRequest struct {
+	Medium  string `json:"medium"`  // Required. One of: ["email", "msisdn"]
+	Address string `json:"address"` // Required. Email address or phone number in international format.
+}
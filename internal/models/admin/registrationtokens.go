@@ -0,0 +1,32 @@
+package admin
+
+import "encoding/json"
+
+// RegistrationToken is token which allows to register when registration policy is "token"
+type RegistrationToken struct {
+	Token       string `json:"token"`        // Token which is sent by client during registration.
+	UsesAllowed *int   `json:"uses_allowed"` // Number of registrations allowed with token, null for unlimited.
+	Pending     int    `json:"pending"`      // Number of registrations which are in progress.
+	Completed   int    `json:"completed"`    // Number of completed registrations.
+	ExpiryTime  *int64 `json:"expiry_time"`  // Time in milliseconds since Unix epoch after which token can't be used, null for no expiry.
+}
+
+// RegistrationTokensResponse is list of registration tokens
+type RegistrationTokensResponse struct {
+	RegistrationTokens []RegistrationToken `json:"registration_tokens"`
+}
+
+// NewRegistrationTokenRequest is request for creation of registration token
+type NewRegistrationTokenRequest struct {
+	Token       string `json:"token,omitempty"`        // Token value, random token is generated if empty.
+	Length      int    `json:"length,omitempty"`       // Length of generated token, 16 by default.
+	UsesAllowed *int   `json:"uses_allowed,omitempty"` // Number of registrations allowed with token, null for unlimited.
+	ExpiryTime  *int64 `json:"expiry_time,omitempty"`  // Time in milliseconds since Unix epoch after which token can't be used, null for no expiry.
+}
+
+// UpdateRegistrationTokenRequest is request for update of registration token. Only present fields are changed,
+// null value removes limit.
+type UpdateRegistrationTokenRequest struct {
+	UsesAllowed json.RawMessage `json:"uses_allowed"` // Number of registrations allowed with token, null for unlimited.
+	ExpiryTime  json.RawMessage `json:"expiry_time"`  // Time in milliseconds since Unix epoch after which token can't be used, null for no expiry.
+}
@@ -28,4 +28,12 @@ const (
 	// Dummy Auth
 	// https://matrix.org/docs/spec/client_server/r0.4.0.html#id204
 	AuthenticationTypeDummy AuthenticationType = "m.login.dummy"
+
+	// Token-authenticated registration
+	// https://spec.matrix.org/v1.2/client-server-api/#token-authenticated-registration
+	AuthenticationTypeRegistrationToken AuthenticationType = "m.login.registration_token"
+
+	// Single sign-on with external provider
+	// https://matrix.org/docs/spec/client_server/latest#sso-client-login
+	AuthenticationTypeSSO AuthenticationType = "m.login.sso"
 )
@@ -65,11 +65,11 @@ type ThumbnailInfo struct {
 // https://matrix.org/docs/spec/client_server/latest#m-file
 type MessageFileContent struct {
 	Body     string        `json:"body"`               // Required. A human-readable description of the file. This is recommended to be the filename of the original upload.
-	filename string        `json:"filename,omitempty"` // The original filename of the uploaded file.
-	info     FileInfo      `json:"info,omitempty"`     // Information about the file referred to in url.
-	msgtype  string        `json:"msgtype"`            // Required. Must be 'm.file'.
+	Filename string        `json:"filename,omitempty"` // The original filename of the uploaded file.
+	Info     FileInfo      `json:"info,omitempty"`     // Information about the file referred to in url.
+	Msgtype  string        `json:"msgtype"`            // Required. Must be 'm.file'.
 	URL      string        `json:"url"`                // 	Required. Required if the file is unencrypted. The URL (typically MXC URI) to the file.
-	file     EncryptedFile `json:"file"`               // 	Required if the file is encrypted. Information on the encrypted file, as specified in End-to-end encryption.
+	File     EncryptedFile `json:"file"`               // 	Required if the file is encrypted. Information on the encrypted file, as specified in End-to-end encryption.
 }
 
 type FileInfo struct {
@@ -1,6 +1,10 @@
 package createroom
 
-import "github.com/signaller-matrix/signaller/internal/models/events"
+import (
+	"encoding/json"
+
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
 
 // https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-createroom
 type VisibilityType string
@@ -29,17 +33,16 @@ type Invite3pid struct {
 // Request is room creation request
 // https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-createroom
 type Request struct {
-	Visibility    VisibilityType `json:"visibility,omitempty"`      // A public visibility indicates that the room will be shown in the published room list. A private visibility will hide the room from the published room list. Rooms default to private visibility if this key is not included. NB: This should not be confused with join_rules which also uses the word public. One of: ["public", "private"]
-	RoomAliasName string         `json:"room_alias_name,omitempty"` // The desired room alias local part. If this is included, a room alias will be created and mapped to the newly created room. The alias will belong on the same homeserver which created the room. For example, if this was set to "foo" and sent to the homeserver "example.com" the complete room alias would be #foo:example.com.
-	Name          string         `json:"name,omitempty"`            // If this is included, an m.room.name event will be sent into the room to indicate the name of the room. See Room Events for more information on m.room.name.
-	Topic         string         `json:"topic,omitempty"`           // If this is included, an m.room.topic event will be sent into the room to indicate the topic for the room. See Room Events for more information on m.room.topic.
-	Invite        []string       `json:"invite,omitempty"`          // A list of user IDs to invite to the room. This will tell the server to invite everyone in the list to the newly created room.
-	Invite3pids   []Invite3pid   `json:"invite_3pid,omitempty"`     // A list of objects representing third party IDs to invite into the room.
-	RoomVersion   string         `json:"room_version,omitempty"`    // The room version to set for the room. If not provided, the homeserver is to use its configured default. If provided, the homeserver will return a 400 error with the errcode M_UNSUPPORTED_ROOM_VERSION if it does not support the room version.
-	// TODO: проверить тип
-	// CreationContent CreationContentType `json:"creation_content,omitempty"`
-	InitialState []events.StateEvent `json:"initial_state,omitempty"` // A list of state events to set in the new room. This allows the user to override the default state events set in the new room. The expected format of the state events are an object with type, state_key and content keys set. Takes precedence over events set by preset, but gets overriden by name and topic keys.
-	Preset       Preset              `json:"preset,omitempty"`        // Convenience parameter for setting various default state events based on a preset. If unspecified, the server should use the visibility to determine which preset to use. A visbility of public equates to a preset of public_chat and private visibility equates to a preset of private_chat. One of: ["private_chat", "public_chat", "trusted_private_chat"]
-	IsDirect     bool                `json:"is_direct,omitempty"`     // This flag makes the server set the is_direct flag on the m.room.member events sent to the users in invite and invite_3pid.
+	Visibility      VisibilityType      `json:"visibility,omitempty"`       // A public visibility indicates that the room will be shown in the published room list. A private visibility will hide the room from the published room list. Rooms default to private visibility if this key is not included. NB: This should not be confused with join_rules which also uses the word public. One of: ["public", "private"]
+	RoomAliasName   string              `json:"room_alias_name,omitempty"`  // The desired room alias local part. If this is included, a room alias will be created and mapped to the newly created room. The alias will belong on the same homeserver which created the room. For example, if this was set to "foo" and sent to the homeserver "example.com" the complete room alias would be #foo:example.com.
+	Name            string              `json:"name,omitempty"`             // If this is included, an m.room.name event will be sent into the room to indicate the name of the room. See Room Events for more information on m.room.name.
+	Topic           string              `json:"topic,omitempty"`            // If this is included, an m.room.topic event will be sent into the room to indicate the topic for the room. See Room Events for more information on m.room.topic.
+	Invite          []string            `json:"invite,omitempty"`           // A list of user IDs to invite to the room. This will tell the server to invite everyone in the list to the newly created room.
+	Invite3pids     []Invite3pid        `json:"invite_3pid,omitempty"`      // A list of objects representing third party IDs to invite into the room.
+	RoomVersion     string              `json:"room_version,omitempty"`     // The room version to set for the room. If not provided, the homeserver is to use its configured default. If provided, the homeserver will return a 400 error with the errcode M_UNSUPPORTED_ROOM_VERSION if it does not support the room version.
+	CreationContent json.RawMessage     `json:"creation_content,omitempty"` // Extra keys, such as m.federate, to be added to the content of the m.room.create event. The server will clobber the following keys: creator, room_version.
+	InitialState    []events.StateEvent `json:"initial_state,omitempty"`    // A list of state events to set in the new room. This allows the user to override the default state events set in the new room. The expected format of the state events are an object with type, state_key and content keys set. Takes precedence over events set by preset, but gets overriden by name and topic keys.
+	Preset          Preset              `json:"preset,omitempty"`           // Convenience parameter for setting various default state events based on a preset. If unspecified, the server should use the visibility to determine which preset to use. A visbility of public equates to a preset of public_chat and private visibility equates to a preset of private_chat. One of: ["private_chat", "public_chat", "trusted_private_chat"]
+	IsDirect        bool                `json:"is_direct,omitempty"`        // This flag makes the server set the is_direct flag on the m.room.member events sent to the users in invite and invite_3pid.
 	// PowerLevelContentOverride `json:"power_level_content_override"`
 }
@@ -12,8 +12,8 @@ type ApiError interface {
 }
 
 type apiError struct {
-	code    string `json:"errcode"`
-	message string `json:"error"`
+	code    string
+	message string
 }
 
 func (apiError *apiError) Error() string {
@@ -39,6 +39,13 @@ func (apiError *apiError) JSON() []byte {
 	return b
 }
 
+func (apiError *apiError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code    string `json:"errcode"`
+		Message string `json:"error,omitempty"`
+	}{apiError.code, apiError.message})
+}
+
 var (
 	// https://matrix.org/docs/spec/client_server/latest#api-standards
 
@@ -57,6 +64,7 @@ var (
 	M_INVALID_USERNAME                = &apiError{"M_INVALID_USERNAME", ""}                // Encountered when trying to register a user ID which is not valid.
 	M_ROOM_IN_USE                     = &apiError{"M_ROOM_IN_USE", ""}                     // Sent when the room alias given to the createRoom API is already in use.
 	M_INVALID_ROOM_STATE              = &apiError{"M_INVALID_ROOM_STATE", ""}              // Sent when the initial state given to the createRoom API is invalid.
+	M_BAD_ALIAS                       = &apiError{"M_BAD_ALIAS", ""}                       // One or more room aliases within the m.room.canonical_alias event do not point to the room ID for which the state event is to be sent to.
 	M_THREEPID_IN_USE                 = &apiError{"M_THREEPID_IN_USE", ""}                 // Sent when a threepid given to an API cannot be used because the same threepid is already in use.
 	M_THREEPID_NOT_FOUND              = &apiError{"M_THREEPID_NOT_FOUND", ""}              // Sent when a threepid given to an API cannot be used because no record matching the threepid was found.
 	M_THREEPID_AUTH_FAILED            = &apiError{"M_THREEPID_AUTH_FAILED", ""}            // Authentication could not be performed on the third party identifier.
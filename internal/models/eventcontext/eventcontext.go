@@ -0,0 +1,16 @@
+package eventcontext
+
+import "github.com/signaller-matrix/signaller/internal/models/events"
+
+// DefaultLimit is default count of context events
+const DefaultLimit = 10
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-context-eventid
+type Response struct {
+	Start        string              `json:"start"`         // A token that can be used to paginate backwards with.
+	End          string              `json:"end"`           // A token that can be used to paginate forwards with.
+	EventsBefore []events.RoomEvent  `json:"events_before"` // A list of room events that happened just before the requested event, in reverse-chronological order.
+	Event        events.RoomEvent    `json:"event"`         // Details of the requested event.
+	EventsAfter  []events.RoomEvent  `json:"events_after"`  // A list of room events that happened just after the requested event, in chronological order.
+	State        []events.StateEvent `json:"state"`         // The state of the room at the last event returned.
+}
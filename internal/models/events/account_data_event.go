@@ -0,0 +1,32 @@
+package events
+
+import "encoding/json"
+
+// AccountDataEvent is private data of user which is delivered in account_data of sync
+// https://matrix.org/docs/spec/client_server/latest#client-config
+type AccountDataEvent struct {
+	ContentData json.RawMessage `json:"content"` // Required. The fields in this object will vary depending on the type of event.
+	EType       EventType       `json:"type"`    // Required. The type of event.
+}
+
+func (this *AccountDataEvent) Content() json.RawMessage {
+	return this.ContentData
+}
+
+// ID returns empty string, account data events have no IDs
+func (this *AccountDataEvent) ID() string {
+	return ""
+}
+
+func (this *AccountDataEvent) Type() EventType {
+	return this.EType
+}
+
+// https://matrix.org/docs/spec/client_server/latest#m-ignored-user-list
+type IgnoredUserListContent struct {
+	IgnoredUsers map[string]struct{} `json:"ignored_users"` // Required. The map of users to ignore. This is a mapping of user ID to empty object.
+}
+
+// DirectContent is content of m.direct account data. It is a mapping from user ID to a list of room IDs of direct chats with that user.
+// https://matrix.org/docs/spec/client_server/latest#m-direct
+type DirectContent map[string][]string
@@ -0,0 +1,27 @@
+package events
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-create
+type CreateContent struct {
+	Creator     string        `json:"creator"`                // Required. The user_id of the room creator. This is set by the homeserver.
+	Federate    *bool         `json:"m.federate,omitempty"`   // Whether users on other servers can join this room. Defaults to true if key does not exist.
+	RoomVersion string        `json:"room_version,omitempty"` // The version of the room. Defaults to "1" if the key does not exist.
+	Predecessor *PreviousRoom `json:"predecessor,omitempty"`  // A reference to the room this room replaces, if the previous room was upgraded.
+}
+
+// PreviousRoom is reference to upgraded room
+type PreviousRoom struct {
+	RoomID  string `json:"room_id"`  // Required. The ID of the old room.
+	EventID string `json:"event_id"` // Required. The event ID of the last known event in the old room.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-tombstone
+type TombstoneContent struct {
+	Body            string `json:"body"`             // Required. A server-defined message.
+	ReplacementRoom string `json:"replacement_room"` // Required. The new room the client should be visiting.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-canonical-alias
+type CanonicalAliasContent struct {
+	Alias      string   `json:"alias,omitempty"`       // The canonical alias for the room. If not present, null, or empty the room should be considered to have no canonical alias.
+	AltAliases []string `json:"alt_aliases,omitempty"` // Alternative aliases the room advertises. This list can have aliases despite the alias field being null, empty, or otherwise not present.
+}
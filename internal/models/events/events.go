@@ -54,6 +54,24 @@ const (
 
 	// https://matrix.org/docs/spec/client_server/latest#m-room-pinned-events
 	PinnedEvents EventType = "m.room.pinned_events"
+
+	// https://matrix.org/docs/spec/client_server/latest#m-room-history-visibility
+	HistoryVisibility EventType = "m.room.history_visibility"
+
+	// https://matrix.org/docs/spec/client_server/latest#m-room-guest-access
+	GuestAccess EventType = "m.room.guest_access"
+
+	// https://matrix.org/docs/spec/client_server/latest#m-room-tombstone
+	Tombstone EventType = "m.room.tombstone"
+
+	// https://matrix.org/docs/spec/client_server/latest#m-tag
+	Tag EventType = "m.tag"
+
+	// https://matrix.org/docs/spec/client_server/latest#m-ignored-user-list
+	IgnoredUserList EventType = "m.ignored_user_list"
+
+	// https://matrix.org/docs/spec/client_server/latest#m-direct
+	Direct EventType = "m.direct"
 )
 
 type Event interface {
@@ -62,14 +80,15 @@ type Event interface {
 	ID() string
 }
 
+// https://matrix.org/docs/spec/client_server/latest#m-room-member
 type EventContent struct {
-	AvatarURL string `json:"avatar_url"` // The avatar URL for this user, if any. This is added by the homeserver.
+	AvatarURL string `json:"avatar_url,omitempty"` // The avatar URL for this user, if any. This is added by the homeserver.
 	// TODO: string or null
-	DisplayName      string       `json:"displayname"`        // The display name for this user, if any. This is added by the homeserver.
-	Membership       Membership   `json:"membership"`         // Required. The membership state of the user. One of: ["invite", "join", "knock", "leave", "ban"]
-	IsDirect         bool         `json:"is_direct"`          // Flag indicating if the room containing this event was created with the intention of being a direct chat. See Direct Messaging.
-	ThirdPartyInvite Invite       `json:"third_party_invite"` //
-	Unsigned         UnsignedData `json:"unsigned"`           // Contains optional extra information about the event.
+	DisplayName      string        `json:"displayname,omitempty"`        // The display name for this user, if any. This is added by the homeserver.
+	Membership       Membership    `json:"membership"`                   // Required. The membership state of the user. One of: ["invite", "join", "knock", "leave", "ban"]
+	IsDirect         bool          `json:"is_direct,omitempty"`          // Flag indicating if the room containing this event was created with the intention of being a direct chat. See Direct Messaging.
+	ThirdPartyInvite *Invite       `json:"third_party_invite,omitempty"` //
+	Unsigned         *UnsignedData `json:"unsigned,omitempty"`           // Contains optional extra information about the event.
 }
 
 type StateEvent struct {
@@ -123,5 +142,5 @@ type Presence struct {
 }
 
 type ToDevice struct {
-	Events []Event `json:"events` // List of send-to-device messages
+	Events []Event `json:"events"` // List of send-to-device messages
 }
@@ -0,0 +1,54 @@
+package events
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-power-levels
+type PowerLevelsContent struct {
+	Ban           int                      `json:"ban"`            // The level required to ban a user. Defaults to 50 if unspecified.
+	Events        map[EventType]int        `json:"events"`         // The level required to send specific event types. This is a mapping from event type to power level required.
+	EventsDefault int                      `json:"events_default"` // The default level required to send message events. Can be overridden by the events key. Defaults to 0 if unspecified.
+	Invite        int                      `json:"invite"`         // The level required to invite a user. Defaults to 50 if unspecified.
+	Kick          int                      `json:"kick"`           // The level required to kick a user. Defaults to 50 if unspecified.
+	Redact        int                      `json:"redact"`         // The level required to redact an event. Defaults to 50 if unspecified.
+	StateDefault  int                      `json:"state_default"`  // The default level required to send state events. Can be overridden by the events key. Defaults to 50 if unspecified.
+	Users         map[string]int           `json:"users"`          // The power levels for specific users. This is a mapping from user_id to power level for that user.
+	UsersDefault  int                      `json:"users_default"`  // The default power level for every user in the room, unless their user_id is mentioned in the users key. Defaults to 0 if unspecified.
+	Notifications PowerLevelsNotifications `json:"notifications"`  // The power level requirements for specific notification types. This is a mapping from key to power level for that notifications key.
+}
+
+type PowerLevelsNotifications struct {
+	Room int `json:"room"` // The level required to trigger an @room notification. Defaults to 50 if unspecified.
+}
+
+// NewPowerLevelsContent returns power levels with values which are used by spec if they are unspecified
+func NewPowerLevelsContent() PowerLevelsContent {
+	return PowerLevelsContent{
+		Ban:           50,
+		Events:        make(map[EventType]int),
+		Invite:        50,
+		Kick:          50,
+		Redact:        50,
+		StateDefault:  50,
+		Users:         make(map[string]int),
+		Notifications: PowerLevelsNotifications{Room: 50}}
+}
+
+// UserLevel returns power level of user
+func (content *PowerLevelsContent) UserLevel(userID string) int {
+	if level, exists := content.Users[userID]; exists {
+		return level
+	}
+
+	return content.UsersDefault
+}
+
+// EventLevel returns power level required to send event of specified type
+func (content *PowerLevelsContent) EventLevel(eventType EventType, isState bool) int {
+	if level, exists := content.Events[eventType]; exists {
+		return level
+	}
+
+	if isState {
+		return content.StateDefault
+	}
+
+	return content.EventsDefault
+}
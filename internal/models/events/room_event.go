@@ -6,13 +6,14 @@ import (
 
 type RoomEvent struct {
 	// TODO: object
-	ContentData    json.RawMessage `json:"content"`          // Required. The fields in this object will vary depending on the type of event. When interacting with the REST API, this is the HTTP body.
-	EType          EventType       `json:"type"`             // Required. The type of event. This SHOULD be namespaced similar to Java package naming conventions e.g. 'com.example.subdomain.event.type'
-	EventID        string          `json:"event_id"`         // Required. The globally unique event identifier.
-	Sender         string          `json:"sender"`           // Required. Contains the fully-qualified ID of the user who sent this event.
-	OriginServerTs int64           `json:"origin_server_ts"` // Required. Timestamp in milliseconds on originating homeserver when this event was sent.
-	Unsigned       UnsignedData    `json:"unsigned"`         // Contains optional extra information about the event.
-	RoomID         string          `json:"room_id"`          // Required. The ID of the room associated with this event. Will not be present on events that arrive through /sync, despite being required everywhere else.
+	ContentData    json.RawMessage `json:"content"`             // Required. The fields in this object will vary depending on the type of event. When interacting with the REST API, this is the HTTP body.
+	EType          EventType       `json:"type"`                // Required. The type of event. This SHOULD be namespaced similar to Java package naming conventions e.g. 'com.example.subdomain.event.type'
+	EventID        string          `json:"event_id"`            // Required. The globally unique event identifier.
+	Sender         string          `json:"sender"`              // Required. Contains the fully-qualified ID of the user who sent this event.
+	OriginServerTs int64           `json:"origin_server_ts"`    // Required. Timestamp in milliseconds on originating homeserver when this event was sent.
+	Unsigned       UnsignedData    `json:"unsigned"`            // Contains optional extra information about the event.
+	RoomID         string          `json:"room_id"`             // Required. The ID of the room associated with this event. Will not be present on events that arrive through /sync, despite being required everywhere else.
+	StateKey       *string         `json:"state_key,omitempty"` // Present if, and only if, this event is a state event. The key making this piece of state unique in the room. Note that it is often an empty string.
 }
 
 func (this *RoomEvent) Content() json.RawMessage {
@@ -26,3 +27,8 @@ func (this *RoomEvent) ID() string {
 func (this *RoomEvent) Type() EventType {
 	return this.EType
 }
+
+// IsState reports whether event is state event
+func (this *RoomEvent) IsState() bool {
+	return this.StateKey != nil
+}
@@ -34,29 +34,29 @@ type RoomFilter struct {
 }
 
 type StateFilter struct {
-	Limit                   int      `json:"limit"`                   // The maximum number of events to return.
-	NotSenders              []string `json:"notSenders"`              // A list of sender IDs to exclude. If this list is absent then no senders are excluded. A matching sender will be excluded even if it is listed in the 'senders' filter.
-	NotTypes                []string `json:"notTypes"`                // A list of event types to exclude. If this list is absent then no event types are excluded. A matching type will be excluded even if it is listed in the 'types' filter. A '*' can be used as a wildcard to match any sequence of characters.
-	Senders                 []string `json:"senders"`                 // A list of senders IDs to include. If this list is absent then all senders are included.
-	Types                   []string `json:"types"`                   // A list of event types to include. If this list is absent then all event types are included. A '*' can be used as a wildcard to match any sequence of characters.
-	LazyLoadMembers         bool     `json:"lazyLoadMembers"`         // If true, enables lazy-loading of membership events. See Lazy-loading room members for more information. Defaults to false.
-	IncludeRedundantMembers bool     `json:"includeRedundantMembers"` // If true, sends all membership events for all events, even if they have already been sent to the client. Does not apply unless lazyLoadMembers is true. See Lazy- loading room members for more information. Defaults to false.
-	NotRooms                []string `json:"notRooms"`                // A list of room IDs to exclude. If this list is absent then no rooms are excluded. A matching room will be excluded even if it is listed in the 'rooms' filter.
-	Rooms                   []string `json:"rooms"`                   // A list of room IDs to include. If this list is absent then all rooms are included.
-	Contains_url            bool     `json:"contains_url"`            // If true, includes only events with a url key in their content. If false, excludes those events. If omitted, url key is not considered for filtering.
+	Limit                   int      `json:"limit"`                     // The maximum number of events to return.
+	NotSenders              []string `json:"not_senders"`               // A list of sender IDs to exclude. If this list is absent then no senders are excluded. A matching sender will be excluded even if it is listed in the 'senders' filter.
+	NotTypes                []string `json:"not_types"`                 // A list of event types to exclude. If this list is absent then no event types are excluded. A matching type will be excluded even if it is listed in the 'types' filter. A '*' can be used as a wildcard to match any sequence of characters.
+	Senders                 []string `json:"senders"`                   // A list of senders IDs to include. If this list is absent then all senders are included.
+	Types                   []string `json:"types"`                     // A list of event types to include. If this list is absent then all event types are included. A '*' can be used as a wildcard to match any sequence of characters.
+	LazyLoadMembers         bool     `json:"lazy_load_members"`         // If true, enables lazy-loading of membership events. See Lazy-loading room members for more information. Defaults to false.
+	IncludeRedundantMembers bool     `json:"include_redundant_members"` // If true, sends all membership events for all events, even if they have already been sent to the client. Does not apply unless lazyLoadMembers is true. See Lazy- loading room members for more information. Defaults to false.
+	NotRooms                []string `json:"not_rooms"`                 // A list of room IDs to exclude. If this list is absent then no rooms are excluded. A matching room will be excluded even if it is listed in the 'rooms' filter.
+	Rooms                   []string `json:"rooms"`                     // A list of room IDs to include. If this list is absent then all rooms are included.
+	Contains_url            bool     `json:"contains_url"`              // If true, includes only events with a url key in their content. If false, excludes those events. If omitted, url key is not considered for filtering.
 }
 
 type RoomEventFilter struct {
-	Limit                   int      `json:"limit"`                   // The maximum number of events to return.
-	NotSenders              []string `json:"notSenders"`              // A list of sender IDs to exclude. If this list is absent then no senders are excluded. A matching sender will be excluded even if it is listed in the 'senders' filter.
-	NotTypes                []string `json:"notTypes"`                // A list of event types to exclude. If this list is absent then no event types are excluded. A matching type will be excluded even if it is listed in the 'types' filter. A '*' can be used as a wildcard to match any sequence of characters.
-	Senders                 []string `json:"senders"`                 // A list of senders IDs to include. If this list is absent then all senders are included.
-	Types                   []string `json:"types"`                   // A list of event types to include. If this list is absent then all event types are included. A '*' can be used as a wildcard to match any sequence of characters.
-	LazyLoadMembers         bool     `json:"lazyLoadMembers"`         // If true, enables lazy-loading of membership events. See Lazy-loading room members for more information. Defaults to false.
-	IncludeRedundantMembers bool     `json:"includeRedundantMembers"` // If true, sends all membership events for all events, even if they have already been sent to the client. Does not apply unless lazyLoadMembers is true. See Lazy- loading room members for more information. Defaults to false.
-	NotRooms                []string `json:"notRooms"`                // A list of room IDs to exclude. If this list is absent then no rooms are excluded. A matching room will be excluded even if it is listed in the 'rooms' filter.
-	Rooms                   []string `json:"rooms"`                   // A list of room IDs to include. If this list is absent then all rooms are included.
-	Contains_url            bool     `json:"contains_url"`            // If true, includes only events with a url key in their content. If false, excludes those events. If omitted, url key is not considered for filtering.
+	Limit                   int      `json:"limit"`                     // The maximum number of events to return.
+	NotSenders              []string `json:"not_senders"`               // A list of sender IDs to exclude. If this list is absent then no senders are excluded. A matching sender will be excluded even if it is listed in the 'senders' filter.
+	NotTypes                []string `json:"not_types"`                 // A list of event types to exclude. If this list is absent then no event types are excluded. A matching type will be excluded even if it is listed in the 'types' filter. A '*' can be used as a wildcard to match any sequence of characters.
+	Senders                 []string `json:"senders"`                   // A list of senders IDs to include. If this list is absent then all senders are included.
+	Types                   []string `json:"types"`                     // A list of event types to include. If this list is absent then all event types are included. A '*' can be used as a wildcard to match any sequence of characters.
+	LazyLoadMembers         bool     `json:"lazy_load_members"`         // If true, enables lazy-loading of membership events. See Lazy-loading room members for more information. Defaults to false.
+	IncludeRedundantMembers bool     `json:"include_redundant_members"` // If true, sends all membership events for all events, even if they have already been sent to the client. Does not apply unless lazyLoadMembers is true. See Lazy- loading room members for more information. Defaults to false.
+	NotRooms                []string `json:"not_rooms"`                 // A list of room IDs to exclude. If this list is absent then no rooms are excluded. A matching room will be excluded even if it is listed in the 'rooms' filter.
+	Rooms                   []string `json:"rooms"`                     // A list of room IDs to include. If this list is absent then all rooms are included.
+	Contains_url            bool     `json:"contains_url"`              // If true, includes only events with a url key in their content. If false, excludes those events. If omitted, url key is not considered for filtering.
 }
 
 type Response struct {
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// Match reports whether room event passes filter. Limit is not taken into account.
+func (filter *RoomEventFilter) Match(event *events.RoomEvent) bool {
+	if inList(filter.NotRooms, event.RoomID) ||
+		(filter.Rooms != nil && !inList(filter.Rooms, event.RoomID)) {
+		return false
+	}
+
+	if inList(filter.NotSenders, event.Sender) ||
+		(filter.Senders != nil && !inList(filter.Senders, event.Sender)) {
+		return false
+	}
+
+	if matchTypes(filter.NotTypes, string(event.EType)) ||
+		(filter.Types != nil && !matchTypes(filter.Types, string(event.EType))) {
+		return false
+	}
+
+	// TODO: check contains_url when it will be possible to distinguish omitted value
+
+	return true
+}
+
+// MatchType reports whether event type passes types and not_types of filter
+func (filter *EventFilter) MatchType(eventType events.EventType) bool {
+	return !matchTypes(filter.NotTypes, string(eventType)) &&
+		(filter.Types == nil || matchTypes(filter.Types, string(eventType)))
+}
+
+// MatchType reports whether event type passes types and not_types of filter
+func (filter *RoomEventFilter) MatchType(eventType events.EventType) bool {
+	return !matchTypes(filter.NotTypes, string(eventType)) &&
+		(filter.Types == nil || matchTypes(filter.Types, string(eventType)))
+}
+
+func inList(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchTypes checks event type against list of types, which can contain '*' wildcards
+func matchTypes(types []string, eventType string) bool {
+	for _, t := range types {
+		if !strings.Contains(t, "*") {
+			if t == eventType {
+				return true
+			}
+			continue
+		}
+
+		expr := "^" + strings.Replace(regexp.QuoteMeta(t), `\*`, ".*", -1) + "$"
+		if regexp.MustCompile(expr).MatchString(eventType) {
+			return true
+		}
+	}
+
+	return false
+}
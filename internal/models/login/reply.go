@@ -7,17 +7,18 @@ import (
 // PostReply is returned reply from POST login method
 // https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-login
 type PostReply struct {
-	UserID      string               `json:"user_id"`              // The fully-qualified Matrix ID that has been registered.
-	AccessToken string               `json:"access_token"`         // An access token for the account. This access token can then be used to authorize other requests.
-	DeviceID    string               `json:"device_id"`            // ID of the logged-in device. Will be the same as the corresponding parameter in the request, if one was specified.
-	WellKnown   DiscoveryInformation `json:"well_known,omitempty"` // Optional client configuration provided by the server. If present, clients SHOULD use the provided object to reconfigure themselves, optionally validating the URLs within. This object takes the same form as the one returned from .well-known autodiscovery.
+	UserID      string                `json:"user_id"`               // The fully-qualified Matrix ID that has been registered.
+	AccessToken string                `json:"access_token"`          // An access token for the account. This access token can then be used to authorize other requests.
+	HomeServer  string                `json:"home_server,omitempty"` // The server_name of the homeserver on which the account has been registered. Deprecated. Clients should extract the server_name from user_id.
+	DeviceID    string                `json:"device_id"`             // ID of the logged-in device. Will be the same as the corresponding parameter in the request, if one was specified.
+	WellKnown   *DiscoveryInformation `json:"well_known,omitempty"`  // Optional client configuration provided by the server. If present, clients SHOULD use the provided object to reconfigure themselves, optionally validating the URLs within. This object takes the same form as the one returned from .well-known autodiscovery.
 }
 
 // DiscoveryInformation is client configuration provided by the server
 // https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-login
 type DiscoveryInformation struct {
-	HomeServer     HomeserverInformation     `json:"m.homeserver"`                // Required. Used by clients to discover homeserver information.
-	IdentityServer IdentityServerInformation `json:"m.identity_server,omitempty"` // Used by clients to discover ide
+	HomeServer     HomeserverInformation      `json:"m.homeserver"`                // Required. Used by clients to discover homeserver information.
+	IdentityServer *IdentityServerInformation `json:"m.identity_server,omitempty"` // Used by clients to discover identity server information.
 }
 
 // HomeserverInformation is used by clients to discover homeserver information
@@ -43,3 +44,10 @@ type Flow struct {
 type GetReply struct {
 	Flows []Flow `json:"flows"` // The homeserver's supported login types
 }
+
+// GetTokenReply is reply with short-lived token for m.login.token login
+// https://github.com/matrix-org/matrix-spec-proposals/pull/3882
+type GetTokenReply struct {
+	LoginToken  string `json:"login_token"`   // Required. The login token for the m.login.token login flow.
+	ExpiresInMs int64  `json:"expires_in_ms"` // Required. The time remaining in milliseconds until the homeserver will no longer accept the token.
+}
@@ -0,0 +1,7 @@
+package media
+
+// ConfigResponse is configuration of content repository
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-media-r0-config
+type ConfigResponse struct {
+	UploadSize int64 `json:"m.upload.size,omitempty"` // The maximum size an upload can be in bytes. Clients SHOULD use this as a guide when uploading content. If not listed or null, the size limit should be treated as unknown.
+}
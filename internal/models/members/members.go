@@ -0,0 +1,18 @@
+package members
+
+import "github.com/signaller-matrix/signaller/internal/models/events"
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-members
+type Response struct {
+	Chunk []events.StateEvent `json:"chunk"` // Get the list of members for this room.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-joined-members
+type JoinedResponse struct {
+	Joined map[string]RoomMember `json:"joined"` // A map from user ID to a RoomMember object.
+}
+
+type RoomMember struct {
+	DisplayName string `json:"display_name,omitempty"` // The display name of the user this object is representing.
+	AvatarURL   string `json:"avatar_url,omitempty"`   // The mxc avatar url of the user this object is representing.
+}
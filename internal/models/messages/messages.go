@@ -0,0 +1,21 @@
+package messages
+
+import "github.com/signaller-matrix/signaller/internal/models/events"
+
+type Direction string
+
+const (
+	DirectionBackward Direction = "b"
+	DirectionForward  Direction = "f"
+)
+
+// DefaultLimit is default count of returned events
+const DefaultLimit = 10
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-messages
+type Response struct {
+	Start string              `json:"start"`           // The token the pagination starts from. If dir=b this will be the token supplied in from.
+	End   string              `json:"end"`             // The token the pagination ends at. If dir=b this token should be used again to request even earlier events.
+	Chunk []events.RoomEvent  `json:"chunk"`           // A list of room events. The order depends on the dir parameter. For dir=b events will be in reverse-chronological order, for dir=f in chronological order, so that events start at the from point.
+	State []events.StateEvent `json:"state,omitempty"` // A list of state events relevant to showing the chunk. For example, if lazy_load_members is enabled in the filter then this may contain the membership events for the senders of events in the chunk.
+}
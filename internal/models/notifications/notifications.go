@@ -0,0 +1,25 @@
+package notifications
+
+import (
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+)
+
+// OnlyHighlight is value of "only" parameter to return only highlight notifications
+const OnlyHighlight = "highlight"
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-notifications
+type Response struct {
+	NextToken     string         `json:"next_token,omitempty"` // The token to supply in the from param of the next /notifications request in order to request more events. If this is absent, there are no more results.
+	Notifications []Notification `json:"notifications"`        // Required. The list of events that triggered notifications.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-notifications
+type Notification struct {
+	Actions    []pushrules.Action `json:"actions"`               // Required. The action(s) to perform when the conditions for this rule are met.
+	Event      events.RoomEvent   `json:"event"`                 // Required. The Event object for the event that triggered the notification.
+	ProfileTag string             `json:"profile_tag,omitempty"` // The profile tag of the rule that matched this event.
+	Read       bool               `json:"read"`                  // Required. Indicates whether the user has sent a read receipt indicating that they have read this message.
+	RoomID     string             `json:"room_id"`               // Required. The ID of the room in which the event was posted.
+	TS         int64              `json:"ts"`                    // Required. The unix timestamp at which the event notification was sent, in milliseconds.
+}
@@ -0,0 +1,20 @@
+package peek
+
+import (
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// DefaultTimeout is default time to wait for new events
+const DefaultTimeout = 30 * time.Second
+
+// MaxTimeout is maximum time to wait for new events, longer timeout requested by client is reduced to it
+const MaxTimeout = 2 * time.Minute
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-events
+type Response struct {
+	Start string             `json:"start"` // A token which correlates to the first value in chunk. This is usually the same token supplied to from=.
+	End   string             `json:"end"`   // A token which correlates to the last value in chunk. This token should be used in the next request to /events.
+	Chunk []events.RoomEvent `json:"chunk"` // An array of events.
+}
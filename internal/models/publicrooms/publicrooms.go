@@ -1,5 +1,8 @@
 package publicrooms
 
+// DefaultLimit is default number of rooms in batch of public room directory
+const DefaultLimit = 50
+
 // Merged request for Get and Post methods
 type Request struct {
 	Limit                int    `json:"limit"`                   // Limit the number of results returned.
@@ -0,0 +1,54 @@
+package pushers
+
+import (
+	"encoding/json"
+)
+
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+type NotifyRequest struct {
+	Notification Notification `json:"notification"` // Required. Information about the push notification
+}
+
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+type Notification struct {
+	EventID           string          `json:"event_id,omitempty"`            // The Matrix event ID of the event being notified about.
+	RoomID            string          `json:"room_id,omitempty"`             // The ID of the room in which this event occurred.
+	Type              string          `json:"type,omitempty"`                // The type of the event as in the event's type field.
+	Sender            string          `json:"sender,omitempty"`              // The sender of the event as in the corresponding event field.
+	SenderDisplayName string          `json:"sender_display_name,omitempty"` // The current display name of the sender in the room in which the event occurred.
+	RoomName          string          `json:"room_name,omitempty"`           // The name of the room in which the event occurred.
+	RoomAlias         string          `json:"room_alias,omitempty"`          // An alias to display for the room in which the event occurred.
+	UserIsTarget      bool            `json:"user_is_target,omitempty"`      // This is true if the user receiving the notification is the subject of a member event (i.e. the state_key of the member event is equal to the user's Matrix ID).
+	Prio              Priority        `json:"prio,omitempty"`                // The priority of the notification. If omitted, high is assumed.
+	Content           json.RawMessage `json:"content,omitempty"`             // The content field from the event, if present.
+	Counts            Counts          `json:"counts"`                        // This is a dictionary of the current number of unacknowledged communications for the recipient user.
+	Devices           []Device        `json:"devices"`                       // Required. This is an array of devices that the notification should be sent to.
+}
+
+// Priority is priority of notification
+type Priority string
+
+const (
+	PriorityHigh Priority = "high"
+	PriorityLow  Priority = "low"
+)
+
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+type Counts struct {
+	Unread      int `json:"unread,omitempty"`       // The number of unread messages a user has across all of the rooms they are a member of.
+	MissedCalls int `json:"missed_calls,omitempty"` // The number of unacknowledged missed calls a user has across all rooms of which they are a member.
+}
+
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+type Device struct {
+	AppID     string                 `json:"app_id"`               // Required. The app_id given when the pusher was created.
+	PushKey   string                 `json:"pushkey"`              // Required. The pushkey given when the pusher was created.
+	PushKeyTS int64                  `json:"pushkey_ts,omitempty"` // The unix timestamp (in seconds) when the pushkey was last updated.
+	Data      PusherData             `json:"data,omitempty"`       // A dictionary of additional pusher-specific data.
+	Tweaks    map[string]interface{} `json:"tweaks,omitempty"`     // A dictionary of customisations made to the way this notification is to be presented.
+}
+
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+type NotifyResponse struct {
+	Rejected []string `json:"rejected"` // Required. A list of all pushkeys given in the notification request that are not valid.
+}
@@ -0,0 +1,46 @@
+package pushers
+
+// Kind is kind of pusher
+type Kind string
+
+const (
+	KindHTTP  Kind = "http"
+	KindEmail Kind = "email"
+)
+
+// Format is format of notifications sent to push gateway
+type Format string
+
+const (
+	FormatFull        Format = ""
+	FormatEventIDOnly Format = "event_id_only" // Only event_id, room_id, counts and devices are sent to push gateway.
+)
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushers
+type Pusher struct {
+	PushKey           string     `json:"pushkey"`               // Required. This is a unique identifier for this pusher. See /set for more detail. Max length, 512 bytes.
+	Kind              Kind       `json:"kind"`                  // Required. The kind of pusher. "http" is a pusher that sends HTTP pokes.
+	AppID             string     `json:"app_id"`                // Required. This is a reverse-DNS style identifier for the application. Max length, 64 chars.
+	AppDisplayName    string     `json:"app_display_name"`      // Required. A string that will allow the user to identify what application owns this pusher.
+	DeviceDisplayName string     `json:"device_display_name"`   // Required. A string that will allow the user to identify what device owns this pusher.
+	ProfileTag        string     `json:"profile_tag,omitempty"` // This string determines which set of device specific rules this pusher executes.
+	Lang              string     `json:"lang"`                  // Required. The preferred language for receiving notifications (e.g. 'en' or 'en-US')
+	Data              PusherData `json:"data"`                  // Required. A dictionary of information for the pusher implementation itself.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushers
+type PusherData struct {
+	URL    string `json:"url,omitempty"`    // Required if kind is http. The URL to use to send notifications to.
+	Format Format `json:"format,omitempty"` // The format to use when sending notifications to the Push Gateway.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushers
+type GetResponse struct {
+	Pushers []Pusher `json:"pushers"` // An array containing the current pushers for the user
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-pushers-set
+type SetRequest struct {
+	Pusher
+	Append bool `json:"append,omitempty"` // If true, the homeserver should add another pusher with the given pushkey and App ID in addition to any others with different user IDs. Otherwise, the homeserver must remove any other pushers with the same App ID and pushkey for different users.
+}
@@ -0,0 +1,105 @@
+package pushrules
+
+// Default rule IDs
+// https://matrix.org/docs/spec/client_server/latest#predefined-rules
+const (
+	RuleMaster              = ".m.rule.master"
+	RuleSuppressNotices     = ".m.rule.suppress_notices"
+	RuleInviteForMe         = ".m.rule.invite_for_me"
+	RuleMemberEvent         = ".m.rule.member_event"
+	RuleContainsDisplayName = ".m.rule.contains_display_name"
+	RuleTombstone           = ".m.rule.tombstone"
+	RuleRoomNotif           = ".m.rule.roomnotif"
+
+	RuleContainsUserName = ".m.rule.contains_user_name"
+
+	RuleCall                  = ".m.rule.call"
+	RuleEncryptedRoomOneToOne = ".m.rule.encrypted_room_one_to_one"
+	RuleRoomOneToOne          = ".m.rule.room_one_to_one"
+	RuleMessage               = ".m.rule.message"
+	RuleEncrypted             = ".m.rule.encrypted"
+)
+
+var (
+	actionNotify        = Action{Type: ActionNotify}
+	actionDontNotify    = Action{Type: ActionDontNotify}
+	actionSoundDefault  = Action{Type: ActionSetTweak, Tweak: TweakSound, Value: "default"}
+	actionSoundRing     = Action{Type: ActionSetTweak, Tweak: TweakSound, Value: "ring"}
+	actionHighlight     = Action{Type: ActionSetTweak, Tweak: TweakHighlight}
+	actionHighlightNone = Action{Type: ActionSetTweak, Tweak: TweakHighlight, Value: false}
+)
+
+func eventMatch(key, pattern string) PushCondition {
+	return PushCondition{Kind: ConditionEventMatch, Key: key, Pattern: pattern}
+}
+
+func defaultRule(ruleID string, conditions []PushCondition, actions ...Action) PushRule {
+	return PushRule{
+		RuleID:     ruleID,
+		Default:    true,
+		Enabled:    true,
+		Conditions: conditions,
+		Actions:    actions}
+}
+
+// DefaultRuleset builds server-default push rules for specified user
+// https://matrix.org/docs/spec/client_server/latest#predefined-rules
+func DefaultRuleset(userID, localpart string) Ruleset {
+	master := defaultRule(RuleMaster, nil, actionDontNotify)
+	master.Enabled = false
+
+	containsUserName := defaultRule(RuleContainsUserName, nil, actionNotify, actionSoundDefault, actionHighlight)
+	containsUserName.Pattern = localpart
+
+	return Ruleset{
+		Override: []PushRule{
+			master,
+			defaultRule(RuleSuppressNotices,
+				[]PushCondition{eventMatch("content.msgtype", "m.notice")},
+				actionDontNotify),
+			defaultRule(RuleInviteForMe,
+				[]PushCondition{
+					eventMatch("type", "m.room.member"),
+					eventMatch("content.membership", "invite"),
+					eventMatch("state_key", userID)},
+				actionNotify, actionSoundDefault, actionHighlightNone),
+			defaultRule(RuleMemberEvent,
+				[]PushCondition{eventMatch("type", "m.room.member")},
+				actionDontNotify),
+			defaultRule(RuleContainsDisplayName,
+				[]PushCondition{{Kind: ConditionContainsDisplayName}},
+				actionNotify, actionSoundDefault, actionHighlight),
+			defaultRule(RuleTombstone,
+				[]PushCondition{
+					eventMatch("type", "m.room.tombstone"),
+					eventMatch("state_key", "")},
+				actionNotify, actionHighlight),
+			defaultRule(RuleRoomNotif,
+				[]PushCondition{
+					eventMatch("content.body", "@room"),
+					{Kind: ConditionSenderNotificationPermission, Key: "room"}},
+				actionNotify, actionHighlight)},
+		Content: []PushRule{containsUserName},
+		Room:    []PushRule{},
+		Sender:  []PushRule{},
+		Underride: []PushRule{
+			defaultRule(RuleCall,
+				[]PushCondition{eventMatch("type", "m.call.invite")},
+				actionNotify, actionSoundRing, actionHighlightNone),
+			defaultRule(RuleEncryptedRoomOneToOne,
+				[]PushCondition{
+					{Kind: ConditionRoomMemberCount, Is: "2"},
+					eventMatch("type", "m.room.encrypted")},
+				actionNotify, actionSoundDefault, actionHighlightNone),
+			defaultRule(RuleRoomOneToOne,
+				[]PushCondition{
+					{Kind: ConditionRoomMemberCount, Is: "2"},
+					eventMatch("type", "m.room.message")},
+				actionNotify, actionSoundDefault, actionHighlightNone),
+			defaultRule(RuleMessage,
+				[]PushCondition{eventMatch("type", "m.room.message")},
+				actionNotify, actionHighlightNone),
+			defaultRule(RuleEncrypted,
+				[]PushCondition{eventMatch("type", "m.room.encrypted")},
+				actionNotify, actionHighlightNone)}}
+}
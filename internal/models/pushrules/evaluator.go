@@ -0,0 +1,198 @@
+package pushrules
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EvaluationContext provides information about event receiver and room,
+// required to evaluate push conditions
+type EvaluationContext interface {
+	UserDisplayName() string                         // Display name of the user for whom the rules are evaluated.
+	RoomMemberCount() int                            // Current number of joined members of the room.
+	SenderHasNotificationPermission(key string) bool // Whether the sender has enough power level for specified notifications key.
+}
+
+// Result is result of ruleset evaluation
+type Result struct {
+	RuleID    string   // ID of matched rule, empty if nothing matched.
+	Actions   []Action // Actions of matched rule.
+	Notify    bool     // Event should generate notification.
+	Highlight bool     // Notification should be highlighted.
+}
+
+// Evaluate evaluates ruleset against event in priority order and returns actions of first matched rule
+// https://matrix.org/docs/spec/client_server/latest#push-rules
+func (ruleset Ruleset) Evaluate(event interface{}, ctx EvaluationContext) Result {
+	flattenedEvent := flattenEvent(event)
+
+	for _, kind := range Kinds {
+		for _, rule := range ruleset.Rules(kind) {
+			if !rule.Enabled || !rule.matches(kind, flattenedEvent, ctx) {
+				continue
+			}
+
+			return resultFromActions(rule.RuleID, rule.Actions)
+		}
+	}
+
+	return Result{}
+}
+
+func resultFromActions(ruleID string, actions []Action) Result {
+	result := Result{
+		RuleID:  ruleID,
+		Actions: actions}
+
+	for _, action := range actions {
+		switch action.Type {
+		case ActionNotify, ActionCoalesce:
+			result.Notify = true
+		case ActionSetTweak:
+			if action.Tweak == TweakHighlight {
+				highlight, ok := action.Value.(bool)
+				result.Highlight = !ok || highlight // highlight without value means true
+			}
+		}
+	}
+
+	if !result.Notify {
+		result.Highlight = false
+	}
+
+	return result
+}
+
+func (rule PushRule) matches(kind Kind, event map[string]string, ctx EvaluationContext) bool {
+	switch kind {
+	case KindContent:
+		body, ok := event["content.body"]
+		return ok && matchGlob(rule.Pattern, body, true)
+	case KindRoom:
+		return event["room_id"] == rule.RuleID
+	case KindSender:
+		return event["sender"] == rule.RuleID
+	}
+
+	for _, condition := range rule.Conditions {
+		if !condition.matches(event, ctx) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (condition PushCondition) matches(event map[string]string, ctx EvaluationContext) bool {
+	switch condition.Kind {
+	case ConditionEventMatch:
+		value, ok := event[condition.Key]
+		if !ok {
+			return false
+		}
+		return matchGlob(condition.Pattern, value, condition.Key == "content.body")
+	case ConditionContainsDisplayName:
+		displayName := ctx.UserDisplayName()
+		body, ok := event["content.body"]
+		if !ok || displayName == "" {
+			return false
+		}
+		return matchRegexp(regexp.QuoteMeta(displayName), body, true)
+	case ConditionRoomMemberCount:
+		return matchMemberCount(condition.Is, ctx.RoomMemberCount())
+	case ConditionSenderNotificationPermission:
+		return ctx.SenderHasNotificationPermission(condition.Key)
+	}
+
+	// Unknown conditions never match
+	return false
+}
+
+// matchGlob matches value with glob pattern (case-insensitive). If wordBoundary is true
+// pattern may match any word sequence of value, otherwise it must match whole value.
+func matchGlob(pattern, value string, wordBoundary bool) bool {
+	var expr strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*?")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	return matchRegexp(expr.String(), value, wordBoundary)
+}
+
+func matchRegexp(expr, value string, wordBoundary bool) bool {
+	var re string
+	if wordBoundary {
+		re = `(?i)(^|\W)` + expr + `(\W|$)`
+	} else {
+		re = `(?i)^` + expr + `$`
+	}
+
+	compiled, err := regexp.Compile(re)
+	if err != nil {
+		return false
+	}
+
+	return compiled.MatchString(value)
+}
+
+func matchMemberCount(is string, count int) bool {
+	operator := strings.TrimRight(is, "0123456789")
+	number, err := strconv.Atoi(is[len(operator):])
+	if err != nil {
+		return false
+	}
+
+	switch operator {
+	case "", "==":
+		return count == number
+	case "<":
+		return count < number
+	case ">":
+		return count > number
+	case "<=":
+		return count <= number
+	case ">=":
+		return count >= number
+	}
+
+	return false
+}
+
+// flattenEvent returns string fields of event by dot-separated keys
+func flattenEvent(event interface{}) map[string]string {
+	result := make(map[string]string)
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return result
+	}
+
+	var object map[string]interface{}
+	if json.Unmarshal(b, &object) != nil {
+		return result
+	}
+
+	flattenObject("", object, result)
+
+	return result
+}
+
+func flattenObject(prefix string, object map[string]interface{}, result map[string]string) {
+	for key, value := range object {
+		switch v := value.(type) {
+		case string:
+			result[prefix+key] = v
+		case map[string]interface{}:
+			flattenObject(prefix+key+".", v, result)
+		}
+	}
+}
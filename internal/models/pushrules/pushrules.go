@@ -0,0 +1,148 @@
+package pushrules
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Kind is kind of push rule
+// https://matrix.org/docs/spec/client_server/latest#push-rules
+type Kind string
+
+const (
+	KindOverride  Kind = "override"  // The highest priority rules are user-configured overrides.
+	KindContent   Kind = "content"   // These configure behaviour for (unencrypted) messages that match certain patterns.
+	KindRoom      Kind = "room"      // These rules change the behaviour of all messages for a given room.
+	KindSender    Kind = "sender"    // These rules configure notification behaviour for messages from a specific Matrix user ID.
+	KindUnderride Kind = "underride" // These are identical to override rules, but have a lower priority than content, room and sender rules.
+)
+
+// Kinds is list of push rule kinds in evaluation order
+var Kinds = []Kind{KindOverride, KindContent, KindRoom, KindSender, KindUnderride}
+
+// ScopeGlobal is the only supported push rules scope
+const ScopeGlobal = "global"
+
+// ActionType is type of push rule action
+// https://matrix.org/docs/spec/client_server/latest#actions
+type ActionType string
+
+const (
+	ActionNotify     ActionType = "notify"      // This causes each matching event to generate a notification.
+	ActionDontNotify ActionType = "dont_notify" // This prevents each matching event from generating a notification.
+	ActionCoalesce   ActionType = "coalesce"    // This enables notifications for matching events but activates homeserver specific behaviour to intelligently coalesce multiple events into a single notification.
+	ActionSetTweak   ActionType = "set_tweak"   // Sets an entry in the tweaks dictionary key that is sent in the notification request to the Push Gateway.
+)
+
+// Tweak is name of action tweak
+type Tweak string
+
+const (
+	TweakSound     Tweak = "sound"     // A string representing the sound to be played when this notification arrives.
+	TweakHighlight Tweak = "highlight" // A boolean representing whether or not this message should be highlighted in the UI.
+)
+
+// Action is push rule action. Serialized as plain string or as set_tweak object.
+type Action struct {
+	Type  ActionType
+	Tweak Tweak
+	Value interface{}
+}
+
+func (action Action) MarshalJSON() ([]byte, error) {
+	if action.Type != ActionSetTweak {
+		return json.Marshal(string(action.Type))
+	}
+
+	object := map[string]interface{}{"set_tweak": action.Tweak}
+	if action.Value != nil {
+		object["value"] = action.Value
+	}
+
+	return json.Marshal(object)
+}
+
+func (action *Action) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		action.Type = ActionType(s)
+		return nil
+	}
+
+	var object struct {
+		SetTweak Tweak       `json:"set_tweak"`
+		Value    interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return err
+	}
+	if object.SetTweak == "" {
+		return errors.New("unknown action")
+	}
+
+	action.Type = ActionSetTweak
+	action.Tweak = object.SetTweak
+	action.Value = object.Value
+
+	return nil
+}
+
+// ConditionKind is kind of push condition
+// https://matrix.org/docs/spec/client_server/latest#conditions
+type ConditionKind string
+
+const (
+	ConditionEventMatch                   ConditionKind = "event_match"                    // This is a glob pattern match on a field of the event.
+	ConditionContainsDisplayName          ConditionKind = "contains_display_name"          // This matches unencrypted messages where content.body contains the owner's display name in that room.
+	ConditionRoomMemberCount              ConditionKind = "room_member_count"              // This matches the current number of members in the room.
+	ConditionSenderNotificationPermission ConditionKind = "sender_notification_permission" // This takes into account the current power levels in the room, ensuring the sender of the event has high enough power to trigger the notification.
+)
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules
+type PushCondition struct {
+	Kind    ConditionKind `json:"kind"`              // Required. The kind of condition to apply.
+	Key     string        `json:"key,omitempty"`     // Required for event_match conditions. The dot-separated field of the event to match.
+	Pattern string        `json:"pattern,omitempty"` // Required for event_match conditions. The glob-style pattern to match against.
+	Is      string        `json:"is,omitempty"`      // Required for room_member_count conditions. A decimal integer optionally prefixed by one of, ==, <, >, >= or <=.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules
+type PushRule struct {
+	Actions    []Action        `json:"actions"`              // Required. The actions to perform when this rule is matched.
+	Default    bool            `json:"default"`              // Required. Whether this is a default rule, or has been set explicitly.
+	Enabled    bool            `json:"enabled"`              // Required. Whether the push rule is enabled or not.
+	RuleID     string          `json:"rule_id"`              // Required. The ID of this rule.
+	Conditions []PushCondition `json:"conditions,omitempty"` // The conditions that must hold true for an event in order for a rule to be applied to an event.
+	Pattern    string          `json:"pattern,omitempty"`    // The glob-style pattern to match against. Only applicable to content rules.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules
+type Ruleset struct {
+	Content   []PushRule `json:"content"`
+	Override  []PushRule `json:"override"`
+	Room      []PushRule `json:"room"`
+	Sender    []PushRule `json:"sender"`
+	Underride []PushRule `json:"underride"`
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-pushrules
+type Response struct {
+	Global Ruleset `json:"global"` // Required. The global ruleset.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid
+type PutRequest struct {
+	Actions    []Action        `json:"actions"`              // Required. The action(s) to perform when the conditions for this rule are met.
+	Conditions []PushCondition `json:"conditions,omitempty"` // The conditions that must hold true for an event in order for a rule to be applied to an event.
+	Pattern    string          `json:"pattern,omitempty"`    // Only applicable to content rules. The glob-style pattern to match against.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid-enabled
+type EnabledRequest struct {
+	Enabled bool `json:"enabled"` // Required. Whether the push rule is enabled or not.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid-actions
+type ActionsRequest struct {
+	Actions []Action `json:"actions"` // Required. The action(s) to perform for this rule.
+}
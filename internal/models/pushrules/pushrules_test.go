@@ -0,0 +1,154 @@
+package pushrules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testContext struct {
+	displayName      string
+	memberCount      int
+	senderPermission bool
+}
+
+func (ctx testContext) UserDisplayName() string                     { return ctx.displayName }
+func (ctx testContext) RoomMemberCount() int                        { return ctx.memberCount }
+func (ctx testContext) SenderHasNotificationPermission(string) bool { return ctx.senderPermission }
+
+func message(body, msgtype string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "m.room.message",
+		"room_id": "!room:localhost",
+		"sender":  "@sender:localhost",
+		"content": map[string]interface{}{"body": body, "msgtype": msgtype}}
+}
+
+func TestDefaultRulesEvaluation(t *testing.T) {
+	ruleset := DefaultRuleset("@user1:localhost", "user1")
+
+	tests := []struct {
+		name      string
+		event     interface{}
+		ctx       testContext
+		ruleID    string
+		notify    bool
+		highlight bool
+	}{
+		{"plain message", message("hello", "m.text"), testContext{memberCount: 3}, RuleMessage, true, false},
+		{"one to one message", message("hello", "m.text"), testContext{memberCount: 2}, RuleRoomOneToOne, true, false},
+		{"notice", message("hello", "m.notice"), testContext{memberCount: 3}, RuleSuppressNotices, false, false},
+		{"user name", message("hi USER1!", "m.text"), testContext{memberCount: 3}, RuleContainsUserName, true, true},
+		{"user name as part of word", message("hi user123", "m.text"), testContext{memberCount: 3}, RuleMessage, true, false},
+		{"display name", message("ping Alice", "m.text"), testContext{displayName: "alice", memberCount: 3}, RuleContainsDisplayName, true, true},
+		{"room notification", message("@room hi", "m.text"), testContext{memberCount: 3, senderPermission: true}, RuleRoomNotif, true, true},
+		{"room notification without permission", message("@room hi", "m.text"), testContext{memberCount: 3}, RuleMessage, true, false},
+		{"invite", map[string]interface{}{
+			"type":      "m.room.member",
+			"state_key": "@user1:localhost",
+			"content":   map[string]interface{}{"membership": "invite"}}, testContext{}, RuleInviteForMe, true, false},
+		{"other member event", map[string]interface{}{
+			"type":      "m.room.member",
+			"state_key": "@user2:localhost",
+			"content":   map[string]interface{}{"membership": "join"}}, testContext{}, RuleMemberEvent, false, false},
+		{"unknown event", map[string]interface{}{"type": "m.custom"}, testContext{}, "", false, false},
+	}
+
+	for _, test := range tests {
+		result := ruleset.Evaluate(test.event, test.ctx)
+		assert.Equal(t, test.ruleID, result.RuleID, test.name)
+		assert.Equal(t, test.notify, result.Notify, test.name)
+		assert.Equal(t, test.highlight, result.Highlight, test.name)
+	}
+}
+
+func TestMasterRule(t *testing.T) {
+	ruleset := DefaultRuleset("@user1:localhost", "user1")
+
+	err := ruleset.SetEnabled(KindOverride, RuleMaster, true)
+	assert.NoError(t, err)
+
+	result := ruleset.Evaluate(message("hi user1", "m.text"), testContext{})
+	assert.Equal(t, RuleMaster, result.RuleID)
+	assert.False(t, result.Notify)
+}
+
+func TestUserDefinedRules(t *testing.T) {
+	ruleset := DefaultRuleset("@user1:localhost", "user1")
+
+	// Mute room
+	err := ruleset.AddRule(KindRoom, PushRule{RuleID: "!room:localhost", Actions: []Action{{Type: ActionDontNotify}}}, "", "")
+	assert.NoError(t, err)
+
+	result := ruleset.Evaluate(message("hello", "m.text"), testContext{memberCount: 3})
+	assert.Equal(t, "!room:localhost", result.RuleID)
+	assert.False(t, result.Notify)
+
+	// Keyword rule has higher priority than room rule
+	err = ruleset.AddRule(KindContent, PushRule{RuleID: "cake", Pattern: "cake*", Actions: []Action{{Type: ActionNotify}}}, "", "")
+	assert.NoError(t, err)
+
+	result = ruleset.Evaluate(message("cakes are here", "m.text"), testContext{memberCount: 3})
+	assert.Equal(t, "cake", result.RuleID)
+	assert.True(t, result.Notify)
+
+	// Default rules can't be deleted or overwritten
+	assert.Error(t, ruleset.DeleteRule(KindUnderride, RuleMessage))
+	assert.Error(t, ruleset.AddRule(KindUnderride, PushRule{RuleID: RuleMessage}, "", ""))
+
+	assert.NoError(t, ruleset.DeleteRule(KindRoom, "!room:localhost"))
+	_, err = ruleset.Rule(KindRoom, "!room:localhost")
+	assert.Error(t, err)
+}
+
+func TestAddRuleOrdering(t *testing.T) {
+	ruleset := DefaultRuleset("@user1:localhost", "user1")
+
+	assert.NoError(t, ruleset.AddRule(KindOverride, PushRule{RuleID: "a"}, "", ""))
+	assert.NoError(t, ruleset.AddRule(KindOverride, PushRule{RuleID: "b"}, "", "a"))
+	assert.NoError(t, ruleset.AddRule(KindOverride, PushRule{RuleID: "c"}, "a", ""))
+	assert.Error(t, ruleset.AddRule(KindOverride, PushRule{RuleID: "d"}, RuleMaster, ""))
+
+	rules := ruleset.Rules(KindOverride)
+	assert.Equal(t, RuleMaster, rules[0].RuleID)
+	assert.Equal(t, "c", rules[1].RuleID)
+	assert.Equal(t, "a", rules[2].RuleID)
+	assert.Equal(t, "b", rules[3].RuleID)
+}
+
+func TestRoomMemberCount(t *testing.T) {
+	tests := []struct {
+		is       string
+		count    int
+		expected bool
+	}{
+		{"2", 2, true},
+		{"==2", 3, false},
+		{"<2", 1, true},
+		{">2", 2, false},
+		{">=2", 2, true},
+		{"<=2", 3, false},
+		{"wrong", 2, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, matchMemberCount(test.is, test.count), test.is)
+	}
+}
+
+func TestActionJSON(t *testing.T) {
+	const data = `["notify",{"set_tweak":"sound","value":"default"},{"set_tweak":"highlight"}]`
+
+	var actions []Action
+	err := json.Unmarshal([]byte(data), &actions)
+	assert.NoError(t, err)
+	assert.Equal(t, []Action{
+		{Type: ActionNotify},
+		{Type: ActionSetTweak, Tweak: TweakSound, Value: "default"},
+		{Type: ActionSetTweak, Tweak: TweakHighlight}}, actions)
+
+	b, err := json.Marshal(actions)
+	assert.NoError(t, err)
+	assert.JSONEq(t, data, string(b))
+}
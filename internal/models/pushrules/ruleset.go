@@ -0,0 +1,198 @@
+package pushrules
+
+import (
+	"strings"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+// IsValidKind reports whether kind is known push rule kind
+func IsValidKind(kind Kind) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rules returns rules of specified kind in priority order
+func (ruleset *Ruleset) Rules(kind Kind) []PushRule {
+	return *ruleset.rulesPtr(kind)
+}
+
+// Rule returns rule with specified kind and ID
+func (ruleset *Ruleset) Rule(kind Kind, ruleID string) (PushRule, models.ApiError) {
+	if !IsValidKind(kind) {
+		return PushRule{}, models.NewError(models.M_INVALID_PARAM, "unknown push rule kind "+string(kind))
+	}
+
+	for _, rule := range ruleset.Rules(kind) {
+		if rule.RuleID == ruleID {
+			return rule, nil
+		}
+	}
+
+	return PushRule{}, models.NewError(models.M_NOT_FOUND, "push rule not found")
+}
+
+// Copy returns deep copy of ruleset
+func (ruleset *Ruleset) Copy() Ruleset {
+	var result Ruleset
+
+	for _, kind := range Kinds {
+		source := ruleset.Rules(kind)
+		rules := make([]PushRule, len(source))
+		for i, rule := range source {
+			rule.Actions = append([]Action(nil), rule.Actions...)
+			rule.Conditions = append([]PushCondition(nil), rule.Conditions...)
+			rules[i] = rule
+		}
+		*result.rulesPtr(kind) = rules
+	}
+
+	return result
+}
+
+// AddRule adds new user-defined rule or replaces existing one. If before or after is set,
+// rule will be placed relatively to specified user-defined rule, otherwise the rule becomes
+// the highest priority user-defined rule of its kind.
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid
+func (ruleset *Ruleset) AddRule(kind Kind, rule PushRule, before, after string) models.ApiError {
+	if !IsValidKind(kind) {
+		return models.NewError(models.M_INVALID_PARAM, "unknown push rule kind "+string(kind))
+	}
+
+	if rule.RuleID == "" || strings.HasPrefix(rule.RuleID, ".") {
+		return models.NewError(models.M_INVALID_PARAM, "rule_id can't be empty or start with '.'")
+	}
+
+	if kind == KindContent && rule.Pattern == "" {
+		return models.NewError(models.M_MISSING_PARAM, "pattern is required for content rules")
+	}
+
+	rule.Default = false
+	rule.Enabled = true
+
+	rules := ruleset.rulesPtr(kind)
+
+	// Replace rule with same ID
+	for i, existingRule := range *rules {
+		if existingRule.RuleID == rule.RuleID {
+			*rules = append((*rules)[:i], (*rules)[i+1:]...)
+			break
+		}
+	}
+
+	position := 0
+	if kind == KindOverride {
+		// master rule always has the highest priority
+		for position < len(*rules) && (*rules)[position].RuleID == RuleMaster {
+			position++
+		}
+	}
+
+	if before != "" || after != "" {
+		relativeID := before
+		if relativeID == "" {
+			relativeID = after
+		}
+
+		found := false
+		for i, existingRule := range *rules {
+			if existingRule.RuleID != relativeID || existingRule.Default {
+				continue
+			}
+
+			position = i
+			if after != "" {
+				position++
+			}
+			found = true
+			break
+		}
+
+		if !found {
+			return models.NewError(models.M_NOT_FOUND, "rule "+relativeID+" not found")
+		}
+	}
+
+	*rules = append(*rules, PushRule{})
+	copy((*rules)[position+1:], (*rules)[position:])
+	(*rules)[position] = rule
+
+	return nil
+}
+
+// DeleteRule deletes user-defined rule
+// https://matrix.org/docs/spec/client_server/latest#delete-matrix-client-r0-pushrules-scope-kind-ruleid
+func (ruleset *Ruleset) DeleteRule(kind Kind, ruleID string) models.ApiError {
+	rule, err := ruleset.Rule(kind, ruleID)
+	if err != nil {
+		return err
+	}
+
+	if rule.Default {
+		return models.NewError(models.M_FORBIDDEN, "server-default rules can't be deleted")
+	}
+
+	rules := ruleset.rulesPtr(kind)
+	for i, existingRule := range *rules {
+		if existingRule.RuleID == ruleID {
+			*rules = append((*rules)[:i], (*rules)[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// SetEnabled enables or disables rule
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid-enabled
+func (ruleset *Ruleset) SetEnabled(kind Kind, ruleID string, enabled bool) models.ApiError {
+	return ruleset.updateRule(kind, ruleID, func(rule *PushRule) {
+		rule.Enabled = enabled
+	})
+}
+
+// SetActions sets actions of rule
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-pushrules-scope-kind-ruleid-actions
+func (ruleset *Ruleset) SetActions(kind Kind, ruleID string, actions []Action) models.ApiError {
+	return ruleset.updateRule(kind, ruleID, func(rule *PushRule) {
+		rule.Actions = actions
+	})
+}
+
+func (ruleset *Ruleset) updateRule(kind Kind, ruleID string, update func(rule *PushRule)) models.ApiError {
+	if _, err := ruleset.Rule(kind, ruleID); err != nil {
+		return err
+	}
+
+	rules := *ruleset.rulesPtr(kind)
+	for i := range rules {
+		if rules[i].RuleID == ruleID {
+			update(&rules[i])
+		}
+	}
+
+	return nil
+}
+
+func (ruleset *Ruleset) rulesPtr(kind Kind) *[]PushRule {
+	switch kind {
+	case KindOverride:
+		return &ruleset.Override
+	case KindContent:
+		return &ruleset.Content
+	case KindRoom:
+		return &ruleset.Room
+	case KindSender:
+		return &ruleset.Sender
+	case KindUnderride:
+		return &ruleset.Underride
+	}
+
+	empty := []PushRule{}
+	return &empty
+}
@@ -0,0 +1,8 @@
+package receipt
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-receipt-receipttype-eventid
+type ReceiptType string
+
+const (
+	ReceiptTypeRead ReceiptType = "m.read"
+)
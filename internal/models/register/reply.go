@@ -1,8 +1,33 @@
 package register
 
+import "github.com/signaller-matrix/signaller/internal/models/common"
+
 // https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-register
 type RegisterResponse struct {
 	UserID      string `json:"user_id"`                // Required. The fully-qualified Matrix user ID (MXID) that has been registered. Any user ID returned by this API must conform to the grammar given in the Matrix specification.
 	AccessToken string `json:"access_token,omitempty"` // An access token for the account. This access token can then be used to authorize other requests. Required if the inhibit_login option is false.
 	DeviceID    string `json:"device_id,omitempty"`    // ID of the registered device. Will be the same as the corresponding parameter in the request, if one was specified. Required if the inhibit_login option is false.
 }
+
+// AuthFlow is list of authentication stages which should be completed to register
+// https://matrix.org/docs/spec/client_server/latest#user-interactive-authentication-api
+type AuthFlow struct {
+	Stages []common.AuthenticationType `json:"stages"` // Login types of stages in order of completion.
+}
+
+// AuthResponse is returned with 401 status when authentication stages should be completed before registration
+// https://matrix.org/docs/spec/client_server/latest#user-interactive-authentication-api
+type AuthResponse struct {
+	Flows     []AuthFlow                                      `json:"flows"`               // Required. A list of the login flows supported by the server for this API.
+	Params    map[common.AuthenticationType]map[string]string `json:"params"`              // Contains any information that the client will need to know in order to use a given type of authentication.
+	Session   string                                          `json:"session,omitempty"`   // This is a session identifier that the client must pass back to the home server, if one is provided, in subsequent attempts to authenticate in the same API call.
+	Completed []common.AuthenticationType                     `json:"completed,omitempty"` // A list of the stages the client has completed successfully.
+	ErrCode   string                                          `json:"errcode,omitempty"`   // Error code of previous failed authentication attempt.
+	Error     string                                          `json:"error,omitempty"`     // Error message of previous failed authentication attempt.
+}
+
+// TokenValidityResponse is reply of registration token validity check
+// https://spec.matrix.org/v1.2/client-server-api/#token-authenticated-registration
+type TokenValidityResponse struct {
+	Valid bool `json:"valid"` // Required. True if the token is still valid, false otherwise.
+}
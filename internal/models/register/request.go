@@ -1,5 +1,7 @@
 package register
 
+import "github.com/signaller-matrix/signaller/internal/models/common"
+
 // https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-register
 type RegisterRequest struct {
 	Auth                     AuthenticationData `json:"auth"`                        // Additional authentication information for the user-interactive authentication API. Note that this information is not used to define how the registered user should be authenticated, but is instead used to authenticate the register call itself.
@@ -10,11 +12,11 @@ type RegisterRequest struct {
 	DeviceID                 string             `json:"device_id"`                   // ID of the client device. If this does not correspond to a known client device, a new device will be created. The server will auto-generate a device_id if this is not specified.
 	InitialDeviceDisplayName string             `json:"initial_device_display_name"` // A display name to assign to the newly-created device. Ignored if device_id corresponds to a known device.
 	InhibitLogin             bool               `json:"inhibit_login"`               // If true, an access_token and device_id should not be returned from this call, therefore preventing an automatic login. Defaults to false.
-
 }
 
 // https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-register
 type AuthenticationData struct {
-	Type    string `json:"type"`              // Required. The login type that the client is attempting to complete.
-	Session string `json:"session,omitempty"` // The value of the session key given by the homeserver.
+	Type    common.AuthenticationType `json:"type"`              // Required. The login type that the client is attempting to complete.
+	Session string                    `json:"session,omitempty"` // The value of the session key given by the homeserver.
+	Token   string                    `json:"token,omitempty"`   // Registration token, required when type is m.login.registration_token.
 }
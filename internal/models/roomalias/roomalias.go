@@ -10,3 +10,8 @@ type ResponseGet struct {
 	RoomID  string   `json:"room_id"` // The room ID for this room alias.
 	Servers []string `json:"servers"` // A list of servers that are aware of this room alias.
 }
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-aliases
+type ResponseAliases struct {
+	Aliases []string `json:"aliases"` // Required. The server's local aliases on the room. Can be empty.
+}
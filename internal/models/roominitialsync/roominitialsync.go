@@ -0,0 +1,27 @@
+package roominitialsync
+
+import (
+	"github.com/signaller-matrix/signaller/internal/models/createroom"
+	"github.com/signaller-matrix/signaller/internal/models/events"
+)
+
+// DefaultLimit is default count of returned messages
+const DefaultLimit = 10
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-initialsync
+type Response struct {
+	RoomID      string                    `json:"room_id"`              // Required. The ID of this room.
+	Membership  events.Membership         `json:"membership,omitempty"` // The user's membership state in this room. One of: ["invite", "join", "leave", "ban"]
+	Messages    PaginationChunk           `json:"messages"`             // The pagination chunk for this room.
+	State       []events.StateEvent       `json:"state"`                // If the user is a member of the room this will be the current state of the room as a list of events. If the user has left the room this will be the state of the room when they left it.
+	Visibility  createroom.VisibilityType `json:"visibility"`           // Whether this room is visible to the /publicRooms API or not. One of: ["private", "public"]
+	AccountData []events.Event            `json:"account_data"`         // The private data that this user has attached to this room.
+	Presence    []events.Event            `json:"presence"`             // The presence of the members of this room.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-rooms-roomid-initialsync
+type PaginationChunk struct {
+	Start string             `json:"start"` // Required. A token which correlates to the first value in chunk. This is usually the same token supplied to from=.
+	End   string             `json:"end"`   // Required. A token which correlates to the last value in chunk. This token should be used in the next request to /events.
+	Chunk []events.RoomEvent `json:"chunk"` // Required. If the user is a member of the room this will be a list of the most recent messages for this room. If the user has left the room this will be the messages that preceeded them leaving. This array will consist of at most limit elements.
+}
@@ -4,7 +4,25 @@ type JoinRule string
 
 const (
 	Public  JoinRule = "public"
-	Knock            = "knock"
-	Invite           = "invite"
-	Private          = "private"
+	Knock   JoinRule = "knock"
+	Invite  JoinRule = "invite"
+	Private JoinRule = "private"
+)
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-history-visibility
+type HistoryVisibility string
+
+const (
+	HistoryVisibilityInvited       HistoryVisibility = "invited"
+	HistoryVisibilityJoined        HistoryVisibility = "joined"
+	HistoryVisibilityShared        HistoryVisibility = "shared"
+	HistoryVisibilityWorldReadable HistoryVisibility = "world_readable"
+)
+
+// https://matrix.org/docs/spec/client_server/latest#m-room-guest-access
+type GuestAccess string
+
+const (
+	GuestAccessCanJoin   GuestAccess = "can_join"
+	GuestAccessForbidden GuestAccess = "forbidden"
 )
@@ -0,0 +1,11 @@
+package roomupgrade
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-upgrade
+type Request struct {
+	NewVersion string `json:"new_version"` // Required. The new version for the room.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-rooms-roomid-upgrade
+type Response struct {
+	ReplacementRoom string `json:"replacement_room"` // Required. The ID of the new room.
+}
@@ -0,0 +1,83 @@
+package search
+
+import (
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/filter"
+)
+
+type OrderBy string
+
+const (
+	OrderByRank   OrderBy = "rank"
+	OrderByRecent OrderBy = "recent"
+)
+
+const (
+	KeyContentBody  = "content.body"
+	KeyContentName  = "content.name"
+	KeyContentTopic = "content.topic"
+)
+
+// Keys is list of keys which are searched by default
+var Keys = []string{KeyContentBody, KeyContentName, KeyContentTopic}
+
+const (
+	DefaultContextLimit = 5 // default count of events before and after result
+	DefaultLimit        = 10
+)
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-search
+type Request struct {
+	SearchCategories Categories `json:"search_categories"` // Required. Describes which categories to search in and their criteria.
+}
+
+type Categories struct {
+	RoomEvents *RoomEventsCriteria `json:"room_events,omitempty"` // Mapping of category name to search criteria.
+}
+
+type RoomEventsCriteria struct {
+	SearchTerm   string                  `json:"search_term"`             // Required. The string to search events for
+	Keys         []string                `json:"keys,omitempty"`          // The keys to search. Defaults to all. One of: ["content.body", "content.name", "content.topic"]
+	Filter       *filter.RoomEventFilter `json:"filter,omitempty"`        // This takes a filter.
+	OrderBy      OrderBy                 `json:"order_by,omitempty"`      // The order in which to search for results. By default, this is "rank". One of: ["recent", "rank"]
+	EventContext *IncludeEventContext    `json:"event_context,omitempty"` // Configures whether any context for the events returned are included in the response.
+	IncludeState bool                    `json:"include_state,omitempty"` // Requests the server return the current state for each room returned.
+	// TODO: groupings
+	// TODO: return state when include_state is set
+}
+
+type IncludeEventContext struct {
+	BeforeLimit    *int `json:"before_limit,omitempty"`    // How many events before the result are returned. By default, this is 5.
+	AfterLimit     *int `json:"after_limit,omitempty"`     // How many events after the result are returned. By default, this is 5.
+	IncludeProfile bool `json:"include_profile,omitempty"` // Requests that the server returns the historic profile information for the users that sent the events that were returned. By default, this is false.
+}
+
+// https://matrix.org/docs/spec/client_server/latest#post-matrix-client-r0-search
+type Response struct {
+	SearchCategories ResultCategories `json:"search_categories"` // Required. Describes which categories to search in and their criteria.
+}
+
+type ResultCategories struct {
+	RoomEvents *RoomEventsResults `json:"room_events,omitempty"` // Mapping of category name to search criteria.
+}
+
+type RoomEventsResults struct {
+	Count      int      `json:"count"`                // An approximate count of the total number of results found.
+	Highlights []string `json:"highlights"`           // List of words which should be highlighted, useful for stemming which may change the query terms.
+	Results    []Result `json:"results"`              // List of results in the requested order.
+	NextBatch  string   `json:"next_batch,omitempty"` // Token that can be used to get the next batch of results, by passing as the next_batch parameter to the next call. If this field is absent, there are no more results.
+}
+
+type Result struct {
+	Rank    float64          `json:"rank"`              // A number that describes how closely this result matches the search. Higher is closer.
+	Result  events.RoomEvent `json:"result"`            // The event that matched.
+	Context *EventContext    `json:"context,omitempty"` // Context for result, if requested.
+}
+
+type EventContext struct {
+	Start        string             `json:"start,omitempty"` // Pagination token for the start of the chunk
+	End          string             `json:"end,omitempty"`   // Pagination token for the end of the chunk
+	EventsBefore []events.RoomEvent `json:"events_before"`   // Events just before the result.
+	EventsAfter  []events.RoomEvent `json:"events_after"`    // Events just after the result.
+	// TODO: profile_info
+}
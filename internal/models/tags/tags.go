@@ -0,0 +1,23 @@
+package tags
+
+// Tags which are defined by spec
+// https://matrix.org/docs/spec/client_server/latest#room-tagging
+const (
+	Favourite    = "m.favourite"
+	LowPriority  = "m.lowpriority"
+	ServerNotice = "m.server_notice"
+)
+
+// MaxNameLength is max length of tag name in bytes
+const MaxNameLength = 255
+
+// https://matrix.org/docs/spec/client_server/latest#put-matrix-client-r0-user-userid-rooms-roomid-tags-tag
+type Tag struct {
+	Order *float64 `json:"order,omitempty"` // A number in a range [0,1] describing a relative position of the room under the given tag.
+}
+
+// Response is list of room tags. It is content of m.tag event too.
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-user-userid-rooms-roomid-tags
+type Response struct {
+	Tags map[string]Tag `json:"tags"` // The list of tags for the user for the room.
+}
@@ -0,0 +1,22 @@
+package threepids
+
+// Media of third party identifiers
+const (
+	MediumEmail  = "email"
+	MediumMSISDN = "msisdn"
+)
+
+// ThirdPartyIdentifier is third party identifier which is associated with account
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-account-3pid
+type ThirdPartyIdentifier struct {
+	Medium      string `json:"medium"`       // Required. The medium of the third party identifier. One of: ["email", "msisdn"]
+	Address     string `json:"address"`      // Required. The third party identifier address.
+	ValidatedAt int64  `json:"validated_at"` // Required. The timestamp, in milliseconds, when the identifier was validated by the identity server.
+	AddedAt     int64  `json:"added_at"`     // Required. The timestamp, in milliseconds, when the homeserver associated the third party identifier with the user.
+}
+
+// Response is list of third party identifiers of account
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-account-3pid
+type Response struct {
+	ThreePIDs []ThirdPartyIdentifier `json:"threepids"`
+}
@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew is allowed difference between clocks of server and provider
+const clockSkew = time.Minute
+
+// idTokenHeader is JOSE header of ID token
+// https://tools.ietf.org/html/rfc7515#section-4
+type idTokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jwks is JSON Web Key Set of provider
+// https://tools.ietf.org/html/rfc7517#section-5
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk is JSON Web Key, only RSA keys are used
+// https://tools.ietf.org/html/rfc7518#section-6.3
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// verifyIDToken checks signature and claims of ID token and returns its claims
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (provider *Provider) verifyIDToken(idToken, nonce string) (Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: ID token is malformed")
+	}
+
+	var header idTokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: ID token header is malformed: %v", err)
+	}
+
+	// RS256 is the only algorithm which must be supported by providers
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("oidc: unsupported ID token algorithm %q", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: ID token signature is malformed")
+	}
+
+	key, err := provider.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("oidc: invalid ID token signature")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("oidc: ID token claims are malformed: %v", err)
+	}
+
+	if err := provider.checkClaims(claims, nonce, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// checkClaims checks issuer, audience, expiration time and nonce of ID token
+func (provider *Provider) checkClaims(claims Claims, nonce string, now time.Time) error {
+	if issuer, _ := claims["iss"].(string); strings.TrimSuffix(issuer, "/") != provider.issuer {
+		return fmt.Errorf("oidc: ID token is issued by %q", issuer)
+	}
+
+	if !claims.hasAudience(provider.clientID) {
+		return errors.New("oidc: ID token is issued for another client")
+	}
+
+	expires, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("oidc: ID token doesn't contain expiration time")
+	}
+	if now.Add(-clockSkew).After(time.Unix(int64(expires), 0)) {
+		return errors.New("oidc: ID token is expired")
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return errors.New("oidc: ID token nonce doesn't match")
+	}
+
+	if claims.Subject() == "" {
+		return errors.New("oidc: ID token doesn't contain subject")
+	}
+
+	return nil
+}
+
+// hasAudience reports whether aud claim, which is string or array of strings, contains client ID
+func (claims Claims) hasAudience(clientID string) bool {
+	switch audience := claims["aud"].(type) {
+	case string:
+		return audience == clientID
+	case []interface{}:
+		for _, a := range audience {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// key returns public key of provider by key ID. Keys are reloaded when key is unknown, so keys can be rotated.
+func (provider *Provider) key(keyID string) (*rsa.PublicKey, error) {
+	provider.mutex.Lock()
+	key, exists := provider.keys[keyID]
+	provider.mutex.Unlock()
+
+	if exists {
+		return key, nil
+	}
+
+	meta, err := provider.loadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid JWKS URI: %v", err)
+	}
+
+	var keySet jwks
+	if err := provider.do(request, &keySet); err != nil {
+		return nil, fmt.Errorf("oidc: loading of keys failed: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range keySet.Keys {
+		if k.KeyType != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		publicKey, err := k.rsaPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[k.KeyID] = publicKey
+	}
+
+	provider.mutex.Lock()
+	provider.keys = keys
+	provider.mutex.Unlock()
+
+	key, exists = keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("oidc: unknown ID token key %q", keyID)
+	}
+
+	return key, nil
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: key %q has malformed modulus", k.KeyID)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("oidc: key %q has malformed exponent", k.KeyID)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// decodeSegment decodes base64url encoded JSON segment of ID token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,47 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckClaims(t *testing.T) {
+	provider := NewProvider("https://idp.example.com/", "signaller", "secret", []string{"openid"}, "https://matrix.example.com/callback")
+	now := time.Unix(1000000, 0)
+
+	valid := func() Claims {
+		return Claims{
+			"iss":   "https://idp.example.com",
+			"aud":   []interface{}{"other", "signaller"},
+			"exp":   float64(now.Add(time.Minute).Unix()),
+			"nonce": "nonce",
+			"sub":   "42"}
+	}
+
+	assert.NoError(t, provider.checkClaims(valid(), "nonce", now))
+
+	tests := []struct {
+		name  string
+		claim string
+		value interface{}
+	}{
+		{"wrong issuer", "iss", "https://evil.example.com"},
+		{"wrong audience", "aud", "other"},
+		{"expired", "exp", float64(now.Add(-2 * time.Minute).Unix())},
+		{"missing expiration", "exp", nil},
+		{"wrong nonce", "nonce", "other"},
+		{"missing subject", "sub", nil}}
+
+	for _, test := range tests {
+		claims := valid()
+		if test.value == nil {
+			delete(claims, test.claim)
+		} else {
+			claims[test.claim] = test.value
+		}
+
+		assert.Error(t, provider.checkClaims(claims, "nonce", now), test.name)
+	}
+}
@@ -0,0 +1,261 @@
+// Package oidc implements relying party of OpenID Connect authorization code flow which is used for SSO login
+// https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryPath is path of provider metadata relative to issuer
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfig
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+const (
+	sessionLifetime = 10 * time.Minute
+	requestTimeout  = 10 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+// Claims are claims of ID token
+type Claims map[string]interface{}
+
+// Subject returns identifier of user in provider
+func (claims Claims) Subject() string {
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+// Provider is OpenID Connect provider which is used by server as relying party. Provider metadata and keys
+// are loaded on first use, so server can be started when provider is unavailable.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	redirectURL  string // callback URL of server which receives authorization code
+	client       *http.Client
+
+	metadata *metadata
+	keys     map[string]*rsa.PublicKey // key ID -> key of provider
+	sessions map[string]session        // state -> login which is in progress
+	mutex    sync.Mutex
+}
+
+// metadata contains endpoints of provider
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// session is login which waits for authorization code
+type session struct {
+	nonce             string
+	browserKeyHash    [sha256.Size]byte // hash of key which is kept by browser that has started login
+	clientRedirectURL string
+	expires           time.Time
+}
+
+// tokenResponse is successful response of token endpoint
+// https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// NewProvider returns provider of issuer. Authorization codes are sent to redirectURL.
+func NewProvider(issuer, clientID, clientSecret string, scopes []string, redirectURL string) *Provider {
+	return &Provider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		redirectURL:  redirectURL,
+		client:       &http.Client{Timeout: requestTimeout},
+		keys:         make(map[string]*rsa.PublicKey),
+		sessions:     make(map[string]session)}
+}
+
+// AuthURL starts login and returns URL of provider where user must be redirected for authentication
+// and browser key which must be kept by redirected browser, e.g. in cookie. Login is finished by Exchange
+// with the same browser key, so state leaked to another browser can't be used there.
+// Client redirect URL is returned by Exchange when login is finished.
+func (provider *Provider) AuthURL(clientRedirectURL string) (authURL, browserKey string, err error) {
+	meta, err := provider.loadMetadata()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, nonce := randomString(), randomString()
+	browserKey = randomString()
+
+	provider.mutex.Lock()
+	now := time.Now()
+	for key, s := range provider.sessions {
+		if now.After(s.expires) {
+			delete(provider.sessions, key)
+		}
+	}
+	provider.sessions[state] = session{
+		nonce:             nonce,
+		browserKeyHash:    sha256.Sum256([]byte(browserKey)),
+		clientRedirectURL: clientRedirectURL,
+		expires:           now.Add(sessionLifetime)}
+	provider.mutex.Unlock()
+
+	endpoint, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("oidc: invalid authorization endpoint: %v", err)
+	}
+
+	query := endpoint.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.clientID)
+	query.Set("redirect_uri", provider.redirectURL)
+	query.Set("scope", strings.Join(provider.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	endpoint.RawQuery = query.Encode()
+
+	return endpoint.String(), browserKey, nil
+}
+
+// Exchange finishes login of state in browser which keeps browser key returned by AuthURL: authorization code
+// is exchanged for ID token, which is verified. Claims of ID token and client redirect URL of login are returned.
+// State can't be used again.
+func (provider *Provider) Exchange(state, code, browserKey string) (Claims, string, error) {
+	provider.mutex.Lock()
+	s, exists := provider.sessions[state]
+	delete(provider.sessions, state)
+	provider.mutex.Unlock()
+
+	if !exists || time.Now().After(s.expires) {
+		return nil, "", errors.New("oidc: unknown or expired state")
+	}
+
+	browserKeyHash := sha256.Sum256([]byte(browserKey))
+	if subtle.ConstantTimeCompare(browserKeyHash[:], s.browserKeyHash[:]) != 1 {
+		return nil, "", errors.New("oidc: login has been started in another browser")
+	}
+
+	if code == "" {
+		return nil, "", errors.New("oidc: authorization code is missing")
+	}
+
+	meta, err := provider.loadMetadata()
+	if err != nil {
+		return nil, "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.redirectURL)
+
+	request, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, "", fmt.Errorf("oidc: invalid token endpoint: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(provider.clientID), url.QueryEscape(provider.clientSecret))
+
+	var tokens tokenResponse
+	if err := provider.do(request, &tokens); err != nil {
+		return nil, "", fmt.Errorf("oidc: token request failed: %v", err)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, "", errors.New("oidc: token response doesn't contain ID token")
+	}
+
+	claims, err := provider.verifyIDToken(tokens.IDToken, s.nonce)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return claims, s.clientRedirectURL, nil
+}
+
+// loadMetadata returns provider metadata, it is loaded once
+func (provider *Provider) loadMetadata() (*metadata, error) {
+	provider.mutex.Lock()
+	meta := provider.metadata
+	provider.mutex.Unlock()
+
+	if meta != nil {
+		return meta, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, provider.issuer+DiscoveryPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid issuer: %v", err)
+	}
+
+	meta = &metadata{}
+	if err := provider.do(request, meta); err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %v", err)
+	}
+
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if strings.TrimSuffix(meta.Issuer, "/") != provider.issuer {
+		return nil, fmt.Errorf("oidc: discovery returned issuer %q instead of %q", meta.Issuer, provider.issuer)
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document doesn't contain required endpoints")
+	}
+
+	provider.mutex.Lock()
+	provider.metadata = meta
+	provider.mutex.Unlock()
+
+	return meta, nil
+}
+
+// do sends request and decodes JSON response with status 200
+func (provider *Provider) do(request *http.Request, response interface{}) error {
+	request.Header.Set("Accept", "application/json")
+
+	httpResponse, err := provider.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(httpResponse.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d: %s", request.URL, httpResponse.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, response)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,88 @@
+package oidc_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/oidc"
+	"github.com/signaller-matrix/signaller/internal/oidc/oidctest"
+)
+
+const callbackURL = "https://matrix.example.com/callback"
+
+// login follows authorization URL and returns state and code of callback and browser key
+func login(t *testing.T, fake *oidctest.Provider, provider *oidc.Provider, clientRedirectURL string) (state, code, browserKey string) {
+	authURL, browserKey, err := provider.AuthURL(clientRedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback, err := fake.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, callbackURL, parsed.Scheme+"://"+parsed.Host+parsed.Path)
+
+	return parsed.Query().Get("state"), parsed.Query().Get("code"), browserKey
+}
+
+func TestExchange(t *testing.T) {
+	fake := oidctest.NewProvider("signaller", "secret")
+	defer fake.Close()
+	fake.SetClaims(map[string]interface{}{"sub": "42", "preferred_username": "alice"})
+
+	provider := oidc.NewProvider(fake.URL, "signaller", "secret", []string{"openid", "profile"}, callbackURL)
+
+	state, code, browserKey := login(t, fake, provider, "https://client.example.com/")
+	claims, clientRedirectURL, err := provider.Exchange(state, code, browserKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "42", claims.Subject())
+	assert.Equal(t, "alice", claims["preferred_username"])
+	assert.Equal(t, "https://client.example.com/", clientRedirectURL)
+
+	// state is single-use
+	_, _, err = provider.Exchange(state, code, browserKey)
+	assert.Error(t, err)
+}
+
+func TestExchangeErrors(t *testing.T) {
+	fake := oidctest.NewProvider("signaller", "secret")
+	defer fake.Close()
+
+	provider := oidc.NewProvider(fake.URL, "signaller", "secret", []string{"openid"}, callbackURL)
+
+	_, _, err := provider.Exchange("unknown", "code", "")
+	assert.Error(t, err)
+
+	state, _, browserKey := login(t, fake, provider, "")
+	_, _, err = provider.Exchange(state, "wrong code", browserKey)
+	assert.Error(t, err)
+
+	// login can't be finished in another browser
+	state, code, _ := login(t, fake, provider, "")
+	_, _, err = provider.Exchange(state, code, "another")
+	assert.Error(t, err)
+
+	wrongSecret := oidc.NewProvider(fake.URL, "signaller", "wrong", []string{"openid"}, callbackURL)
+	state, code, browserKey = login(t, fake, wrongSecret, "")
+	_, _, err = wrongSecret.Exchange(state, code, browserKey)
+	assert.Error(t, err)
+}
+
+func TestWrongIssuer(t *testing.T) {
+	fake := oidctest.NewProvider("signaller", "secret")
+	defer fake.Close()
+
+	provider := oidc.NewProvider(fake.URL+"/other", "signaller", "secret", []string{"openid"}, callbackURL)
+	_, _, err := provider.AuthURL("")
+	assert.Error(t, err)
+}
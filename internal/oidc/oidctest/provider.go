@@ -0,0 +1,228 @@
+// Package oidctest implements in-process OpenID Connect provider for tests. Provider authenticates
+// every authorization request immediately as user with configured claims.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal/oidc"
+)
+
+const keyID = "test"
+
+// Provider is fake OpenID Connect provider which is served by httptest server
+type Provider struct {
+	URL          string // Issuer URL
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+	codes  map[string]authRequest // authorization code -> request which it was issued for
+	mutex  sync.Mutex
+}
+
+// authRequest is authorization request which is approved by provider
+type authRequest struct {
+	redirectURI string
+	nonce       string
+	claims      map[string]interface{}
+}
+
+// NewProvider starts provider for client. Provider must be closed after use.
+func NewProvider(clientID, clientSecret string) *Provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	provider := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		claims:       map[string]interface{}{"sub": "user"},
+		codes:        make(map[string]authRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidc.DiscoveryPath, provider.discoveryHandler)
+	mux.HandleFunc("/authorize", provider.authorizeHandler)
+	mux.HandleFunc("/token", provider.tokenHandler)
+	mux.HandleFunc("/jwks", provider.jwksHandler)
+
+	provider.server = httptest.NewServer(mux)
+	provider.URL = provider.server.URL
+
+	return provider
+}
+
+// Close stops provider
+func (provider *Provider) Close() {
+	provider.server.Close()
+}
+
+// SetClaims sets claims of user which is authenticated by next authorization requests. Claims must contain sub.
+func (provider *Provider) SetClaims(claims map[string]interface{}) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.claims = claims
+}
+
+// Authorize follows authorization URL as user agent and returns URL of callback with authorization code
+func (provider *Provider) Authorize(authURL string) (string, error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+
+	response, err := client.Get(authURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	location, err := response.Location()
+	if err != nil {
+		return "", err
+	}
+
+	return location.String(), nil
+}
+
+func (provider *Provider) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                provider.URL,
+		"authorization_endpoint":                provider.URL + "/authorize",
+		"token_endpoint":                        provider.URL + "/token",
+		"jwks_uri":                              provider.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"}})
+}
+
+func (provider *Provider) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != provider.ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	provider.mutex.Lock()
+	provider.codes[code] = authRequest{
+		redirectURI: redirectURI.String(),
+		nonce:       query.Get("nonce"),
+		claims:      provider.claims}
+	provider.mutex.Unlock()
+
+	callbackQuery := redirectURI.Query()
+	callbackQuery.Set("code", code)
+	callbackQuery.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callbackQuery.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (provider *Provider) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if r.Method != http.MethodPost || !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if clientID != provider.ClientID || clientSecret != provider.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	provider.mutex.Lock()
+	request, exists := provider.codes[r.PostFormValue("code")]
+	delete(provider.codes, r.PostFormValue("code"))
+	provider.mutex.Unlock()
+
+	if r.PostFormValue("grant_type") != "authorization_code" || !exists || r.PostFormValue("redirect_uri") != request.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   provider.URL,
+		"aud":   provider.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": request.nonce}
+	for name, value := range request.claims {
+		claims[name] = value
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     provider.sign(claims)})
+}
+
+func (provider *Provider) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(provider.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(provider.key.E)).Bytes())}}})
+}
+
+// sign returns ID token with claims which is signed with RS256
+func (provider *Provider) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, provider.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
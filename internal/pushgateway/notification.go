@@ -0,0 +1,82 @@
+package pushgateway
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/signaller-matrix/signaller/internal/models/events"
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+	"github.com/signaller-matrix/signaller/internal/models/pushrules"
+)
+
+// NewNotification builds push gateway request for specified event and pusher
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+func NewNotification(event *events.RoomEvent, pusher pushers.Pusher, pushKeyTS int64, unread int, actions []pushrules.Action) pushers.NotifyRequest {
+	device := pushers.Device{
+		AppID:     pusher.AppID,
+		PushKey:   pusher.PushKey,
+		PushKeyTS: pushKeyTS,
+		Data:      pushers.PusherData{Format: pusher.Data.Format},
+		Tweaks:    tweaks(actions)}
+
+	notification := pushers.Notification{
+		EventID: event.EventID,
+		RoomID:  event.RoomID,
+		Counts:  pushers.Counts{Unread: unread},
+		Devices: []pushers.Device{device}}
+
+	if pusher.Data.Format == pushers.FormatEventIDOnly {
+		return pushers.NotifyRequest{Notification: notification}
+	}
+
+	notification.Type = string(event.EType)
+	notification.Sender = event.Sender
+	notification.Content = event.ContentData
+	notification.Prio = pushers.PriorityLow
+	if event.EType == events.Message {
+		notification.Prio = pushers.PriorityHigh
+	}
+
+	return pushers.NotifyRequest{Notification: notification}
+}
+
+func tweaks(actions []pushrules.Action) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	for _, action := range actions {
+		if action.Type != pushrules.ActionSetTweak {
+			continue
+		}
+
+		if action.Value == nil && action.Tweak == pushrules.TweakHighlight {
+			result[string(action.Tweak)] = true
+		} else {
+			result[string(action.Tweak)] = action.Value
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+// ValidateURL checks that url is absolute HTTP URL of push gateway notification endpoint
+func ValidateURL(pushGatewayURL string) error {
+	u, err := url.Parse(pushGatewayURL)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("push gateway url must be absolute http(s) url")
+	}
+
+	if u.Path != NotifyPath {
+		return fmt.Errorf("push gateway url path must be %s", NotifyPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package pushgateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+)
+
+// NotifyPath is path of push gateway notification endpoint
+// https://matrix.org/docs/spec/push_gateway/latest#post-matrix-push-v1-notify
+const NotifyPath = "/_matrix/push/v1/notify"
+
+const (
+	defaultQueueSize      = 1024
+	defaultWorkersCount   = 4
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultMaxFailures    = 3
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// Delivery is notification which should be sent to push gateway of pusher
+type Delivery struct {
+	UserID       string
+	Pusher       pushers.Pusher
+	Notification pushers.NotifyRequest
+}
+
+// DisableFunc is called when pusher should be removed
+type DisableFunc func(userID string, pusher pushers.Pusher)
+
+// Worker delivers notifications to HTTP push gateways with retries
+type Worker struct {
+	Client         *http.Client
+	MaxAttempts    int           // Max attempts to deliver one notification.
+	InitialBackoff time.Duration // Delay before second attempt, doubled on every next attempt.
+	MaxBackoff     time.Duration // Max delay between attempts.
+	MaxFailures    int           // Count of consecutive undelivered notifications after which pusher will be disabled.
+
+	onDisable DisableFunc
+	queue     chan attempt
+	retries   map[*time.Timer]struct{} // scheduled retries, they are cancelled on stop
+	stopped   bool
+	failures  map[string]int // pusher key -> count of consecutive failed deliveries
+	wg        sync.WaitGroup
+	mutex     sync.Mutex
+}
+
+// attempt is attempt to deliver notification
+type attempt struct {
+	delivery Delivery
+	number   int           // number of attempt starting from 1
+	backoff  time.Duration // delay before next attempt
+}
+
+// NewWorker returns new worker. onDisable is called for pushers which were rejected
+// by gateway or failed to receive notifications too many times.
+func NewWorker(onDisable DisableFunc) *Worker {
+	return &Worker{
+		Client:         &http.Client{Timeout: defaultRequestTimeout},
+		MaxAttempts:    defaultMaxAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+		MaxFailures:    defaultMaxFailures,
+		onDisable:      onDisable,
+		queue:          make(chan attempt, defaultQueueSize),
+		retries:        make(map[*time.Timer]struct{}),
+		failures:       make(map[string]int)}
+}
+
+// Start starts delivery goroutines
+func (worker *Worker) Start() {
+	for i := 0; i < defaultWorkersCount; i++ {
+		worker.wg.Add(1)
+		go func() {
+			defer worker.wg.Done()
+
+			for a := range worker.queue {
+				worker.deliver(a)
+			}
+		}()
+	}
+}
+
+// Stop stops accepting of new notifications, cancels scheduled retries and waits until queued
+// notifications will be processed. Stop can be called more than once.
+func (worker *Worker) Stop() {
+	worker.mutex.Lock()
+	if worker.stopped {
+		worker.mutex.Unlock()
+		return
+	}
+
+	worker.stopped = true
+	for timer := range worker.retries {
+		timer.Stop()
+	}
+	worker.retries = nil
+	close(worker.queue)
+	worker.mutex.Unlock()
+
+	worker.wg.Wait()
+}
+
+// Enqueue adds notification to delivery queue. Notification is dropped if queue is full or worker is stopped.
+func (worker *Worker) Enqueue(delivery Delivery) bool {
+	return worker.enqueue(attempt{
+		delivery: delivery,
+		number:   1,
+		backoff:  worker.InitialBackoff})
+}
+
+func (worker *Worker) enqueue(a attempt) bool {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	if worker.stopped {
+		return false
+	}
+
+	select {
+	case worker.queue <- a:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver sends notification once. Failed attempt is retried after backoff without blocking of delivery goroutine,
+// so unavailable gateway doesn't delay notifications of other pushers.
+func (worker *Worker) deliver(a attempt) {
+	rejected, err := worker.send(a.delivery)
+	if err == nil {
+		worker.mutex.Lock()
+		delete(worker.failures, pusherKey(a.delivery.UserID, a.delivery.Pusher))
+		worker.mutex.Unlock()
+
+		for _, pushKey := range rejected {
+			if pushKey == a.delivery.Pusher.PushKey {
+				worker.disable(a.delivery)
+			}
+		}
+		return
+	}
+
+	if a.number < worker.MaxAttempts {
+		worker.retry(a)
+		return
+	}
+
+	worker.fail(a.delivery)
+}
+
+// retry schedules next attempt after backoff
+func (worker *Worker) retry(a attempt) {
+	next := attempt{
+		delivery: a.delivery,
+		number:   a.number + 1,
+		backoff:  a.backoff * 2}
+	if next.backoff > worker.MaxBackoff {
+		next.backoff = worker.MaxBackoff
+	}
+
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	if worker.stopped {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(a.backoff, func() {
+		worker.mutex.Lock()
+		_, scheduled := worker.retries[timer]
+		delete(worker.retries, timer)
+		worker.mutex.Unlock()
+
+		if scheduled && !worker.enqueue(next) {
+			worker.fail(next.delivery)
+		}
+	})
+	worker.retries[timer] = struct{}{}
+}
+
+// fail counts undelivered notification and disables pusher which failed too many times
+func (worker *Worker) fail(delivery Delivery) {
+	key := pusherKey(delivery.UserID, delivery.Pusher)
+
+	worker.mutex.Lock()
+	worker.failures[key]++
+	failures := worker.failures[key]
+	worker.mutex.Unlock()
+
+	if failures >= worker.MaxFailures {
+		worker.disable(delivery)
+	}
+}
+
+func (worker *Worker) disable(delivery Delivery) {
+	worker.mutex.Lock()
+	delete(worker.failures, pusherKey(delivery.UserID, delivery.Pusher))
+	worker.mutex.Unlock()
+
+	if worker.onDisable != nil {
+		worker.onDisable(delivery.UserID, delivery.Pusher)
+	}
+}
+
+// send sends notification and returns rejected pushkeys
+func (worker *Worker) send(delivery Delivery) (rejected []string, err error) {
+	b, err := json.Marshal(delivery.Notification)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := worker.Client.Post(delivery.Pusher.Data.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("push gateway returned %s", resp.Status)
+	}
+
+	var response pushers.NotifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Rejected, nil
+}
+
+func pusherKey(userID string, pusher pushers.Pusher) string {
+	return userID + "|" + pusher.AppID + "|" + pusher.PushKey
+}
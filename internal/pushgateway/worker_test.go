@@ -0,0 +1,186 @@
+package pushgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/pushers"
+)
+
+func newTestWorker(onDisable DisableFunc) *Worker {
+	worker := NewWorker(onDisable)
+	worker.MaxAttempts = 3
+	worker.InitialBackoff = time.Millisecond
+	worker.MaxBackoff = 2 * time.Millisecond
+	worker.MaxFailures = 2
+
+	return worker
+}
+
+func testDelivery(url string) Delivery {
+	pusher := pushers.Pusher{
+		PushKey: "pushkey1",
+		Kind:    pushers.KindHTTP,
+		AppID:   "com.example.app",
+		Data:    pushers.PusherData{URL: url + NotifyPath}}
+
+	return Delivery{
+		UserID: "@user1:localhost",
+		Pusher: pusher,
+		Notification: pushers.NotifyRequest{Notification: pushers.Notification{
+			EventID: "event1",
+			Devices: []pushers.Device{{AppID: pusher.AppID, PushKey: pusher.PushKey}}}}}
+}
+
+func TestDelivery(t *testing.T) {
+	received := make(chan pushers.NotifyRequest, 1)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, NotifyPath, r.URL.Path)
+
+		var request pushers.NotifyRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		received <- request
+
+		w.Write([]byte(`{"rejected":[]}`))
+	}))
+	defer gateway.Close()
+
+	worker := newTestWorker(func(string, pushers.Pusher) { t.Error("pusher must not be disabled") })
+	worker.Start()
+
+	assert.True(t, worker.Enqueue(testDelivery(gateway.URL)))
+
+	select {
+	case request := <-received:
+		assert.Equal(t, "event1", request.Notification.EventID)
+	case <-time.After(time.Second):
+		t.Fatal("notification was not delivered")
+	}
+
+	worker.Stop()
+}
+
+func TestDeliveryRetry(t *testing.T) {
+	var attempts int32
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"rejected":[]}`))
+	}))
+	defer gateway.Close()
+
+	var disabled int32
+	worker := newTestWorker(func(string, pushers.Pusher) { atomic.AddInt32(&disabled, 1) })
+	worker.Start()
+	worker.Enqueue(testDelivery(gateway.URL))
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&attempts) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	worker.Stop()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&disabled))
+}
+
+func TestDisableFailingPusher(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer gateway.Close()
+
+	disabled := make(chan string, 1)
+	worker := newTestWorker(func(userID string, pusher pushers.Pusher) { disabled <- pusher.PushKey })
+	worker.Start()
+
+	// First undelivered notification doesn't disable pusher
+	worker.Enqueue(testDelivery(gateway.URL))
+	select {
+	case <-disabled:
+		t.Fatal("pusher must not be disabled after first undelivered notification")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	worker.Enqueue(testDelivery(gateway.URL))
+	select {
+	case pushKey := <-disabled:
+		assert.Equal(t, "pushkey1", pushKey)
+	case <-time.After(time.Second):
+		t.Fatal("pusher was not disabled")
+	}
+
+	worker.Stop()
+}
+
+func TestRetryDoesNotBlockDelivery(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	received := make(chan struct{}, 1)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		w.Write([]byte(`{"rejected":[]}`))
+	}))
+	defer healthy.Close()
+
+	worker := newTestWorker(nil)
+	worker.InitialBackoff = time.Hour
+	worker.MaxBackoff = time.Hour
+	worker.Start()
+
+	for i := 0; i < 2*defaultWorkersCount; i++ {
+		worker.Enqueue(testDelivery(failing.URL))
+	}
+	worker.Enqueue(testDelivery(healthy.URL))
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("notification was delayed by retries of another gateway")
+	}
+
+	// scheduled retries are cancelled
+	stopped := make(chan struct{})
+	go func() {
+		worker.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("worker was not stopped")
+	}
+	assert.False(t, worker.Enqueue(testDelivery(healthy.URL)))
+}
+
+func TestDisableRejectedPusher(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"rejected":["pushkey1"]}`))
+	}))
+	defer gateway.Close()
+
+	disabled := make(chan string, 1)
+	worker := newTestWorker(func(userID string, pusher pushers.Pusher) { disabled <- pusher.PushKey })
+
+	worker.deliver(attempt{delivery: testDelivery(gateway.URL), number: 1})
+	assert.Equal(t, "pushkey1", <-disabled)
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.NoError(t, ValidateURL("https://push.example.com/_matrix/push/v1/notify"))
+	assert.Error(t, ValidateURL("https://push.example.com/notify"))
+	assert.Error(t, ValidateURL("/_matrix/push/v1/notify"))
+	assert.Error(t, ValidateURL("ftp://push.example.com/_matrix/push/v1/notify"))
+}
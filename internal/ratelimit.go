@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+// maxRateLimitBuckets is number of tracked clients after which buckets of idle clients are dropped
+const maxRateLimitBuckets = 10000
+
+// rateLimitError is body of M_LIMIT_EXCEEDED error
+// https://matrix.org/docs/spec/client_server/latest#rate-limiting
+type rateLimitError struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms"` // The amount of time in milliseconds the client should wait before trying the request again.
+}
+
+// rateLimiter limits request rate of clients using token bucket per client
+type rateLimiter struct {
+	buckets map[string]*rateLimitBucket
+	mutex   sync.Mutex
+}
+
+type rateLimitBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*rateLimitBucket)}
+}
+
+// allow takes one token from bucket of client. If bucket is empty, time to wait until next token is returned.
+func (limiter *rateLimiter) allow(client string, perSecond float64, burst int, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	bucket, exists := limiter.buckets[client]
+	if !exists {
+		if len(limiter.buckets) >= maxRateLimitBuckets {
+			limiter.dropIdle(perSecond, burst, now)
+		}
+
+		bucket = &rateLimitBucket{tokens: float64(burst), updated: now}
+		limiter.buckets[client] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+// dropIdle removes buckets which are full again, they are the same as new buckets
+func (limiter *rateLimiter) dropIdle(perSecond float64, burst int, now time.Time) {
+	for client, bucket := range limiter.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond >= float64(burst) {
+			delete(limiter.buckets, client)
+		}
+	}
+}
+
+// rateLimitMiddleware rejects requests of clients which exceed configured request rate.
+// Clients are identified by valid access token or by IP address for requests without it.
+func (server *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := server.Config().RateLimits
+		if !limits.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Unknown tokens are not used as keys, otherwise every request with random token would get new bucket
+		client, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if token := getTokenFromResponse(r); token != "" && server.Backend.GetUserByToken(token) != nil {
+			client = token
+		}
+
+		allowed, retryAfter := server.limiter.allow(client, limits.PerSecond, limits.Burst, time.Now())
+		if !allowed {
+			sendJsonResponse(w, http.StatusTooManyRequests, rateLimitError{
+				ErrCode:      models.M_LIMIT_EXCEEDED.Code(),
+				Error:        "too many requests",
+				RetryAfterMs: int64(math.Ceil(float64(retryAfter) / float64(time.Millisecond)))})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
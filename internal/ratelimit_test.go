@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.allow("client1", 2, 3, now)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter := limiter.allow("client1", 2, 3, now)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// Other clients have their own buckets
+	allowed, _ = limiter.allow("client2", 2, 3, now)
+	assert.True(t, allowed)
+
+	allowed, _ = limiter.allow("client1", 2, 3, now.Add(500*time.Millisecond))
+	assert.True(t, allowed)
+
+	allowed, _ = limiter.allow("client1", 2, 3, now.Add(500*time.Millisecond))
+	assert.False(t, allowed)
+}
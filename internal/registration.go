@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/signaller-matrix/signaller/internal/models/admin"
+)
+
+const (
+	authSessionLifetime   = 10 * time.Minute
+	registerNonceLifetime = time.Minute
+	authSessionSize       = 16
+)
+
+// expiringKeys is set of random single-use keys which are valid for limited time,
+// it is used for user-interactive authentication sessions and nonces
+type expiringKeys struct {
+	keys     map[string]time.Time // key -> expiration time
+	lifetime time.Duration
+	mutex    sync.Mutex
+}
+
+func newExpiringKeys(lifetime time.Duration) *expiringKeys {
+	return &expiringKeys{
+		keys:     make(map[string]time.Time),
+		lifetime: lifetime}
+}
+
+// new returns new key
+func (keys *expiringKeys) new() string {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	now := time.Now()
+	for key, expires := range keys.keys {
+		if now.After(expires) {
+			delete(keys.keys, key)
+		}
+	}
+
+	key := RandomString(authSessionSize)
+	keys.keys[key] = now.Add(keys.lifetime)
+
+	return key
+}
+
+// valid reports whether key was returned by new, isn't expired and isn't taken yet
+func (keys *expiringKeys) valid(key string) bool {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	expires, exists := keys.keys[key]
+
+	return exists && time.Now().Before(expires)
+}
+
+// take removes key and reports whether it was valid
+func (keys *expiringKeys) take(key string) bool {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	expires, exists := keys.keys[key]
+	delete(keys.keys, key)
+
+	return exists && time.Now().Before(expires)
+}
+
+// sharedSecretMAC returns hex encoded HMAC-SHA1 of shared-secret registration request
+func sharedSecretMAC(secret string, request admin.RegisterRequest) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(request.Nonce))
+	mac.Write([]byte{0})
+	mac.Write([]byte(request.Username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(request.Password))
+	mac.Write([]byte{0})
+	if request.Admin {
+		mac.Write([]byte("admin"))
+	} else {
+		mac.Write([]byte("notadmin"))
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validSharedSecretMAC reports whether request is signed with shared secret
+func validSharedSecretMAC(secret string, request admin.RegisterRequest) bool {
+	mac, err := hex.DecodeString(request.MAC)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := hex.DecodeString(sharedSecretMAC(secret, request))
+
+	return hmac.Equal(mac, expected)
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/admin"
+)
+
+func TestSharedSecretMAC(t *testing.T) {
+	request := admin.RegisterRequest{
+		Nonce:    "nonce",
+		Username: "username1",
+		Password: "password1"}
+
+	// printf 'nonce\0username1\0password1\0notadmin' | openssl sha1 -hmac secret
+	assert.Equal(t, "2ee5c6b8d37b95db0db98c3fbdac912a55aae006", sharedSecretMAC("secret", request))
+
+	request.MAC = sharedSecretMAC("secret", request)
+	assert.True(t, validSharedSecretMAC("secret", request))
+	assert.False(t, validSharedSecretMAC("other secret", request))
+
+	request.Admin = true
+	assert.False(t, validSharedSecretMAC("secret", request))
+
+	request.MAC = "not hex"
+	assert.False(t, validSharedSecretMAC("secret", request))
+}
+
+func TestExpiringKeys(t *testing.T) {
+	keys := newExpiringKeys(authSessionLifetime)
+
+	key := keys.new()
+	assert.True(t, keys.valid(key))
+	assert.True(t, keys.take(key))
+	assert.False(t, keys.valid(key))
+	assert.False(t, keys.take(key))
+	assert.False(t, keys.valid(""))
+
+	expired := newExpiringKeys(-1)
+	assert.False(t, expired.take(expired.new()))
+}
@@ -1,33 +1,54 @@
 package internal
 
 import (
-	"errors"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"reflect"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
-	"github.com/signaller-matrix/signaller/internal/models/capabilities"
+	"github.com/signaller-matrix/signaller/internal/config"
+	"github.com/signaller-matrix/signaller/internal/oidc"
 )
 
 var currServer *Server
 
 type Server struct {
-	httpServer *http.Server
-	router     *mux.Router
+	httpServers []*http.Server
+	router      *mux.Router
+	limiter     *rateLimiter
+
+	authSessions   *expiringKeys  // sessions of user-interactive authentication of registration
+	registerNonces *expiringKeys  // nonces of shared-secret registration
+	sso            *oidc.Provider // nil if SSO login is disabled
+
+	config      *config.Config
+	logFile     *os.File
+	configMutex sync.RWMutex
 
 	Address string
 
-	Capabilities capabilities.Capabilities
-	Backend      Backend
+	Backend Backend
 }
 
-func NewServer(port int) (*Server, error) {
+// NewServer returns server which serves client API of backend on listeners of config
+func NewServer(cfg *config.Config, backend Backend) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
 	router := mux.NewRouter()
 	router.HandleFunc("/_matrix/client/versions", VersionHandler)
 	router.HandleFunc("/_matrix/client/r0/login", LoginHandler)
 	router.HandleFunc("/_matrix/client/r0/logout", LogoutHandler)
+	router.HandleFunc("/_matrix/client/r0/login/sso/redirect", ssoRedirectHandler)
+	router.HandleFunc("/_matrix/client/v1/login/get_token", loginGetTokenHandler)
+	router.HandleFunc("/_matrix/client/r0/account/3pid", threePIDsHandler)
 	router.HandleFunc("/_matrix/client/r0/logout/all", LogoutAllHandler)
 	router.HandleFunc("/_matrix/client/r0/register", RegisterHandler)
 	router.HandleFunc("/_matrix/client/r0/account/whoami", WhoAmIHandler)
@@ -44,25 +65,140 @@ func NewServer(port int) (*Server, error) {
 	router.HandleFunc("/_matrix/client/r0/user/{userId}/filter/{filterID}", GetFilterHandler).Methods("GET")
 	router.HandleFunc("/_matrix/client/r0/user/{userId}/filter", AddFilterHandler).Methods("POST")
 	router.HandleFunc("/_matrix/client/r0/directory/room/{roomAlias}", roomAliasHandler).Methods(http.MethodPut, http.MethodGet, http.MethodDelete)
+	router.HandleFunc("/_matrix/client/r0/notifications", notificationsHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/receipt/{receiptType}/{eventId}", receiptHandler)
+	router.HandleFunc("/_matrix/client/r0/pushers", pushersHandler)
+	router.HandleFunc("/_matrix/client/r0/pushers/set", setPusherHandler)
+	router.HandleFunc("/_matrix/client/r0/pushrules/", pushRulesHandler)
+	router.HandleFunc("/_matrix/client/r0/pushrules/{scope}/", pushRulesHandler)
+	router.HandleFunc("/_matrix/client/r0/pushrules/{scope}/{kind}/", pushRulesHandler)
+	router.HandleFunc("/_matrix/client/r0/pushrules/{scope}/{kind}/{ruleId}", pushRuleHandler).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+	router.HandleFunc("/_matrix/client/r0/pushrules/{scope}/{kind}/{ruleId}/enabled", pushRuleEnabledHandler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/_matrix/client/r0/pushrules/{scope}/{kind}/{ruleId}/actions", pushRuleActionsHandler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/_matrix/client/r0/search", searchHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/messages", roomMessagesHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/context/{eventId}", eventContextHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/event/{eventId}", roomEventHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/members", roomMembersHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/joined_members", joinedMembersHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/initialSync", roomInitialSyncHandler)
+	router.HandleFunc("/_matrix/client/r0/events", peekEventsHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/upgrade", roomUpgradeHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/aliases", roomAliasesHandler)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/state/m.room.canonical_alias", canonicalAliasHandler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/_matrix/client/r0/rooms/{roomId}/state/m.room.canonical_alias/", canonicalAliasHandler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/_matrix/client/r0/user/{userId}/account_data/{type}", accountDataHandler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/_matrix/client/r0/user/{userId}/rooms/{roomId}/account_data/{type}", roomAccountDataHandler).Methods(http.MethodGet, http.MethodPut)
+	router.HandleFunc("/_matrix/client/r0/user/{userId}/rooms/{roomId}/tags", roomTagsHandler).Methods(http.MethodGet)
+	router.HandleFunc("/_matrix/client/r0/user/{userId}/rooms/{roomId}/tags/{tag}", roomTagsHandler).Methods(http.MethodPut, http.MethodDelete)
 
-	router.HandleFunc("/", RootHandler)
+	router.HandleFunc("/_matrix/client/v1/register/m.login.registration_token/validity", registrationTokenValidityHandler)
+	router.HandleFunc("/_matrix/media/r0/config", mediaConfigHandler)
 
-	if port <= 0 || port > 65535 {
-		return nil, errors.New("invalid port number")
-	}
+	router.HandleFunc(ssoCallbackPath, ssoCallbackHandler)
+	router.HandleFunc("/_signaller/admin/v1/register", sharedSecretRegisterHandler)
+	router.HandleFunc("/_signaller/admin/v1/registration_tokens", registrationTokensHandler)
+	router.HandleFunc("/_signaller/admin/v1/registration_tokens/new", registrationTokensHandler).Methods(http.MethodPost)
+	router.HandleFunc("/_signaller/admin/v1/registration_tokens/{token}", registrationTokenHandler)
+	router.HandleFunc("/_signaller/admin/v1/users/{userId}/threepids", adminThreePIDsHandler)
 
-	httpServer := new(http.Server)
-	httpServer.Addr = ":" + strconv.Itoa(port)
-	httpServer.Handler = router
+	router.HandleFunc("/", RootHandler)
 
 	server := &Server{
-		httpServer: httpServer,
-		router:     router}
+		router:  router,
+		limiter: newRateLimiter(),
+
+		authSessions:   newExpiringKeys(authSessionLifetime),
+		registerNonces: newExpiringKeys(registerNonceLifetime),
+		Address:        cfg.ServerName,
+		Backend:        backend}
+
+	if err := server.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	handler := server.requestLogMiddleware(server.rateLimitMiddleware(router))
+	for _, listener := range cfg.Listeners {
+		server.httpServers = append(server.httpServers, &http.Server{
+			Addr:    net.JoinHostPort(listener.Address, strconv.Itoa(listener.Port)),
+			Handler: handler})
+	}
 
 	currServer = server
 	return server, nil
 }
 
+// Run serves all listeners and returns first error of them
 func (server *Server) Run() error {
-	return server.httpServer.ListenAndServe() // TODO: custom port
+	errs := make(chan error, len(server.httpServers))
+	for _, httpServer := range server.httpServers {
+		go func(httpServer *http.Server) {
+			errs <- httpServer.ListenAndServe()
+		}(httpServer)
+	}
+
+	return <-errs
+}
+
+// Config returns current server config
+func (server *Server) Config() *config.Config {
+	server.configMutex.RLock()
+	defer server.configMutex.RUnlock()
+
+	return server.config
+}
+
+// SSOProvider returns OpenID Connect provider and config which it was created for, provider is nil if SSO is disabled
+func (server *Server) SSOProvider() (*oidc.Provider, *config.Config) {
+	server.configMutex.RLock()
+	defer server.configMutex.RUnlock()
+
+	return server.sso, server.config
+}
+
+// SetConfig applies new config. Values which can't be changed without restart (see config.Reload)
+// must be the same as in previous config. Log file is reopened, so it can be rotated. SSO provider
+// is recreated only if its settings are changed, so logins in progress aren't lost.
+func (server *Server) SetConfig(cfg *config.Config) error {
+	var logFile *os.File
+	if cfg.Logging.File != "" {
+		var err error
+		logFile, err = os.OpenFile(cfg.Logging.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		log.SetOutput(logFile)
+	} else {
+		log.SetOutput(os.Stderr)
+	}
+
+	server.configMutex.Lock()
+	if server.logFile != nil {
+		server.logFile.Close()
+	}
+	server.logFile = logFile
+	if server.config == nil || !reflect.DeepEqual(server.config.SSO, cfg.SSO) || server.config.PublicBaseURL != cfg.PublicBaseURL {
+		server.sso = newSSOProvider(cfg)
+	}
+	server.config = cfg
+	server.configMutex.Unlock()
+
+	server.Backend.SetAccessTokenLifetime(cfg.Tokens.AccessTokenLifetime.Duration)
+	server.Backend.SetValidateUsernameFunc(newUsernamePolicy(cfg).Validate)
+
+	return nil
+}
+
+// requestLogMiddleware logs each request if it is enabled in config
+func (server *Server) requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !server.Config().Logging.Requests {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s %s", r.RemoteAddr, r.Method, r.URL.Path, time.Since(start))
+	})
 }
@@ -0,0 +1,52 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/backends/memory"
+	"github.com/signaller-matrix/signaller/internal/config"
+)
+
+func TestRateLimitByToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimits = config.RateLimits{Enabled: true, PerSecond: 0.001, Burst: 1}
+
+	backend := memory.NewBackend(cfg.ServerName)
+	t.Cleanup(func() {
+		backend.Close()
+	})
+
+	_, token, err := backend.Register("user1", "", "")
+	assert.NoError(t, err)
+
+	server, serverErr := internal.NewServer(cfg, backend)
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+
+	get := func(accessToken string) int {
+		request := httptest.NewRequest(http.MethodGet, "/_matrix/client/versions", nil)
+		if accessToken != "" {
+			request.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	// Valid token has its own bucket
+	assert.Equal(t, http.StatusOK, get(token))
+	assert.Equal(t, http.StatusTooManyRequests, get(token))
+
+	// Unknown tokens share bucket of IP address
+	assert.Equal(t, http.StatusOK, get("unknown1"))
+	assert.Equal(t, http.StatusTooManyRequests, get("unknown2"))
+	assert.Equal(t, http.StatusTooManyRequests, get(""))
+}
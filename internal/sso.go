@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/signaller-matrix/signaller/internal/config"
+	"github.com/signaller-matrix/signaller/internal/models"
+	"github.com/signaller-matrix/signaller/internal/oidc"
+)
+
+// ssoCallbackPath is path of server endpoint which receives authorization code from OpenID Connect provider
+const ssoCallbackPath = "/_signaller/client/oidc/callback"
+
+// ssoSessionCookie is cookie with browser key of SSO login, it binds login to browser which has started it
+const ssoSessionCookie = "signaller_sso_session"
+
+// newSSOProvider returns OpenID Connect provider of config or nil if SSO is disabled, config must be valid
+func newSSOProvider(cfg *config.Config) *oidc.Provider {
+	if !cfg.SSO.Enabled {
+		return nil
+	}
+
+	return oidc.NewProvider(cfg.SSO.Issuer, cfg.SSO.ClientID, cfg.SSO.ClientSecret, cfg.SSO.Scopes,
+		strings.TrimSuffix(cfg.PublicBaseURL, "/")+ssoCallbackPath)
+}
+
+// https://matrix.org/docs/spec/client_server/latest#get-matrix-client-r0-login-sso-redirect
+func ssoRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	provider, cfg := currServer.SSOProvider()
+	if provider == nil {
+		errorResponse(w, models.M_UNRECOGNIZED, http.StatusNotFound, "SSO login is disabled")
+		return
+	}
+
+	redirectURL := r.URL.Query().Get("redirectUrl")
+	if redirectURL == "" {
+		errorResponse(w, models.M_MISSING_PARAM, http.StatusBadRequest, "redirectUrl is required")
+		return
+	}
+
+	if !isAllowedSSORedirect(redirectURL, cfg.SSO.ClientRedirectPrefixes) {
+		errorResponse(w, models.M_INVALID_PARAM, http.StatusBadRequest, "redirectUrl is not allowed")
+		return
+	}
+
+	authURL, browserKey, err := provider.AuthURL(redirectURL)
+	if err != nil {
+		log.Println(err)
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadGateway, "SSO provider is unavailable")
+		return
+	}
+
+	// Lax mode is required because callback is cross-site navigation from provider
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoSessionCookie,
+		Value:    browserKey,
+		Path:     ssoCallbackPath,
+		Secure:   strings.HasPrefix(cfg.PublicBaseURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// ssoCallbackHandler finishes SSO login: user of provider is registered on first login and redirected to client
+// with token for m.login.token login
+func ssoCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, models.M_UNKNOWN, http.StatusBadRequest, "wrong method: "+r.Method)
+		return
+	}
+
+	provider, cfg := currServer.SSOProvider()
+	if provider == nil {
+		errorResponse(w, models.M_UNRECOGNIZED, http.StatusNotFound, "SSO login is disabled")
+		return
+	}
+
+	// https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "SSO provider returned error: "+providerErr)
+		return
+	}
+
+	var browserKey string
+	if cookie, err := r.Cookie(ssoSessionCookie); err == nil {
+		browserKey = cookie.Value
+	}
+
+	// Browser key is single-use as well as state
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoSessionCookie,
+		Path:     ssoCallbackPath,
+		MaxAge:   -1,
+		Secure:   strings.HasPrefix(cfg.PublicBaseURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode})
+
+	claims, redirectURL, err := provider.Exchange(query.Get("state"), query.Get("code"), browserKey)
+	if err != nil {
+		log.Println(err)
+		errorResponse(w, models.M_FORBIDDEN, http.StatusForbidden, "SSO login failed")
+		return
+	}
+
+	user := currServer.Backend.GetUserBySSOSubject(cfg.SSO.Issuer, claims.Subject())
+	if user == nil {
+		localpart, err := ssoLocalpart(&cfg.SSO, claims)
+		if err != nil {
+			errorResponse(w, models.M_INVALID_USERNAME, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var apiErr models.ApiError
+		user, apiErr = currServer.Backend.CreateSSOUser(localpart, cfg.SSO.Issuer, claims.Subject())
+		if apiErr != nil {
+			errorResponse(w, apiErr, registerHTTPCode(apiErr), "")
+			return
+		}
+	}
+
+	loginToken := currServer.Backend.CreateLoginToken(user, cfg.Tokens.LoginTokenLifetime.Duration)
+
+	http.Redirect(w, r, addQueryParam(redirectURL, "loginToken", loginToken), http.StatusFound)
+}
+
+// ssoLocalpart returns localpart of new user which is made from claims of ID token by localpart template
+func ssoLocalpart(sso *config.SSO, claims oidc.Claims) (string, error) {
+	tmpl, err := sso.LocalpartTemplateParsed()
+	if err != nil {
+		return "", err
+	}
+
+	var localpart bytes.Buffer
+	if err := tmpl.Execute(&localpart, map[string]interface{}(claims)); err != nil {
+		return "", err
+	}
+
+	return NormalizeUsername(strings.TrimSpace(localpart.String())), nil
+}
+
+// isAllowedSSORedirect reports whether client redirect URL is absolute http or https URL which matches
+// one of prefixes: scheme and host are the same and path is the same or is inside path of prefix.
+// No URL is allowed if there are no prefixes.
+func isAllowedSSORedirect(redirectURL string, prefixes []string) bool {
+	u, err := url.Parse(redirectURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || hasDotSegment(u.Path) {
+		return false
+	}
+
+	for _, prefix := range prefixes {
+		p, err := url.Parse(prefix)
+		if err != nil {
+			continue
+		}
+
+		if u.Scheme == p.Scheme && strings.EqualFold(u.Host, p.Host) && hasPathPrefix(u.EscapedPath(), p.EscapedPath()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPathPrefix reports whether path is the same as prefix or is inside it, e.g. /app/login is inside /app
+// and /app/, but not inside /ap
+func hasPathPrefix(path, prefix string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		if path == prefix {
+			return true
+		}
+		prefix += "/"
+	}
+
+	return strings.HasPrefix(path, prefix)
+}
+
+// hasDotSegment reports whether unescaped path has . or .. segment, which browser resolves
+// and so can move redirect out of allowed prefix. Backslash is separator for browsers too.
+func hasDotSegment(path string) bool {
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addQueryParam returns URL with added query parameter, URL must be valid
+func addQueryParam(rawURL, name, value string) string {
+	u, _ := url.Parse(rawURL)
+
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
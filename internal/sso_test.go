@@ -0,0 +1,218 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal"
+	"github.com/signaller-matrix/signaller/internal/backends/memory"
+	"github.com/signaller-matrix/signaller/internal/config"
+	"github.com/signaller-matrix/signaller/internal/models/login"
+	"github.com/signaller-matrix/signaller/internal/oidc/oidctest"
+)
+
+const clientRedirectURL = "https://client.example.com/login?app=1"
+
+// ssoTestServer serves client API with SSO login through fake provider
+type ssoTestServer struct {
+	t        *testing.T
+	provider *oidctest.Provider
+	handler  http.Handler
+}
+
+func newSSOTestServer(t *testing.T, prefixes ...string) *ssoTestServer {
+	provider := oidctest.NewProvider("signaller", "secret")
+
+	cfg := config.Default()
+	cfg.PublicBaseURL = "https://matrix.example.com"
+	cfg.SSO = config.SSO{
+		Enabled:                true,
+		Issuer:                 provider.URL,
+		ClientID:               "signaller",
+		ClientSecret:           "secret",
+		Scopes:                 []string{"openid", "profile"},
+		LocalpartTemplate:      "{{.preferred_username}}",
+		ClientRedirectPrefixes: prefixes}
+
+	backend := memory.NewBackend(cfg.ServerName)
+	t.Cleanup(func() {
+		provider.Close()
+		backend.Close()
+	})
+
+	server, err := internal.NewServer(cfg, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ssoTestServer{t: t, provider: provider, handler: server.Handler()}
+}
+
+func (server *ssoTestServer) get(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handler.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+// authorize starts SSO login and returns path of callback and cookies set by server
+func (server *ssoTestServer) authorize(redirectURL string) (string, []*http.Cookie) {
+	response := server.get("/_matrix/client/r0/login/sso/redirect?redirectUrl=" + url.QueryEscape(redirectURL))
+	if !assert.Equal(server.t, http.StatusFound, response.Code, response.Body.String()) {
+		server.t.FailNow()
+	}
+
+	callback, err := server.provider.Authorize(response.Header().Get("Location"))
+	if err != nil {
+		server.t.Fatal(err)
+	}
+
+	assert.True(server.t, strings.HasPrefix(callback, "https://matrix.example.com/_signaller/client/oidc/callback?"))
+
+	return strings.TrimPrefix(callback, "https://matrix.example.com"), response.Result().Cookies()
+}
+
+// login performs SSO login and returns response of callback
+func (server *ssoTestServer) login(redirectURL string) *httptest.ResponseRecorder {
+	callback, cookies := server.authorize(redirectURL)
+
+	return server.get(callback, cookies...)
+}
+
+// tokenLogin exchanges login token of client redirect URL for access token and returns user ID
+func (server *ssoTestServer) tokenLogin(location string) string {
+	redirect, err := url.Parse(location)
+	if err != nil {
+		server.t.Fatal(err)
+	}
+
+	body := `{"type":"m.login.token","token":"` + redirect.Query().Get("loginToken") + `"}`
+	recorder := httptest.NewRecorder()
+	server.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/_matrix/client/r0/login", strings.NewReader(body)))
+	if !assert.Equal(server.t, http.StatusOK, recorder.Code, recorder.Body.String()) {
+		return ""
+	}
+
+	var reply login.PostReply
+	assert.NoError(server.t, json.Unmarshal(recorder.Body.Bytes(), &reply))
+
+	return reply.UserID
+}
+
+func TestSSOLogin(t *testing.T) {
+	server := newSSOTestServer(t, "https://client.example.com/")
+
+	flows := server.get("/_matrix/client/r0/login")
+	assert.Contains(t, flows.Body.String(), `"m.login.sso"`)
+
+	// login is bound to browser by cookie which isn't available to scripts
+	_, cookies := server.authorize(clientRedirectURL)
+	if assert.Len(t, cookies, 1) {
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, "/_signaller/client/oidc/callback", cookies[0].Path)
+		assert.NotEmpty(t, cookies[0].Value)
+	}
+
+	server.provider.SetClaims(map[string]interface{}{"sub": "42", "preferred_username": "Alice.Smith"})
+
+	response := server.login(clientRedirectURL)
+	if !assert.Equal(t, http.StatusFound, response.Code, response.Body.String()) {
+		return
+	}
+
+	location := response.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "https://client.example.com/login?"))
+	assert.Contains(t, location, "app=1")
+	assert.Equal(t, "@alice.smith:localhost", server.tokenLogin(location))
+
+	// subject is linked to user, so changed claims don't create another user
+	server.provider.SetClaims(map[string]interface{}{"sub": "42", "preferred_username": "renamed"})
+	response = server.login(clientRedirectURL)
+	assert.Equal(t, "@alice.smith:localhost", server.tokenLogin(response.Header().Get("Location")))
+
+	// localpart of another subject is taken
+	server.provider.SetClaims(map[string]interface{}{"sub": "43", "preferred_username": "alice.smith"})
+	response = server.login(clientRedirectURL)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "M_USER_IN_USE")
+
+	// claim which is used by template is missing
+	server.provider.SetClaims(map[string]interface{}{"sub": "44"})
+	response = server.login(clientRedirectURL)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "M_INVALID_USERNAME")
+}
+
+func TestSSOLoginErrors(t *testing.T) {
+	server := newSSOTestServer(t, "https://client.example.com/")
+
+	response := server.get("/_matrix/client/r0/login/sso/redirect")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "M_MISSING_PARAM")
+
+	response = server.get("/_matrix/client/r0/login/sso/redirect?redirectUrl=" + url.QueryEscape("https://evil.example.com/"))
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = server.get("/_signaller/client/oidc/callback?state=unknown&code=code")
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	response = server.get("/_signaller/client/oidc/callback?error=access_denied")
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	// callback is opened in browser which hasn't started login
+	callback, _ := server.authorize(clientRedirectURL)
+	response = server.get(callback)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	callback, _ = server.authorize(clientRedirectURL)
+	response = server.get(callback, &http.Cookie{Name: "signaller_sso_session", Value: "another"})
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestIsAllowedSSORedirect(t *testing.T) {
+	prefixes := []string{"https://client.example.com/app", "https://other.example.com/", "http://localhost:8080"}
+
+	tests := []struct {
+		redirectURL string
+		allowed     bool
+	}{
+		{"https://client.example.com/app", true},
+		{"https://client.example.com/app/login?x=1", true},
+		{"https://client.example.com/app/../evil?x", false},
+		{"https://client.example.com/app/%2e%2e/evil", false},
+		{"https://client.example.com/app/..%2Fevil", false},
+		{"https://client.example.com/app/..\\evil", false},
+		{"https://client.example.com/app/./login", false},
+		{"https://client.example.com/app/..login", true},
+		{"https://CLIENT.example.com/app/", true},
+		{"https://client.example.com/application", false},
+		{"https://client.example.com/", false},
+		{"http://client.example.com/app", false},
+		{"https://client.example.com.evil.com/app", false},
+		{"https://client.example.com@evil.com/app", false},
+		{"https://client.example.com:8443/app", false},
+		{"https://other.example.com", true},
+		{"https://other.example.com/any/path", true},
+		{"http://localhost:8080/", true},
+		{"http://localhost:8081/", false},
+		{"javascript:alert(1)", false},
+		{"/relative", false}}
+
+	for _, test := range tests {
+		assert.Equal(t, test.allowed, internal.IsAllowedSSORedirect(test.redirectURL, prefixes), test.redirectURL)
+	}
+
+	assert.False(t, internal.IsAllowedSSORedirect("https://client.example.com/app", nil))
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models/threepids"
+)
+
+func TestPhoneToMSISDN(t *testing.T) {
+	tests := []struct {
+		country  string
+		phone    string
+		expected string
+	}{
+		{"GB", "07700 900123", "447700900123"},
+		{"us", "(201) 555-0123", "12015550123"},
+		{"RU", "+7 912 345-67-89", "79123456789"},
+		{"", "+49 30 123456", "4930123456"}}
+
+	for _, test := range tests {
+		msisdn, err := PhoneToMSISDN(test.country, test.phone)
+		assert.NoError(t, err, test.phone)
+		assert.Equal(t, test.expected, msisdn)
+	}
+
+	for _, test := range []struct{ country, phone string }{
+		{"", "07700 900123"},
+		{"XX", "07700 900123"},
+		{"GB", "0770x900123"},
+		{"GB", "12"},
+		{"", "+1234567890123456"}} {
+		_, err := PhoneToMSISDN(test.country, test.phone)
+		assert.Error(t, err, test.phone)
+	}
+}
+
+func TestCanonicalThreePID(t *testing.T) {
+	address, err := CanonicalThreePID(threepids.MediumEmail, " User@Example.COM ")
+	assert.NoError(t, err)
+	assert.Equal(t, "user@example.com", address)
+
+	address, err = CanonicalThreePID(threepids.MediumMSISDN, "+44 7700 900123")
+	assert.NoError(t, err)
+	assert.Equal(t, "447700900123", address)
+
+	address, err = CanonicalThreePID(threepids.MediumMSISDN, "447700900123")
+	assert.NoError(t, err)
+	assert.Equal(t, "447700900123", address)
+
+	for _, email := range []string{"user", "@example.com", "user@", "us er@example.com"} {
+		_, err = CanonicalThreePID(threepids.MediumEmail, email)
+		assert.Error(t, err, email)
+	}
+
+	_, err = CanonicalThreePID("fax", "123")
+	assert.Error(t, err)
+}
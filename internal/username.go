@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/signaller-matrix/signaller/internal/config"
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+// maxUserIDLength is maximum length of user ID including @ and server name
+// https://matrix.org/docs/spec/appendices#user-identifiers
+const maxUserIDLength = 255
+
+// localpartRegexp matches localparts of user IDs which are allowed for new users
+// https://matrix.org/docs/spec/appendices#user-identifiers
+var localpartRegexp = regexp.MustCompile(`^[a-z0-9._=\-/]+$`)
+
+// UsernamePolicy describes which localparts can be registered
+type UsernamePolicy struct {
+	ServerName string           // Name of server which is used to check length of user ID
+	MinLength  int              // Minimum length of localpart
+	MaxLength  int              // Maximum length of localpart, zero for limit of user ID length only
+	Reserved   []string         // Localparts which can't be registered
+	Exclusive  []*regexp.Regexp // Namespaces of user IDs which are reserved for application services
+}
+
+// DefaultUsernamePolicy returns policy which is used when no policy is configured
+func DefaultUsernamePolicy(serverName string) *UsernamePolicy {
+	return &UsernamePolicy{
+		ServerName: serverName,
+		MinLength:  5}
+}
+
+// newUsernamePolicy returns username policy of config, config must be valid
+func newUsernamePolicy(cfg *config.Config) *UsernamePolicy {
+	exclusive, _ := cfg.Usernames.ExclusiveRegexps()
+
+	var reserved []string
+	for _, localpart := range cfg.Usernames.Reserved {
+		reserved = append(reserved, NormalizeUsername(localpart))
+	}
+
+	return &UsernamePolicy{
+		ServerName: cfg.ServerName,
+		MinLength:  cfg.Usernames.MinLength,
+		MaxLength:  cfg.Usernames.MaxLength,
+		Reserved:   reserved,
+		Exclusive:  exclusive}
+}
+
+// NormalizeUsername returns localpart in the form which is used for registration and login,
+// user IDs are case-insensitive for new users
+func NormalizeUsername(username string) string {
+	return strings.ToLower(username)
+}
+
+// Validate checks that normalized localpart is valid and isn't reserved
+func (policy *UsernamePolicy) Validate(localpart string) models.ApiError {
+	if !localpartRegexp.MatchString(localpart) {
+		return models.NewError(models.M_INVALID_USERNAME, "username can contain only a-z, 0-9, '.', '_', '=', '-' and '/'")
+	}
+
+	if len(localpart) < policy.MinLength {
+		return models.NewError(models.M_INVALID_USERNAME, fmt.Sprintf("username must be at least %d characters long", policy.MinLength))
+	}
+
+	if policy.MaxLength > 0 && len(localpart) > policy.MaxLength {
+		return models.NewError(models.M_INVALID_USERNAME, fmt.Sprintf("username must be at most %d characters long", policy.MaxLength))
+	}
+
+	userID := "@" + localpart + ":" + policy.ServerName
+	if len(userID) > maxUserIDLength {
+		return models.NewError(models.M_INVALID_USERNAME, fmt.Sprintf("user ID must be at most %d characters long", maxUserIDLength))
+	}
+
+	if InArray(localpart, policy.Reserved) {
+		return models.NewError(models.M_INVALID_USERNAME, "username is reserved")
+	}
+
+	for _, namespace := range policy.Exclusive {
+		if namespace.MatchString(userID) {
+			return models.NewError(models.M_EXCLUSIVE, "username is reserved by application service")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/signaller-matrix/signaller/internal/models"
+)
+
+func TestUsernamePolicyValidate(t *testing.T) {
+	policy := &UsernamePolicy{
+		ServerName: "example.com",
+		MinLength:  3,
+		MaxLength:  20,
+		Reserved:   []string{"admin"},
+		Exclusive:  []*regexp.Regexp{regexp.MustCompile(`^(?:@irc_.*:example\.com)$`)}}
+
+	tests := []struct {
+		localpart string
+		expected  models.ApiError
+	}{
+		{"a.b-c=d/e", nil},
+		{"user_1", nil},
+		{"admins", nil},
+		{"Alice", models.M_INVALID_USERNAME},
+		{"al ice", models.M_INVALID_USERNAME},
+		{"al:ice", models.M_INVALID_USERNAME},
+		{"ab", models.M_INVALID_USERNAME},
+		{strings.Repeat("a", 21), models.M_INVALID_USERNAME},
+		{"admin", models.M_INVALID_USERNAME},
+		{"irc_bot", models.M_EXCLUSIVE},
+		{"bot_irc_", nil}}
+
+	for _, test := range tests {
+		err := policy.Validate(test.localpart)
+		if test.expected == nil {
+			assert.Nil(t, err, test.localpart)
+		} else if assert.NotNil(t, err, test.localpart) {
+			assert.Equal(t, test.expected.Code(), err.Code(), test.localpart)
+		}
+	}
+
+	// Length of whole user ID is limited
+	policy.MaxLength = 0
+	assert.Nil(t, policy.Validate(strings.Repeat("a", maxUserIDLength-len("@:example.com"))))
+	assert.NotNil(t, policy.Validate(strings.Repeat("a", maxUserIDLength-len("@:example.com")+1)))
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	assert.Equal(t, "alice", NormalizeUsername("ALice"))
+}
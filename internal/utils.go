@@ -1,9 +1,10 @@
 package internal
 
 import (
+	"errors"
+	"net"
+	"strconv"
 	"strings"
-
-	"github.com/signaller-matrix/signaller/internal/models/publicrooms"
 )
 
 func GetCanonicalAlias(hostName string, alias string) string {
@@ -17,25 +18,108 @@ func StripAlias(hostName string, canonicalAlias string) string {
 	return canonicalAlias
 }
 
-func roomsToPublicRoomsChunks(rooms []Room) []publicrooms.PublicRoomsChunk {
-	var chunks []publicrooms.PublicRoomsChunk
+// maxAliasLength is maximum length of room alias including sigil and server name
+const maxAliasLength = 255
+
+// SplitAlias checks grammar of room alias and returns its localpart and server name
+// https://matrix.org/docs/spec/appendices#room-aliases
+func SplitAlias(alias string) (localpart, serverName string, err error) {
+	if len(alias) > maxAliasLength {
+		return "", "", errors.New("room alias is too long")
+	}
+
+	if !strings.HasPrefix(alias, "#") {
+		return "", "", errors.New("room alias must start with #")
+	}
+
+	separatorIndex := strings.Index(alias, ":")
+	if separatorIndex < 0 {
+		return "", "", errors.New("room alias must contain server name")
+	}
+
+	localpart, serverName = alias[1:separatorIndex], alias[separatorIndex+1:]
+	if localpart == "" || strings.ContainsAny(localpart, " \t\n#") {
+		return "", "", errors.New("invalid room alias localpart")
+	}
+
+	if !IsValidServerName(serverName) {
+		return "", "", errors.New("invalid server name " + serverName)
+	}
+
+	return localpart, serverName, nil
+}
+
+// SplitUserID checks grammar of user ID and returns its localpart and server name. Historical localparts
+// which contain any printable characters except colon are accepted.
+// https://matrix.org/docs/spec/appendices#user-identifiers
+func SplitUserID(userID string) (localpart, serverName string, err error) {
+	if len(userID) > maxUserIDLength {
+		return "", "", errors.New("user ID is too long")
+	}
+
+	if !strings.HasPrefix(userID, "@") {
+		return "", "", errors.New("user ID must start with @")
+	}
+
+	separatorIndex := strings.Index(userID, ":")
+	if separatorIndex < 0 {
+		return "", "", errors.New("user ID must contain server name")
+	}
+
+	localpart, serverName = userID[1:separatorIndex], userID[separatorIndex+1:]
+	if localpart == "" || strings.ContainsAny(localpart, " \t\n") {
+		return "", "", errors.New("invalid user ID localpart")
+	}
+
+	if !IsValidServerName(serverName) {
+		return "", "", errors.New("invalid server name " + serverName)
+	}
+
+	return localpart, serverName, nil
+}
+
+// IsValidServerName checks grammar of server name
+// https://matrix.org/docs/spec/appendices#server-name
+func IsValidServerName(serverName string) bool {
+	// IPv6 literal with optional port
+	if strings.HasPrefix(serverName, "[") {
+		closeIndex := strings.Index(serverName, "]")
+		if closeIndex < 0 || net.ParseIP(serverName[1:closeIndex]) == nil {
+			return false
+		}
+
+		port := serverName[closeIndex+1:]
+		return port == "" || strings.HasPrefix(port, ":") && isValidPort(port[1:])
+	}
 
-	for _, room := range rooms {
-		chunk := publicrooms.PublicRoomsChunk{
-			Aliases:          room.Aliases(),
-			CanonicalAlias:   room.AliasName(),
-			Name:             room.Name(),
-			NumJoinedMembers: len(room.Users()),
-			RoomID:           room.ID(),
-			Topic:            room.Topic(),
-			WorldReadable:    room.WorldReadable(),
-			GuestCanJoin:     room.GuestCanJoin(),
-			AvatarURL:        room.AvatarURL()}
+	host := serverName
+	if colonIndex := strings.LastIndex(serverName, ":"); colonIndex >= 0 {
+		if !isValidPort(serverName[colonIndex+1:]) {
+			return false
+		}
+		host = serverName[:colonIndex]
+	}
+
+	if host == "" || len(host) > 255 {
+		return false
+	}
+
+	for _, c := range host {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+
+	return true
+}
 
-		chunks = append(chunks, chunk)
+func isValidPort(port string) bool {
+	if len(port) == 0 || len(port) > 5 {
+		return false
 	}
 
-	return chunks
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
 }
 
 func InArray(a string, arr []string) bool {
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,3 +33,66 @@ func TestStripAlias(t *testing.T) {
 	}
 
 }
+
+func TestSplitAlias(t *testing.T) {
+	tests := []struct {
+		alias      string
+		localpart  string
+		serverName string
+		valid      bool
+	}{
+		{"#alias:host.com", "alias", "host.com", true},
+		{"#alias:host.com:8448", "alias", "host.com:8448", true},
+		{"#alias:[::1]:8448", "alias", "[::1]:8448", true},
+		{"#alias:127.0.0.1", "alias", "127.0.0.1", true},
+		{"alias:host.com", "", "", false},
+		{"#alias", "", "", false},
+		{"#:host.com", "", "", false},
+		{"#ali as:host.com", "", "", false},
+		{"#alias:host_com", "", "", false},
+		{"#alias:host.com:99999", "", "", false},
+		{"#alias:[::1", "", "", false},
+		{"#" + strings.Repeat("a", 255) + ":host.com", "", "", false}}
+
+	for _, test := range tests {
+		localpart, serverName, err := SplitAlias(test.alias)
+		if !test.valid {
+			assert.Error(t, err, test.alias)
+			continue
+		}
+
+		assert.NoError(t, err, test.alias)
+		assert.Equal(t, test.localpart, localpart)
+		assert.Equal(t, test.serverName, serverName)
+	}
+}
+
+func TestSplitUserID(t *testing.T) {
+	tests := []struct {
+		userID     string
+		localpart  string
+		serverName string
+		valid      bool
+	}{
+		{"@user:host.com", "user", "host.com", true},
+		{"@a.b-c=d/e:host.com:8448", "a.b-c=d/e", "host.com:8448", true},
+		{"@User:host.com", "User", "host.com", true},
+		{"user:host.com", "", "", false},
+		{"@user", "", "", false},
+		{"@:host.com", "", "", false},
+		{"@us er:host.com", "", "", false},
+		{"@user:host_com", "", "", false},
+		{"@" + strings.Repeat("a", 255) + ":host.com", "", "", false}}
+
+	for _, test := range tests {
+		localpart, serverName, err := SplitUserID(test.userID)
+		if !test.valid {
+			assert.Error(t, err, test.userID)
+			continue
+		}
+
+		assert.NoError(t, err, test.userID)
+		assert.Equal(t, test.localpart, localpart)
+		assert.Equal(t, test.serverName, serverName)
+	}
+}